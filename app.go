@@ -2,9 +2,22 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Marcel-Bich/dogma/internal/changeset"
+	"github.com/Marcel-Bich/dogma/internal/checkpoint"
 	"github.com/Marcel-Bich/dogma/internal/claude"
+	"github.com/Marcel-Bich/dogma/internal/events"
+	"github.com/Marcel-Bich/dogma/internal/git"
 	"github.com/Marcel-Bich/dogma/internal/updater"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -13,6 +26,8 @@ import (
 type promptSpawner interface {
 	SendPrompt(ctx context.Context, prompt string, handler claude.EventHandler) error
 	SendPromptWithSession(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error
+	SendPromptInDir(ctx context.Context, prompt string, sessionID string, dir string, handler claude.EventHandler) error
+	Version(ctx context.Context) (string, error)
 	Cancel()
 }
 
@@ -24,6 +39,91 @@ type eventEmitter interface {
 // sessionLister abstracts session listing for testability.
 type sessionLister interface {
 	ListSessions(projectPath string) ([]claude.SessionInfo, error)
+	ListProjects() ([]claude.ProjectInfo, error)
+	LoadTodos(projectPath string, sessionID string) ([]claude.TodoItem, error)
+	LoadSession(projectPath string, sessionID string) (claude.Transcript, error)
+	SessionMessages(projectPath string, sessionID string, offset int, limit int) (claude.MessagePage, error)
+	OlderMessages(projectPath string, sessionID string, before int, limit int) (claude.MessagePage, error)
+	MessagesAround(projectPath string, sessionID string, uuid string, limit int) (claude.MessagePage, error)
+	SessionStats(projectPath string, sessionID string) (claude.SessionStats, error)
+	SessionBranches(projectPath string, sessionID string) (claude.ConversationTree, error)
+	LoadBranch(projectPath string, sessionID string, leafUUID string) (claude.Transcript, error)
+	SessionWorkDir(projectPath string, sessionID string) (string, error)
+	SessionModTime(projectPath string, sessionID string) (time.Time, error)
+}
+
+// sessionManager abstracts session metadata and trash operations for testability.
+type sessionManager interface {
+	ListSessionsFiltered(projectPath string, filter claude.SessionFilter) ([]claude.SessionInfo, error)
+	QuerySessions(projectPath string, q claude.SessionQuery) (claude.SessionPage, error)
+	ListSessionsByBranch(projectPath string, filter claude.SessionFilter) ([]claude.SessionGroup, error)
+	RenameSession(sessionID string, title string) error
+	SetSessionPinned(sessionID string, pinned bool) error
+	SetSessionTags(sessionID string, tags []string) error
+	SetSessionArchived(sessionID string, archived bool) error
+	TrashSession(projectPath string, sessionID string) error
+	RestoreSession(sessionID string) error
+	ListTrash() ([]claude.TrashedSession, error)
+	ForkSession(projectPath string, sessionID string, messageUUID string) (string, error)
+	AdoptWorktreeSessions(projectPath string, worktreePath string) ([]string, error)
+}
+
+// sessionExporter abstracts session export for testability.
+type sessionExporter interface {
+	ExportSession(projectPath string, sessionID string, opts claude.ExportOptions) ([]byte, error)
+}
+
+// sessionBundler abstracts portable session bundles for testability.
+type sessionBundler interface {
+	ExportBundle(projectPath string, sessionID string) ([]byte, error)
+	ImportBundle(data []byte, targetPath string, opts claude.ImportOptions) (claude.SessionInfo, error)
+}
+
+// sessionTailer abstracts live tailing of sessions run outside the app.
+type sessionTailer interface {
+	TailSession(projectPath string, sessionID string) (*claude.SessionTail, claude.Transcript, error)
+}
+
+// checkpointStore abstracts file checkpoints for testability.
+type checkpointStore interface {
+	NewRecorder(workDir string, sessionID string, prompt string) *checkpoint.Recorder
+	List(sessionID string) ([]checkpoint.Checkpoint, error)
+	Preview(sessionID string, turn int) ([]checkpoint.Change, error)
+	Restore(sessionID string, turn int) ([]checkpoint.Change, error)
+}
+
+// changesetStore abstracts the per-session record of run changesets for
+// testability.
+type changesetStore interface {
+	Add(cs changeset.Changeset) error
+	List(sessionID string) ([]changeset.Changeset, error)
+}
+
+// gitRepo abstracts the git working tree for testability.
+type gitRepo interface {
+	Changes(ctx context.Context) (git.Changes, error)
+	Stage(ctx context.Context, paths ...string) error
+	Discard(ctx context.Context, path string) error
+	Commit(ctx context.Context, message string) (string, error)
+	Worktrees(ctx context.Context) ([]git.Worktree, error)
+	AddWorktree(ctx context.Context, path string, branch string) error
+	MergeWorktree(ctx context.Context, path string, message string) error
+	RemoveWorktree(ctx context.Context, path string) error
+}
+
+// gitOpener returns the gitRepo for a directory.
+type gitOpener func(dir string) gitRepo
+
+// openDialogFunc abstracts the native open dialog for testability.
+type openDialogFunc func(ctx context.Context, opts runtime.OpenDialogOptions) (string, error)
+
+// saveDialogFunc abstracts the native save dialog for testability.
+type saveDialogFunc func(ctx context.Context, opts runtime.SaveDialogOptions) (string, error)
+
+// sessionSearcher abstracts full-text session search for testability.
+type sessionSearcher interface {
+	Search(query string, filters claude.SearchFilters) ([]claude.SearchHit, error)
+	Refresh() error
 }
 
 // updateApplier abstracts the update apply call for testability.
@@ -38,16 +138,72 @@ func (e *wailsEmitter) Emit(eventName string, data ...interface{}) {
 	runtime.EventsEmit(e.ctx, eventName, data...)
 }
 
+// frontendTopics selects the bus topics forwarded to the frontend.
+var frontendTopics = events.OnTopics(
+	events.BridgeEvents.Name,
+	events.Todos.Name,
+	events.Errors.Name,
+	events.RunFinished.Name,
+	events.SessionsAdded.Name,
+	events.SessionsUpdated.Name,
+	events.SessionsRemoved.Name,
+	events.SessionDetached.Name,
+	events.GitChanges.Name,
+	events.UpdateAvailable.Name,
+	events.UpdateApplied.Name,
+	events.UpdateFailed.Name,
+)
+
+// emitterSubscriber forwards bus events to an eventEmitter using the
+// event names and payload shapes the frontend expects.
+type emitterSubscriber struct {
+	emitter eventEmitter
+}
+
+func (s emitterSubscriber) HandleEvent(ev events.Event) {
+	switch p := ev.Payload.(type) {
+	case events.RunEnd:
+		// Failed runs are reported through claude:error only
+		if p.Error == "" {
+			s.emitter.Emit("claude:done", p)
+		}
+	case events.RunError:
+		s.emitter.Emit("claude:error", p.Message)
+	default:
+		s.emitter.Emit(ev.Topic, ev.Payload)
+	}
+}
+
 // App struct
 type App struct {
 	ctx         context.Context
 	spawner     promptSpawner
 	lister      sessionLister
+	searcher    sessionSearcher
+	manager     sessionManager
+	exporter    sessionExporter
+	bundler     sessionBundler
+	tailer      sessionTailer
+	checkpoints checkpointStore
+	changesets  changesetStore
+	openGit     gitOpener
+	worktreeDir string // Where session worktrees are created; empty disables them
+	saveDialog  saveDialogFunc
+	openDialog  openDialogFunc
 	emitter     eventEmitter
 	applyUpdate updateApplier
 	updateInfo  *updater.UpdateInfo
 	workingDir  string
 	getwdFunc   func() (string, error)
+	todos       claude.TodoTracker
+	bus         *events.Bus
+	busOnce     sync.Once
+
+	attachMu sync.Mutex
+	attached map[string]context.CancelFunc // Detach functions by session ID
+
+	runsMu  sync.Mutex
+	runEnds map[string]time.Time // When the app's last run of a session finished
 }
 
 // NewApp creates a new App application struct
@@ -61,9 +217,77 @@ func getClaudeConfigDir(getenv func(string) string) string {
 	return getenv("CLAUDE_CONFIG_DIR")
 }
 
+// sessionCachePath returns where session metadata is cached between runs,
+// or "" to keep the cache in memory when no user cache dir is available.
+func sessionCachePath(cacheDir func() (string, error)) string {
+	return appFilePath(cacheDir, "session-meta.json")
+}
+
+// sessionStorePath returns where user-managed session titles, pins, tags and
+// archive flags are stored, or "" to keep them in memory only.
+func sessionStorePath(configDir func() (string, error)) string {
+	return appFilePath(configDir, "sessions.json")
+}
+
+// worktreeDir returns where worktrees for isolated sessions are created,
+// or "" if there is no user config dir to put them in.
+func worktreeDir(configDir func() (string, error)) string {
+	return appFilePath(configDir, "worktrees")
+}
+
+// changesetDir returns where the changesets of runs are stored, or "" to
+// keep them in memory only.
+func changesetDir(configDir func() (string, error)) string {
+	return appFilePath(configDir, "changesets")
+}
+
+// trashDir returns where deleted sessions are moved, or "" to keep the
+// trash next to the CLI's projects directory.
+func trashDir(configDir func() (string, error)) string {
+	return appFilePath(configDir, "trash")
+}
+
+// checkpointDir returns where snapshots of the files tool calls edit are
+// stored, or "" to disable checkpoints.
+func checkpointDir(configDir func() (string, error)) string {
+	return appFilePath(configDir, "checkpoints")
+}
+
+// appFilePath returns name inside the app's directory under the user
+// directory returned by dirFunc, or "" if there is none.
+func appFilePath(dirFunc func() (string, error), name string) string {
+	dir, err := dirFunc()
+	if err != nil || dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "dogma", name)
+}
+
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
+	a.saveDialog = runtime.SaveFileDialog
+	a.openDialog = runtime.OpenFileDialog
+	a.emitter = &wailsEmitter{ctx: ctx}
+	a.setup(ctx)
+
+	go func() {
+		info, err := updater.CheckForUpdate(ctx)
+		if err != nil {
+			runtime.LogWarning(ctx, "update check failed: "+err.Error())
+			return
+		}
+		if info != nil {
+			a.updateInfo = info
+			events.Publish(a.eventBus(), events.UpdateAvailable, info)
+		}
+	}()
+}
+
+// setup creates the App's services and starts the session watcher. It does
+// not use the Wails runtime, so the desktop app and the headless server
+// share it. The frontend emitter must be set before.
+func (a *App) setup(ctx context.Context) {
 	a.ctx = ctx
 
 	// Read CLAUDE_CONFIG_DIR from environment
@@ -76,21 +300,33 @@ func (a *App) startup(ctx context.Context) {
 
 	// SessionLister: if ConfigDir is set, use it as base path
 	// Otherwise, use empty string (defaults to ~/.claude)
-	a.lister = claude.NewSessionLister(configDir)
-	a.emitter = &wailsEmitter{ctx: ctx}
+	lister := claude.NewSessionLister(configDir)
+	lister.CachePath = sessionCachePath(os.UserCacheDir)
+	lister.Meta = claude.NewMetaStore(sessionStorePath(os.UserConfigDir))
+	lister.TrashDir = trashDir(os.UserConfigDir)
+	a.lister = lister
+	a.manager = lister
+	a.exporter = lister
+	a.bundler = lister
+	a.tailer = lister
+	if dir := checkpointDir(os.UserConfigDir); dir != "" {
+		a.checkpoints = checkpoint.NewStore(dir)
+	}
+	a.changesets = changeset.NewStore(changesetDir(os.UserConfigDir))
+	a.openGit = func(dir string) gitRepo { return git.Open(dir) }
+	a.worktreeDir = worktreeDir(os.UserConfigDir)
+	lister.Worktrees = func(projectPath string) []string {
+		return sessionWorktreePaths(ctx, git.Open(projectPath), a.worktreeDir)
+	}
+	a.searcher = claude.NewSearchIndex(lister)
+	a.refreshSearchIndex()
 	a.applyUpdate = updater.ApplyUpdate
+	a.eventBus()
 
-	go func() {
-		info, err := updater.CheckForUpdate(ctx)
-		if err != nil {
-			runtime.LogWarning(ctx, "update check failed: "+err.Error())
-			return
-		}
-		if info != nil {
-			a.updateInfo = info
-			runtime.EventsEmit(ctx, "app:update-available", info)
-		}
-	}()
+	if dir, err := a.projectDir(); err == nil {
+		watcher := claude.NewSessionWatcher(lister, dir, a.onSessionChanges)
+		go watcher.Run(ctx)
+	}
 }
 
 // SendPrompt sends a prompt to Claude and streams events to the frontend.
@@ -113,6 +349,276 @@ func (a *App) SendPromptWithSessionAndRequestId(prompt string, sessionID string,
 	go a.streamPrompt(prompt, sessionID, requestID)
 }
 
+// SendPromptInWorktree starts a new session in a git worktree of its own,
+// on a new branch off the project's HEAD, so it cannot overwrite the
+// changes of sessions running in the project checkout. It returns the
+// worktree path. Later prompts to the session run in the worktree too;
+// MergeWorktree or RemoveWorktree finish it.
+func (a *App) SendPromptInWorktree(prompt string, requestID string) (string, error) {
+	if a.worktreeDir == "" {
+		return "", fmt.Errorf("worktrees are not available")
+	}
+	repo, err := a.gitRepo()
+	if err != nil {
+		return "", err
+	}
+	dir, err := a.projectDir()
+	if err != nil {
+		return "", err
+	}
+	name, err := newWorktreeName()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(a.worktreeDir, filepath.Base(dir)+"-"+name)
+	if err := repo.AddWorktree(a.ctx, path, sessionBranchPrefix+name); err != nil {
+		return "", err
+	}
+	go a.streamPromptIn(path, prompt, "", requestID)
+	return path, nil
+}
+
+// MergeWorktree merges the branch of the worktree a session runs in into
+// the project's current branch, which must have no uncommitted changes.
+// Uncommitted changes in the worktree are committed first. On success the
+// worktree and its branch are removed and its sessions move to the
+// project, where they can be resumed. A failed merge, e.g. on a conflict,
+// is aborted; the worktree stays, with its changes committed.
+func (a *App) MergeWorktree(sessionID string) error {
+	repo, path, err := a.sessionWorktree(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := repo.MergeWorktree(a.ctx, path, "Changes from session "+sessionID); err != nil {
+		return err
+	}
+	return a.finishWorktree(repo, path)
+}
+
+// RemoveWorktree deletes the worktree a session runs in and its branch,
+// discarding any work not merged. Its sessions move to the project.
+func (a *App) RemoveWorktree(sessionID string) error {
+	repo, path, err := a.sessionWorktree(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := repo.RemoveWorktree(a.ctx, path); err != nil {
+		return err
+	}
+	return a.finishWorktree(repo, path)
+}
+
+// sessionWorktree returns the project's repository and the worktree the
+// session runs in. Only worktrees dogma created for a session qualify, so
+// a worktree of the user's own is never merged or removed.
+func (a *App) sessionWorktree(sessionID string) (gitRepo, string, error) {
+	repo, err := a.gitRepo()
+	if err != nil {
+		return nil, "", err
+	}
+	dir, err := a.projectDir()
+	if err != nil {
+		return nil, "", err
+	}
+	path, err := a.lister.SessionWorkDir(dir, sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if path == dir {
+		return nil, "", fmt.Errorf("session %s does not run in a worktree", sessionID)
+	}
+	worktrees, err := repo.Worktrees(a.ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, wt := range worktrees {
+		if isSessionWorktree(wt, a.worktreeDir) && git.RealPath(wt.Path) == git.RealPath(path) {
+			return repo, path, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%s is not a session worktree", path)
+}
+
+// finishWorktree moves the sessions of a removed worktree to the project
+// and publishes the project's new git state.
+func (a *App) finishWorktree(repo gitRepo, path string) error {
+	dir, err := a.projectDir()
+	if err != nil {
+		return err
+	}
+	if _, err := a.manager.AdoptWorktreeSessions(dir, path); err != nil {
+		return err
+	}
+	a.publishGitChanges(repo, "", "")
+	return nil
+}
+
+// sessionWorkDir returns the worktree a session runs in, or "" for
+// sessions of the project checkout.
+func (a *App) sessionWorkDir(sessionID string) string {
+	if sessionID == "" || a.lister == nil {
+		return ""
+	}
+	dir, err := a.projectDir()
+	if err != nil {
+		return ""
+	}
+	workDir, err := a.lister.SessionWorkDir(dir, sessionID)
+	if err != nil || workDir == dir {
+		return ""
+	}
+	return workDir
+}
+
+// sessionBranchPrefix starts the branch names of session worktrees.
+const sessionBranchPrefix = "dogma/"
+
+// sessionWorktreePaths returns the paths of the repository's session
+// worktrees below baseDir. Directories outside git have none.
+func sessionWorktreePaths(ctx context.Context, repo gitRepo, baseDir string) []string {
+	worktrees, err := repo.Worktrees(ctx)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, wt := range worktrees {
+		if isSessionWorktree(wt, baseDir) {
+			paths = append(paths, wt.Path)
+		}
+	}
+	return paths
+}
+
+// isSessionWorktree reports whether wt is a worktree SendPromptInWorktree
+// created: a linked worktree below baseDir on a dogma/ branch.
+func isSessionWorktree(wt git.Worktree, baseDir string) bool {
+	if wt.Main || baseDir == "" || !strings.HasPrefix(wt.Branch, sessionBranchPrefix) {
+		return false
+	}
+	rel, err := filepath.Rel(git.RealPath(baseDir), git.RealPath(wt.Path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// newWorktreeName returns a short random name for a session worktree and
+// its branch.
+func newWorktreeName() (string, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate worktree name: %w", err)
+	}
+	return "session-" + hex.EncodeToString(b[:]), nil
+}
+
+// EditPrompt re-runs a session from an earlier user prompt with new text.
+// The conversation before messageUUID is copied into a new session, which
+// is resumed with prompt; the original session is left untouched. It returns
+// the new session ID, or "" when messageUUID was the opening prompt and the
+// edited prompt starts a fresh session.
+func (a *App) EditPrompt(sessionID string, messageUUID string, prompt string, requestID string) (string, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return "", err
+	}
+	newID, err := a.manager.ForkSession(dir, sessionID, messageUUID)
+	if err != nil {
+		return "", err
+	}
+	go a.streamPrompt(prompt, newID, requestID)
+	return newID, nil
+}
+
+// AttachSession follows a session of the current project that is run
+// elsewhere, e.g. by the CLI in a terminal. It returns the transcript so
+// far; entries appended later are published as read-only claude:event
+// events until DetachSession is called. Attaching only reads the session
+// file, and prompts to an attached session are refused, so the outside run
+// is never interrupted. Attaching twice is a no-op apart from the returned
+// transcript.
+func (a *App) AttachSession(sessionID string) (claude.Transcript, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.Transcript{}, err
+	}
+	tail, history, err := a.tailer.TailSession(dir, sessionID)
+	if err != nil {
+		return claude.Transcript{}, err
+	}
+
+	a.attachMu.Lock()
+	defer a.attachMu.Unlock()
+	if _, ok := a.attached[sessionID]; ok {
+		return history, nil
+	}
+	if a.attached == nil {
+		a.attached = make(map[string]context.CancelFunc)
+	}
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.attached[sessionID] = cancel
+	bus := a.eventBus()
+	go tail.Run(ctx, func(ev claude.BridgeEvent) {
+		events.Publish(bus, events.BridgeEvents, ev)
+	})
+	return history, nil
+}
+
+// DetachSession stops following an attached session. The outside run is
+// not affected. Detaching a session that is not attached is a no-op.
+func (a *App) DetachSession(sessionID string) {
+	a.attachMu.Lock()
+	cancel, ok := a.attached[sessionID]
+	delete(a.attached, sessionID)
+	a.attachMu.Unlock()
+	if !ok {
+		return
+	}
+	cancel()
+	events.Publish(a.eventBus(), events.SessionDetached, sessionID)
+}
+
+// isAttached reports whether a session is followed read-only.
+func (a *App) isAttached(sessionID string) bool {
+	a.attachMu.Lock()
+	defer a.attachMu.Unlock()
+	_, ok := a.attached[sessionID]
+	return ok
+}
+
+// recentWriteWindow is how long after an outside write to a session file
+// the app assumes another process may still be running the session.
+const recentWriteWindow = 5 * time.Second
+
+// writtenElsewhere reports whether a session file was written within
+// recentWriteWindow, after the app's own last run of the session ended.
+func (a *App) writtenElsewhere(sessionID string) bool {
+	if a.lister == nil {
+		return false
+	}
+	dir, err := a.projectDir()
+	if err != nil {
+		return false
+	}
+	modTime, err := a.lister.SessionModTime(dir, sessionID)
+	if err != nil || time.Since(modTime) >= recentWriteWindow {
+		return false
+	}
+	a.runsMu.Lock()
+	defer a.runsMu.Unlock()
+	return modTime.After(a.runEnds[sessionID])
+}
+
+// recordRunEnd notes that a run of the app has finished writing a session.
+func (a *App) recordRunEnd(sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	a.runsMu.Lock()
+	defer a.runsMu.Unlock()
+	if a.runEnds == nil {
+		a.runEnds = make(map[string]time.Time)
+	}
+	a.runEnds[sessionID] = time.Now()
+}
+
 // CancelPrompt cancels the currently running Claude process.
 func (a *App) CancelPrompt() {
 	a.spawner.Cancel()
@@ -120,50 +626,561 @@ func (a *App) CancelPrompt() {
 
 // ListSessions returns session metadata for the current project.
 func (a *App) ListSessions() ([]claude.SessionInfo, error) {
-	dir := a.workingDir
-	if dir == "" {
-		getwdFn := a.getwdFunc
-		if getwdFn == nil {
-			getwdFn = os.Getwd
+	dir, err := a.projectDir()
+	if err != nil {
+		return nil, err
+	}
+	return a.lister.ListSessions(dir)
+}
+
+// ListSessionsFiltered returns the sessions of the current project whose
+// pins, tags and archive state match filter.
+func (a *App) ListSessionsFiltered(filter claude.SessionFilter) ([]claude.SessionInfo, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return nil, err
+	}
+	return a.manager.ListSessionsFiltered(dir, filter)
+}
+
+// QuerySessions returns one page of the current project's sessions matching
+// the query. Pass the returned NextCursor to fetch the following page.
+func (a *App) QuerySessions(q claude.SessionQuery) (claude.SessionPage, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.SessionPage{}, err
+	}
+	return a.manager.QuerySessions(dir, q)
+}
+
+// ListSessionsByBranch returns the current project's sessions matching the
+// filter, grouped by the git branch they were last active on.
+func (a *App) ListSessionsByBranch(filter claude.SessionFilter) ([]claude.SessionGroup, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return nil, err
+	}
+	return a.manager.ListSessionsByBranch(dir, filter)
+}
+
+// RenameSession sets a custom session title. An empty title clears it.
+func (a *App) RenameSession(sessionID string, title string) error {
+	return a.manager.RenameSession(sessionID, title)
+}
+
+// PinSession pins or unpins a session at the top of the list.
+func (a *App) PinSession(sessionID string, pinned bool) error {
+	return a.manager.SetSessionPinned(sessionID, pinned)
+}
+
+// SetSessionTags replaces the tags of a session.
+func (a *App) SetSessionTags(sessionID string, tags []string) error {
+	return a.manager.SetSessionTags(sessionID, tags)
+}
+
+// ArchiveSession hides a session from the default list, or brings it back.
+func (a *App) ArchiveSession(sessionID string, archived bool) error {
+	return a.manager.SetSessionArchived(sessionID, archived)
+}
+
+// DeleteSession moves a session of the current project to the trash.
+func (a *App) DeleteSession(sessionID string) error {
+	dir, err := a.projectDir()
+	if err != nil {
+		return err
+	}
+	return a.manager.TrashSession(dir, sessionID)
+}
+
+// RestoreSession moves a trashed session back into its project.
+func (a *App) RestoreSession(sessionID string) error {
+	return a.manager.RestoreSession(sessionID)
+}
+
+// ListTrash returns the sessions in the trash.
+func (a *App) ListTrash() ([]claude.TrashedSession, error) {
+	return a.manager.ListTrash()
+}
+
+// ExportSession renders a session of the current project and saves it to a
+// file chosen in a native save dialog. It returns the saved path, or "" if
+// the dialog was cancelled.
+func (a *App) ExportSession(sessionID string, opts claude.ExportOptions) (string, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return "", err
+	}
+	// Render first so a broken session fails before the dialog opens.
+	data, err := a.exporter.ExportSession(dir, sessionID, opts)
+	if err != nil {
+		return "", err
+	}
+
+	ext := claude.ExportExtension(opts.Format)
+	path, err := a.saveDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export session",
+		DefaultFilename: sessionID + ext,
+		Filters:         []runtime.FileFilter{{DisplayName: strings.ToUpper(ext[1:]) + " files", Pattern: "*" + ext}},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("write export: %w", err)
+	}
+	return path, nil
+}
+
+// bundleFilter matches session bundle files in native dialogs.
+var bundleFilter = runtime.FileFilter{DisplayName: "Session bundles", Pattern: "*" + claude.BundleExtension}
+
+// ExportBundle saves a portable bundle of a session in the current project to
+// a file chosen in a native save dialog. It returns the saved path, or "" if
+// the dialog was cancelled.
+func (a *App) ExportBundle(sessionID string) (string, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := a.bundler.ExportBundle(dir, sessionID)
+	if err != nil {
+		return "", err
+	}
+	path, err := a.saveDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export session bundle",
+		DefaultFilename: sessionID + claude.BundleExtension,
+		Filters:         []runtime.FileFilter{bundleFilter},
+	})
+	if err != nil || path == "" {
+		return "", err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("write bundle: %w", err)
+	}
+	return path, nil
+}
+
+// ImportBundle imports a session bundle chosen in a native open dialog into
+// the current project, rewriting its paths to this checkout. With newID the
+// session gets a fresh ID if its own is already taken. Sessions recorded by
+// a newer CLI than the installed one are refused. A cancelled dialog
+// returns an empty SessionInfo.
+func (a *App) ImportBundle(newID bool) (claude.SessionInfo, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.SessionInfo{}, err
+	}
+	path, err := a.openDialog(a.ctx, runtime.OpenDialogOptions{
+		Title:   "Import session bundle",
+		Filters: []runtime.FileFilter{bundleFilter},
+	})
+	if err != nil || path == "" {
+		return claude.SessionInfo{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return claude.SessionInfo{}, fmt.Errorf("read bundle: %w", err)
+	}
+	opts := claude.ImportOptions{NewSessionID: newID}
+	if a.spawner != nil {
+		// Without a known local version the check is skipped
+		if version, err := a.spawner.Version(a.ctx); err == nil {
+			opts.CLIVersion = version
+		} else {
+			log.Printf("[BUNDLE] ERROR: %v", err)
 		}
-		cwd, err := getwdFn()
-		if err != nil {
-			return nil, err
+	}
+	return a.bundler.ImportBundle(data, dir, opts)
+}
+
+// ListProjects returns every project that has sessions in the Claude config directory.
+func (a *App) ListProjects() ([]claude.ProjectInfo, error) {
+	return a.lister.ListProjects()
+}
+
+// LoadSession returns the full transcript of a session in the current project.
+func (a *App) LoadSession(sessionID string) (claude.Transcript, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.Transcript{}, err
+	}
+	return a.lister.LoadSession(dir, sessionID)
+}
+
+// GetSessionBranches returns the branches of a session's conversation,
+// created by edited prompts, retries and sidechains.
+func (a *App) GetSessionBranches(sessionID string) (claude.ConversationTree, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.ConversationTree{}, err
+	}
+	return a.lister.SessionBranches(dir, sessionID)
+}
+
+// LoadBranch returns one branch of a session as a linear transcript ending
+// at leafUUID. An empty leafUUID loads the branch the CLI would resume.
+func (a *App) LoadBranch(sessionID string, leafUUID string) (claude.Transcript, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.Transcript{}, err
+	}
+	return a.lister.LoadBranch(dir, sessionID, leafUUID)
+}
+
+// GetSessionMessages returns up to limit transcript messages of a session
+// starting at offset, for paging through large session files.
+func (a *App) GetSessionMessages(sessionID string, offset int, limit int) (claude.MessagePage, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.MessagePage{}, err
+	}
+	return a.lister.SessionMessages(dir, sessionID, offset, limit)
+}
+
+// LoadOlderMessages returns up to limit messages before the message at index
+// before. Pass a negative before to load the latest messages.
+func (a *App) LoadOlderMessages(sessionID string, before int, limit int) (claude.MessagePage, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.MessagePage{}, err
+	}
+	return a.lister.OlderMessages(dir, sessionID, before, limit)
+}
+
+// JumpToMessage returns a page of messages centered on the message with the given UUID.
+func (a *App) JumpToMessage(sessionID string, uuid string, limit int) (claude.MessagePage, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.MessagePage{}, err
+	}
+	return a.lister.MessagesAround(dir, sessionID, uuid, limit)
+}
+
+// GetSessionStats returns message, tool, file, token and cost statistics
+// of a session in the current project.
+func (a *App) GetSessionStats(sessionID string) (claude.SessionStats, error) {
+	dir, err := a.projectDir()
+	if err != nil {
+		return claude.SessionStats{}, err
+	}
+	return a.lister.SessionStats(dir, sessionID)
+}
+
+// SearchSessions searches the prompts, assistant text and tool inputs of
+// all sessions in every project.
+func (a *App) SearchSessions(query string, filters claude.SearchFilters) ([]claude.SearchHit, error) {
+	return a.searcher.Search(query, filters)
+}
+
+// refreshSearchIndex indexes new and changed session files in the
+// background, so searches find the index up to date instead of reading
+// the files themselves.
+func (a *App) refreshSearchIndex() {
+	if a.searcher == nil {
+		return
+	}
+	go func() {
+		if err := a.searcher.Refresh(); err != nil {
+			log.Printf("[SEARCH] ERROR: %v", err)
 		}
-		dir = cwd
+	}()
+}
+
+// onSessionChanges handles a batch of changes reported by the session
+// watcher.
+func (a *App) onSessionChanges(changes claude.SessionChanges) {
+	a.publishSessionChanges(changes)
+	for _, info := range changes.Updated {
+		a.todos.Delete(info.ID)
 	}
-	return a.lister.ListSessions(dir)
+	for _, id := range changes.Removed {
+		a.todos.Delete(id)
+	}
+	a.refreshSearchIndex()
+}
+
+// publishSessionChanges announces sessions created, continued or deleted
+// outside the app. Empty change kinds are not published.
+func (a *App) publishSessionChanges(changes claude.SessionChanges) {
+	bus := a.eventBus()
+	if len(changes.Added) > 0 {
+		events.Publish(bus, events.SessionsAdded, changes.Added)
+	}
+	if len(changes.Updated) > 0 {
+		events.Publish(bus, events.SessionsUpdated, changes.Updated)
+	}
+	if len(changes.Removed) > 0 {
+		events.Publish(bus, events.SessionsRemoved, changes.Removed)
+	}
+}
+
+// GetTodos returns the current todo list of a session. Sessions not seen
+// during this run are loaded from their JSONL file, so a resumed session
+// shows where the agent left off. Lists of sessions the watcher reports as
+// changed are loaded again, as another process may have updated them.
+func (a *App) GetTodos(sessionID string) ([]claude.TodoItem, error) {
+	if todos, ok := a.todos.Get(sessionID); ok {
+		return todos, nil
+	}
+	dir, err := a.projectDir()
+	if err != nil {
+		return nil, err
+	}
+	todos, err := a.lister.LoadTodos(dir, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	a.todos.Set(sessionID, todos)
+	return todos, nil
+}
+
+// ListCheckpoints returns the file checkpoints of a session, one per turn
+// that edited files, oldest first.
+func (a *App) ListCheckpoints(sessionID string) ([]checkpoint.Checkpoint, error) {
+	if a.checkpoints == nil {
+		return []checkpoint.Checkpoint{}, nil
+	}
+	return a.checkpoints.List(sessionID)
+}
+
+// PreviewRestore returns the changes RestoreCheckpoint would make, with a
+// diff per file, without touching any file.
+func (a *App) PreviewRestore(sessionID string, turn int) ([]checkpoint.Change, error) {
+	if a.checkpoints == nil {
+		return nil, fmt.Errorf("checkpoints are not available")
+	}
+	return a.checkpoints.Preview(sessionID, turn)
+}
+
+// RestoreCheckpoint puts the files of a session back to their state
+// before the given turn. The current state is checkpointed first, so a
+// restore can itself be undone.
+func (a *App) RestoreCheckpoint(sessionID string, turn int) ([]checkpoint.Change, error) {
+	if a.checkpoints == nil {
+		return nil, fmt.Errorf("checkpoints are not available")
+	}
+	return a.checkpoints.Restore(sessionID, turn)
+}
+
+// ListChangesets returns the files changed by each run of a session,
+// oldest run first.
+func (a *App) ListChangesets(sessionID string) ([]changeset.Changeset, error) {
+	if a.changesets == nil {
+		return []changeset.Changeset{}, nil
+	}
+	return a.changesets.List(sessionID)
+}
+
+// GitChanges returns the changed files and diff of the working tree a
+// session runs in: its worktree, or the project checkout. An empty
+// sessionID selects the project checkout.
+func (a *App) GitChanges(sessionID string) (git.Changes, error) {
+	repo, err := a.sessionRepo(sessionID)
+	if err != nil {
+		return git.Changes{}, err
+	}
+	return repo.Changes(a.ctx)
+}
+
+// StageFiles adds the given paths, relative to the root of the session's
+// working tree, to the index and publishes the new state on git:changes.
+func (a *App) StageFiles(sessionID string, paths []string) error {
+	repo, err := a.sessionRepo(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := repo.Stage(a.ctx, paths...); err != nil {
+		return err
+	}
+	a.publishGitChanges(repo, "", sessionID)
+	return nil
+}
+
+// DiscardFile throws away all changes to a file, relative to the root of
+// the session's working tree, and publishes the new state on git:changes.
+func (a *App) DiscardFile(sessionID string, path string) error {
+	repo, err := a.sessionRepo(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := repo.Discard(a.ctx, path); err != nil {
+		return err
+	}
+	a.publishGitChanges(repo, "", sessionID)
+	return nil
+}
+
+// CommitChanges commits the staged changes of the session's working tree
+// with message and returns the commit hash. The new state is published on
+// git:changes.
+func (a *App) CommitChanges(sessionID string, message string) (string, error) {
+	repo, err := a.sessionRepo(sessionID)
+	if err != nil {
+		return "", err
+	}
+	hash, err := repo.Commit(a.ctx, message)
+	if err != nil {
+		return "", err
+	}
+	a.publishGitChanges(repo, "", sessionID)
+	return hash, nil
+}
+
+// gitRepo returns the git working tree of the project directory.
+func (a *App) gitRepo() (gitRepo, error) {
+	if a.openGit == nil {
+		return nil, fmt.Errorf("git is not available")
+	}
+	dir, err := a.projectDir()
+	if err != nil {
+		return nil, err
+	}
+	return a.openGit(dir), nil
+}
+
+// sessionRepo returns the git working tree a session runs in, matching
+// the working tree its git:changes reports describe.
+func (a *App) sessionRepo(sessionID string) (gitRepo, error) {
+	if a.openGit == nil {
+		return nil, fmt.Errorf("git is not available")
+	}
+	if dir := a.sessionWorkDir(sessionID); dir != "" {
+		return a.openGit(dir), nil
+	}
+	return a.gitRepo()
+}
+
+// publishGitChanges publishes the working tree state on git:changes.
+// Projects outside a git repository publish nothing.
+func (a *App) publishGitChanges(repo gitRepo, requestID string, sessionID string) {
+	changes, err := repo.Changes(a.ctx)
+	if err != nil {
+		if !errors.Is(err, git.ErrNotRepository) {
+			log.Printf("[GIT] ERROR: %v", err)
+		}
+		return
+	}
+	events.Publish(a.eventBus(), events.GitChanges, events.GitReport{
+		RequestID: requestID,
+		SessionID: sessionID,
+		Changes:   changes,
+	})
+}
+
+// eventBus returns the App's event bus, creating it on first use.
+// The frontend emitter, if set, is subscribed to the frontend topics.
+func (a *App) eventBus() *events.Bus {
+	a.busOnce.Do(func() {
+		if a.bus == nil {
+			a.bus = events.NewBus()
+		}
+		if a.emitter != nil {
+			a.bus.Subscribe(emitterSubscriber{emitter: a.emitter}, frontendTopics)
+		}
+	})
+	return a.bus
+}
+
+// projectDir returns the working directory whose sessions are shown.
+func (a *App) projectDir() (string, error) {
+	if a.workingDir != "" {
+		return a.workingDir, nil
+	}
+	getwdFn := a.getwdFunc
+	if getwdFn == nil {
+		getwdFn = os.Getwd
+	}
+	return getwdFn()
 }
 
 // ApplyUpdate downloads and applies the pending update.
 func (a *App) ApplyUpdate() {
+	bus := a.eventBus()
 	if a.updateInfo == nil {
-		a.emitter.Emit("app:update-error", "no update available")
+		events.Publish(bus, events.UpdateFailed, "no update available")
 		return
 	}
 
 	go func() {
 		err := a.applyUpdate(a.ctx, a.updateInfo)
 		if err != nil {
-			a.emitter.Emit("app:update-error", err.Error())
+			events.Publish(bus, events.UpdateFailed, err.Error())
 			return
 		}
-		a.emitter.Emit("app:update-applied", a.updateInfo)
+		events.Publish(bus, events.UpdateApplied, a.updateInfo)
 	}()
 }
 
 func (a *App) streamPrompt(prompt string, sessionID string, requestID string) {
+	a.streamPromptIn(a.sessionWorkDir(sessionID), prompt, sessionID, requestID)
+}
+
+// streamPromptIn runs a prompt in dir, a session worktree, or in the
+// project checkout if dir is empty.
+func (a *App) streamPromptIn(dir string, prompt string, sessionID string, requestID string) {
+	bus := a.eventBus()
+
+	// Resuming a session another process is running would interleave both
+	// runs in one file.
+	// Sessions the app does not follow are caught by a recent write that
+	// none of its own runs made.
+	var msg string
+	switch {
+	case sessionID == "":
+	case a.isAttached(sessionID):
+		msg = fmt.Sprintf("session %s is attached read-only; detach it before sending prompts", sessionID)
+	case a.writtenElsewhere(sessionID):
+		msg = fmt.Sprintf("session %s was just written by another process; attach it to follow the run, or wait until it is idle", sessionID)
+	}
+	if msg != "" {
+		events.Publish(bus, events.Errors, events.RunError{RequestID: requestID, SessionID: sessionID, Message: msg})
+		events.Publish(bus, events.RunFinished, events.RunEnd{RequestID: requestID, SessionID: sessionID, Error: msg})
+		return
+	}
+
 	// Track session ID to add to all events (assistant events don't have it)
 	var currentSessionID string
 	if sessionID != "" {
 		currentSessionID = sessionID
 	}
 
+	events.Publish(bus, events.RunStarted, events.RunStart{
+		RequestID: requestID,
+		SessionID: sessionID,
+		Prompt:    prompt,
+	})
+
+	workDir := dir
+	if workDir == "" {
+		workDir, _ = a.projectDir()
+	}
+	var recorder *checkpoint.Recorder
+	if a.checkpoints != nil && workDir != "" {
+		recorder = a.checkpoints.NewRecorder(workDir, sessionID, prompt)
+	}
+	var tracker *changeset.Tracker
+	if a.changesets != nil && workDir != "" {
+		tracker = changeset.Start(workDir)
+		if recorder != nil {
+			recorder.ExistedBefore = tracker.ExistedBefore
+		}
+	}
+
 	handler := func(event claude.StreamEvent) {
 		parsed, err := claude.ParseEvent(event.Payload)
 		if err != nil || parsed.Type == "" {
 			return
 		}
+		if tracker != nil {
+			tracker.Observe(parsed)
+		}
+		// Snapshot edit targets as the tool calls are announced. The CLI
+		// does not wait for this, so a snapshot may already hold the edit.
+		if recorder != nil {
+			if err := recorder.Observe(parsed); err != nil {
+				log.Printf("[CHECKPOINT] ERROR: %v", err)
+			}
+		}
 		bridge := claude.ToBridgeEvent(parsed)
 
 		// Capture session ID from system event
@@ -177,19 +1194,70 @@ func (a *App) streamPrompt(prompt string, sessionID string, requestID string) {
 		// Add request ID to ALL events for client-side filtering
 		bridge.RequestID = requestID
 
-		a.emitter.Emit("claude:event", bridge)
+		events.Publish(bus, events.BridgeEvents, bridge)
+
+		if todos, ok := claude.TodosFromEvent(parsed); ok && currentSessionID != "" {
+			if a.todos.Set(currentSessionID, todos) {
+				todos, _ = a.todos.Get(currentSessionID)
+				events.Publish(bus, events.Todos, claude.TodoUpdate{
+					SessionID: currentSessionID,
+					RequestID: requestID,
+					Todos:     todos,
+				})
+			}
+		}
+
+		if parsed.Result != nil {
+			report := events.UsageReport{
+				RequestID:    requestID,
+				SessionID:    currentSessionID,
+				TotalCostUSD: parsed.Result.TotalCostUSD,
+				DurationMs:   parsed.Result.DurationMs,
+				NumTurns:     parsed.Result.NumTurns,
+			}
+			if parsed.Result.Usage != nil {
+				report.Usage = *parsed.Result.Usage
+			}
+			events.Publish(bus, events.Usage, report)
+
+			if a.openGit != nil && workDir != "" {
+				a.publishGitChanges(a.openGit(workDir), requestID, currentSessionID)
+			}
+		}
 	}
 
 	var err error
-	if sessionID == "" {
+	if dir != "" {
+		err = a.spawner.SendPromptInDir(a.ctx, prompt, sessionID, dir, handler)
+	} else if sessionID == "" {
 		err = a.spawner.SendPrompt(a.ctx, prompt, handler)
 	} else {
 		err = a.spawner.SendPromptWithSession(a.ctx, prompt, sessionID, handler)
 	}
 
+	a.recordRunEnd(currentSessionID)
+
+	end := events.RunEnd{RequestID: requestID, SessionID: currentSessionID}
+	if tracker != nil {
+		cs := tracker.Finish()
+		cs.RequestID = requestID
+		if cs.SessionID == "" {
+			cs.SessionID = currentSessionID
+		}
+		end.Changes = &cs
+		if cs.SessionID != "" {
+			if err := a.changesets.Add(cs); err != nil {
+				log.Printf("[CHANGESET] ERROR: %v", err)
+			}
+		}
+	}
 	if err != nil {
-		a.emitter.Emit("claude:error", err.Error())
-	} else {
-		a.emitter.Emit("claude:done", nil)
+		end.Error = err.Error()
+		events.Publish(bus, events.Errors, events.RunError{
+			RequestID: requestID,
+			SessionID: currentSessionID,
+			Message:   err.Error(),
+		})
 	}
+	events.Publish(bus, events.RunFinished, end)
 }
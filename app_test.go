@@ -4,12 +4,21 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/Marcel-Bich/dogma/internal/changeset"
+	"github.com/Marcel-Bich/dogma/internal/checkpoint"
 	"github.com/Marcel-Bich/dogma/internal/claude"
+	"github.com/Marcel-Bich/dogma/internal/events"
+	"github.com/Marcel-Bich/dogma/internal/git"
 	"github.com/Marcel-Bich/dogma/internal/updater"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // --- Test doubles ---
@@ -18,6 +27,8 @@ type mockSpawner struct {
 	mu             sync.Mutex
 	sendPromptFn   func(ctx context.Context, prompt string, handler claude.EventHandler) error
 	sendWithSessFn func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error
+	sendInDirFn    func(ctx context.Context, prompt string, sessionID string, dir string, handler claude.EventHandler) error
+	version        string
 	cancelCalled   bool
 }
 
@@ -35,6 +46,20 @@ func (m *mockSpawner) SendPromptWithSession(ctx context.Context, prompt string,
 	return nil
 }
 
+func (m *mockSpawner) SendPromptInDir(ctx context.Context, prompt string, sessionID string, dir string, handler claude.EventHandler) error {
+	if m.sendInDirFn != nil {
+		return m.sendInDirFn(ctx, prompt, sessionID, dir, handler)
+	}
+	return nil
+}
+
+func (m *mockSpawner) Version(ctx context.Context) (string, error) {
+	if m.version == "" {
+		return "", errors.New("claude not found")
+	}
+	return m.version, nil
+}
+
 func (m *mockSpawner) Cancel() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -377,6 +402,65 @@ func TestSendPromptWithSession_LaunchesGoroutine(t *testing.T) {
 	}
 }
 
+func TestEditPrompt_ResumesFork(t *testing.T) {
+	resumed := make(chan string, 1)
+	spawner := &mockSpawner{
+		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
+			if prompt != "Try B" {
+				t.Errorf("expected prompt 'Try B', got %q", prompt)
+			}
+			resumed <- sessionID
+			return nil
+		},
+	}
+	manager := &mockManager{}
+	app := &App{ctx: context.Background(), spawner: spawner, emitter: &mockEmitter{}, manager: manager, workingDir: "/my/project"}
+
+	newID, err := app.EditPrompt("s1", "u2", "Try B", "req-1")
+	if err != nil || newID != "forked" {
+		t.Fatalf("EditPrompt() = %q, %v", newID, err)
+	}
+	if got := <-resumed; got != "forked" {
+		t.Errorf("resumed session %q, want forked", got)
+	}
+	if len(manager.calls) != 1 || manager.calls[0] != "fork /my/project s1 u2" {
+		t.Errorf("unexpected calls: %v", manager.calls)
+	}
+}
+
+func TestEditPrompt_OpeningPromptStartsNewSession(t *testing.T) {
+	started := make(chan string, 1)
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			started <- prompt
+			return nil
+		},
+	}
+	app := &App{ctx: context.Background(), spawner: spawner, emitter: &mockEmitter{}, manager: &mockManager{}, workingDir: "/my/project"}
+
+	newID, err := app.EditPrompt("s1", "first", "Start over", "")
+	if err != nil || newID != "" {
+		t.Fatalf("EditPrompt() = %q, %v", newID, err)
+	}
+	if got := <-started; got != "Start over" {
+		t.Errorf("started with %q", got)
+	}
+}
+
+func TestEditPrompt_ForkError(t *testing.T) {
+	spawner := &mockSpawner{
+		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
+			t.Error("prompt sent despite fork error")
+			return nil
+		},
+	}
+	app := &App{ctx: context.Background(), spawner: spawner, manager: &mockManager{err: errors.New("boom")}, workingDir: "/my/project"}
+
+	if _, err := app.EditPrompt("s1", "u2", "Try B", ""); err == nil {
+		t.Error("EditPrompt() error = nil, want fork error")
+	}
+}
+
 // --- ApplyUpdate tests ---
 
 func TestApplyUpdate_NoUpdateInfo(t *testing.T) {
@@ -470,6 +554,17 @@ func TestApplyUpdate_Error(t *testing.T) {
 
 type mockSessionLister struct {
 	listSessionsFn func(projectPath string) ([]claude.SessionInfo, error)
+	listProjectsFn func() ([]claude.ProjectInfo, error)
+	loadTodosFn    func(projectPath string, sessionID string) ([]claude.TodoItem, error)
+	loadSessionFn  func(projectPath string, sessionID string) (claude.Transcript, error)
+	messagesFn     func(projectPath string, sessionID string, offset int, limit int) (claude.MessagePage, error)
+	olderFn        func(projectPath string, sessionID string, before int, limit int) (claude.MessagePage, error)
+	aroundFn       func(projectPath string, sessionID string, uuid string, limit int) (claude.MessagePage, error)
+	statsFn        func(projectPath string, sessionID string) (claude.SessionStats, error)
+	branchesFn     func(projectPath string, sessionID string) (claude.ConversationTree, error)
+	loadBranchFn   func(projectPath string, sessionID string, leafUUID string) (claude.Transcript, error)
+	workDirFn      func(projectPath string, sessionID string) (string, error)
+	modTimeFn      func(projectPath string, sessionID string) (time.Time, error)
 }
 
 func (m *mockSessionLister) ListSessions(projectPath string) ([]claude.SessionInfo, error) {
@@ -479,6 +574,83 @@ func (m *mockSessionLister) ListSessions(projectPath string) ([]claude.SessionIn
 	return nil, nil
 }
 
+func (m *mockSessionLister) ListProjects() ([]claude.ProjectInfo, error) {
+	if m.listProjectsFn != nil {
+		return m.listProjectsFn()
+	}
+	return []claude.ProjectInfo{}, nil
+}
+
+func (m *mockSessionLister) LoadTodos(projectPath string, sessionID string) ([]claude.TodoItem, error) {
+	if m.loadTodosFn != nil {
+		return m.loadTodosFn(projectPath, sessionID)
+	}
+	return []claude.TodoItem{}, nil
+}
+
+func (m *mockSessionLister) LoadSession(projectPath string, sessionID string) (claude.Transcript, error) {
+	if m.loadSessionFn != nil {
+		return m.loadSessionFn(projectPath, sessionID)
+	}
+	return claude.Transcript{SessionID: sessionID}, nil
+}
+
+func (m *mockSessionLister) SessionMessages(projectPath string, sessionID string, offset int, limit int) (claude.MessagePage, error) {
+	if m.messagesFn != nil {
+		return m.messagesFn(projectPath, sessionID, offset, limit)
+	}
+	return claude.MessagePage{SessionID: sessionID}, nil
+}
+
+func (m *mockSessionLister) OlderMessages(projectPath string, sessionID string, before int, limit int) (claude.MessagePage, error) {
+	if m.olderFn != nil {
+		return m.olderFn(projectPath, sessionID, before, limit)
+	}
+	return claude.MessagePage{SessionID: sessionID}, nil
+}
+
+func (m *mockSessionLister) MessagesAround(projectPath string, sessionID string, uuid string, limit int) (claude.MessagePage, error) {
+	if m.aroundFn != nil {
+		return m.aroundFn(projectPath, sessionID, uuid, limit)
+	}
+	return claude.MessagePage{SessionID: sessionID}, nil
+}
+
+func (m *mockSessionLister) SessionStats(projectPath string, sessionID string) (claude.SessionStats, error) {
+	if m.statsFn != nil {
+		return m.statsFn(projectPath, sessionID)
+	}
+	return claude.SessionStats{}, nil
+}
+
+func (m *mockSessionLister) SessionBranches(projectPath string, sessionID string) (claude.ConversationTree, error) {
+	if m.branchesFn != nil {
+		return m.branchesFn(projectPath, sessionID)
+	}
+	return claude.ConversationTree{}, nil
+}
+
+func (m *mockSessionLister) SessionWorkDir(projectPath string, sessionID string) (string, error) {
+	if m.workDirFn != nil {
+		return m.workDirFn(projectPath, sessionID)
+	}
+	return projectPath, nil
+}
+
+func (m *mockSessionLister) SessionModTime(projectPath string, sessionID string) (time.Time, error) {
+	if m.modTimeFn != nil {
+		return m.modTimeFn(projectPath, sessionID)
+	}
+	return time.Time{}, os.ErrNotExist
+}
+
+func (m *mockSessionLister) LoadBranch(projectPath string, sessionID string, leafUUID string) (claude.Transcript, error) {
+	if m.loadBranchFn != nil {
+		return m.loadBranchFn(projectPath, sessionID, leafUUID)
+	}
+	return claude.Transcript{}, nil
+}
+
 // --- ListSessions tests ---
 
 func TestListSessions_CallsListerWithCorrectPath(t *testing.T) {
@@ -628,129 +800,1551 @@ func TestListSessions_GetwdError(t *testing.T) {
 	}
 }
 
-// --- Request ID tests ---
+// --- ListProjects tests ---
 
-func TestStreamPrompt_AddsRequestIDToAllEvents(t *testing.T) {
-	emitter := &mockEmitter{}
-	spawner := &mockSpawner{
-		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
-			handler(claude.StreamEvent{Payload: []byte(`{"type":"system","session_id":"s1"}`)})
-			handler(claude.StreamEvent{Payload: []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}]}}`)})
-			return nil
+func TestListProjects_ReturnsListerResult(t *testing.T) {
+	lister := &mockSessionLister{
+		listProjectsFn: func() ([]claude.ProjectInfo, error) {
+			return []claude.ProjectInfo{{Name: "-home-user-project", Path: "/home/user/project", SessionCount: 3}}, nil
+		},
+	}
+
+	app := &App{lister: lister}
+
+	projects, err := app.ListProjects()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Path != "/home/user/project" || projects[0].SessionCount != 3 {
+		t.Errorf("unexpected projects: %+v", projects)
+	}
+}
+
+func TestListProjects_PropagatesErrors(t *testing.T) {
+	lister := &mockSessionLister{
+		listProjectsFn: func() ([]claude.ProjectInfo, error) {
+			return nil, errors.New("read failed")
+		},
+	}
+
+	app := &App{lister: lister}
+
+	if _, err := app.ListProjects(); err == nil || err.Error() != "read failed" {
+		t.Errorf("expected 'read failed', got %v", err)
+	}
+}
+
+// --- LoadSession tests ---
+
+func TestLoadSession_CallsListerWithProjectAndSession(t *testing.T) {
+	var calledPath, calledID string
+	lister := &mockSessionLister{
+		loadSessionFn: func(projectPath string, sessionID string) (claude.Transcript, error) {
+			calledPath, calledID = projectPath, sessionID
+			return claude.Transcript{
+				SessionID: sessionID,
+				Events:    []claude.BridgeEvent{{Type: "user", Text: "hi"}},
+			}, nil
 		},
 	}
 
 	app := &App{
-		ctx:     context.Background(),
-		spawner: spawner,
-		emitter: emitter,
+		lister:     lister,
+		workingDir: "/home/user/project",
 	}
 
-	app.streamPrompt("hello", "", "req-123")
+	tr, err := app.LoadSession("sess-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calledPath != "/home/user/project" || calledID != "sess-1" {
+		t.Errorf("lister called with %q, %q", calledPath, calledID)
+	}
+	if len(tr.Events) != 1 || tr.Events[0].Text != "hi" {
+		t.Errorf("unexpected transcript: %+v", tr)
+	}
+}
 
-	events := emitter.getEvents()
-	if len(events) != 3 {
-		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+func TestLoadSession_GetwdError(t *testing.T) {
+	app := &App{
+		lister: &mockSessionLister{},
+		getwdFunc: func() (string, error) {
+			return "", errors.New("getwd failed")
+		},
 	}
 
-	// Check that all claude:event events have the request ID
-	for i, ev := range events {
-		if ev.name == "claude:event" {
-			bridge, ok := ev.data[0].(claude.BridgeEvent)
-			if !ok {
-				t.Fatalf("event[%d]: expected BridgeEvent, got %T", i, ev.data[0])
-			}
-			if bridge.RequestID != "req-123" {
-				t.Errorf("event[%d]: expected RequestID 'req-123', got %q", i, bridge.RequestID)
-			}
-		}
+	if _, err := app.LoadSession("sess-1"); err == nil || err.Error() != "getwd failed" {
+		t.Errorf("expected 'getwd failed', got %v", err)
 	}
 }
 
-func TestSendPromptWithRequestId_LaunchesGoroutine(t *testing.T) {
-	emitter := &mockEmitter{}
-	spawner := &mockSpawner{
-		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
-			if prompt != "test" {
-				t.Errorf("expected prompt 'test', got %q", prompt)
-			}
-			return nil
+// --- Paginated transcript tests ---
+
+func TestPaginationBindings_ForwardArguments(t *testing.T) {
+	var calls []string
+	lister := &mockSessionLister{
+		messagesFn: func(projectPath string, sessionID string, offset int, limit int) (claude.MessagePage, error) {
+			calls = append(calls, fmt.Sprintf("messages %s %s %d %d", projectPath, sessionID, offset, limit))
+			return claude.MessagePage{SessionID: sessionID, Offset: offset}, nil
+		},
+		olderFn: func(projectPath string, sessionID string, before int, limit int) (claude.MessagePage, error) {
+			calls = append(calls, fmt.Sprintf("older %s %s %d %d", projectPath, sessionID, before, limit))
+			return claude.MessagePage{SessionID: sessionID}, nil
+		},
+		aroundFn: func(projectPath string, sessionID string, uuid string, limit int) (claude.MessagePage, error) {
+			calls = append(calls, fmt.Sprintf("around %s %s %s %d", projectPath, sessionID, uuid, limit))
+			return claude.MessagePage{SessionID: sessionID}, nil
 		},
 	}
 
 	app := &App{
-		ctx:     context.Background(),
-		spawner: spawner,
-		emitter: emitter,
+		lister:     lister,
+		workingDir: "/p",
 	}
 
-	app.SendPromptWithRequestId("test", "req-456")
+	if _, err := app.GetSessionMessages("s1", 10, 20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.LoadOlderMessages("s1", -1, 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.JumpToMessage("s1", "uuid-1", 40); err != nil {
+		t.Fatal(err)
+	}
 
-	// Wait for goroutine to complete
-	time.Sleep(50 * time.Millisecond)
+	want := []string{"messages /p s1 10 20", "older /p s1 -1 30", "around /p s1 uuid-1 40"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("call[%d] = %q, want %q", i, calls[i], want[i])
+		}
+	}
+}
 
-	events := emitter.getEvents()
-	if len(events) != 1 {
-		t.Fatalf("expected 1 event, got %d", len(events))
+func TestPaginationBindings_GetwdError(t *testing.T) {
+	app := &App{
+		lister: &mockSessionLister{},
+		getwdFunc: func() (string, error) {
+			return "", errors.New("getwd failed")
+		},
 	}
-	if events[0].name != "claude:done" {
-		t.Errorf("expected 'claude:done', got %q", events[0].name)
+
+	if _, err := app.GetSessionMessages("s1", 0, 10); err == nil {
+		t.Error("GetSessionMessages: expected error")
+	}
+	if _, err := app.LoadOlderMessages("s1", -1, 10); err == nil {
+		t.Error("LoadOlderMessages: expected error")
+	}
+	if _, err := app.JumpToMessage("s1", "u", 10); err == nil {
+		t.Error("JumpToMessage: expected error")
 	}
 }
 
-func TestSendPromptWithSessionAndRequestId_LaunchesGoroutine(t *testing.T) {
+// --- SearchSessions tests ---
+
+type mockSearcher struct {
+	searchFn  func(query string, filters claude.SearchFilters) ([]claude.SearchHit, error)
+	refreshed chan struct{}
+}
+
+func (m *mockSearcher) Search(query string, filters claude.SearchFilters) ([]claude.SearchHit, error) {
+	return m.searchFn(query, filters)
+}
+
+func (m *mockSearcher) Refresh() error {
+	if m.refreshed != nil {
+		m.refreshed <- struct{}{}
+	}
+	return nil
+}
+
+func TestOnSessionChanges_RefreshesSearchIndex(t *testing.T) {
+	searcher := &mockSearcher{refreshed: make(chan struct{}, 1)}
 	emitter := &mockEmitter{}
-	spawner := &mockSpawner{
-		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
-			if prompt != "continue" {
-				t.Errorf("expected prompt 'continue', got %q", prompt)
+	app := &App{searcher: searcher, emitter: emitter}
+
+	app.onSessionChanges(claude.SessionChanges{Added: []claude.SessionInfo{{ID: "s1"}}})
+	select {
+	case <-searcher.refreshed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("search index not refreshed after session changes")
+	}
+	if evts := emitter.getEvents(); len(evts) != 1 || evts[0].name != "sessions:added" {
+		t.Errorf("emitted = %+v, want sessions:added", evts)
+	}
+
+	// Without a searcher there is nothing to refresh
+	(&App{emitter: &mockEmitter{}}).onSessionChanges(claude.SessionChanges{})
+}
+
+func TestSearchSessions_ForwardsQueryAndFilters(t *testing.T) {
+	searcher := &mockSearcher{
+		searchFn: func(query string, filters claude.SearchFilters) ([]claude.SearchHit, error) {
+			if query != "migration bug" {
+				t.Errorf("expected query 'migration bug', got %q", query)
 			}
-			if sessionID != "sess-abc" {
-				t.Errorf("expected sessionID 'sess-abc', got %q", sessionID)
+			if filters.Project != "-p" || filters.Limit != 5 {
+				t.Errorf("unexpected filters: %+v", filters)
 			}
-			return nil
+			return []claude.SearchHit{{SessionID: "s1"}}, nil
 		},
 	}
 
-	app := &App{
-		ctx:     context.Background(),
-		spawner: spawner,
-		emitter: emitter,
+	app := &App{searcher: searcher}
+
+	hits, err := app.SearchSessions("migration bug", claude.SearchFilters{Project: "-p", Limit: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
+	if len(hits) != 1 || hits[0].SessionID != "s1" {
+		t.Errorf("unexpected hits: %+v", hits)
+	}
+}
 
-	app.SendPromptWithSessionAndRequestId("continue", "sess-abc", "req-789")
+// --- Session management tests ---
 
-	// Wait for goroutine to complete
-	time.Sleep(50 * time.Millisecond)
+type mockManager struct {
+	filteredFn func(projectPath string, filter claude.SessionFilter) ([]claude.SessionInfo, error)
+	queryFn    func(projectPath string, q claude.SessionQuery) (claude.SessionPage, error)
+	calls      []string
+	err        error
+}
 
-	events := emitter.getEvents()
-	if len(events) != 1 {
-		t.Fatalf("expected 1 event, got %d", len(events))
+func (m *mockManager) ListSessionsFiltered(projectPath string, filter claude.SessionFilter) ([]claude.SessionInfo, error) {
+	return m.filteredFn(projectPath, filter)
+}
+
+func (m *mockManager) QuerySessions(projectPath string, q claude.SessionQuery) (claude.SessionPage, error) {
+	return m.queryFn(projectPath, q)
+}
+
+func (m *mockManager) ListSessionsByBranch(projectPath string, filter claude.SessionFilter) ([]claude.SessionGroup, error) {
+	sessions, err := m.filteredFn(projectPath, filter)
+	return claude.GroupSessionsByBranch(sessions), err
+}
+
+func (m *mockManager) RenameSession(sessionID string, title string) error {
+	m.calls = append(m.calls, "rename "+sessionID+" "+title)
+	return m.err
+}
+
+func (m *mockManager) SetSessionPinned(sessionID string, pinned bool) error {
+	m.calls = append(m.calls, fmt.Sprintf("pin %s %v", sessionID, pinned))
+	return m.err
+}
+
+func (m *mockManager) SetSessionTags(sessionID string, tags []string) error {
+	m.calls = append(m.calls, fmt.Sprintf("tags %s %v", sessionID, tags))
+	return m.err
+}
+
+func (m *mockManager) SetSessionArchived(sessionID string, archived bool) error {
+	m.calls = append(m.calls, fmt.Sprintf("archive %s %v", sessionID, archived))
+	return m.err
+}
+
+func (m *mockManager) TrashSession(projectPath string, sessionID string) error {
+	m.calls = append(m.calls, "trash "+projectPath+" "+sessionID)
+	return m.err
+}
+
+func (m *mockManager) RestoreSession(sessionID string) error {
+	m.calls = append(m.calls, "restore "+sessionID)
+	return m.err
+}
+
+func (m *mockManager) AdoptWorktreeSessions(projectPath string, worktreePath string) ([]string, error) {
+	m.calls = append(m.calls, "adopt "+projectPath+" "+worktreePath)
+	return []string{"s1"}, m.err
+}
+
+func (m *mockManager) ForkSession(projectPath string, sessionID string, messageUUID string) (string, error) {
+	m.calls = append(m.calls, "fork "+projectPath+" "+sessionID+" "+messageUUID)
+	if m.err != nil {
+		return "", m.err
 	}
-	if events[0].name != "claude:done" {
-		t.Errorf("expected 'claude:done', got %q", events[0].name)
+	if messageUUID == "first" {
+		return "", nil
 	}
+	return "forked", nil
 }
 
-// --- GetClaudeConfigDir tests ---
+func (m *mockManager) ListTrash() ([]claude.TrashedSession, error) {
+	m.calls = append(m.calls, "list trash")
+	return []claude.TrashedSession{{Project: "-p"}}, m.err
+}
 
-func TestGetClaudeConfigDir_ReturnsEnvValue(t *testing.T) {
-	result := getClaudeConfigDir(func(key string) string {
-		if key == "CLAUDE_CONFIG_DIR" {
-			return "/custom/claude/dir"
-		}
-		return ""
-	})
-	if result != "/custom/claude/dir" {
-		t.Errorf("expected '/custom/claude/dir', got %q", result)
+func TestListSessionsFiltered_UsesProjectDir(t *testing.T) {
+	manager := &mockManager{
+		filteredFn: func(projectPath string, filter claude.SessionFilter) ([]claude.SessionInfo, error) {
+			if projectPath != "/my/project" {
+				t.Errorf("expected project '/my/project', got %q", projectPath)
+			}
+			if !filter.PinnedOnly || len(filter.Tags) != 1 || filter.Tags[0] != "bug" {
+				t.Errorf("unexpected filter: %+v", filter)
+			}
+			return []claude.SessionInfo{{ID: "s1", Pinned: true}}, nil
+		},
+	}
+	app := &App{manager: manager, workingDir: "/my/project"}
+
+	sessions, err := app.ListSessionsFiltered(claude.SessionFilter{PinnedOnly: true, Tags: []string{"bug"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "s1" {
+		t.Errorf("unexpected sessions: %+v", sessions)
 	}
 }
 
-func TestGetClaudeConfigDir_ReturnsEmptyWhenNotSet(t *testing.T) {
-	result := getClaudeConfigDir(func(key string) string {
-		return ""
-	})
-	if result != "" {
-		t.Errorf("expected empty string, got %q", result)
+func TestQuerySessions_UsesProjectDir(t *testing.T) {
+	manager := &mockManager{
+		queryFn: func(projectPath string, q claude.SessionQuery) (claude.SessionPage, error) {
+			if projectPath != "/my/project" {
+				t.Errorf("expected project '/my/project', got %q", projectPath)
+			}
+			if q.SortBy != claude.SortByLastActivity || q.Limit != 20 || q.Cursor != "next" {
+				t.Errorf("unexpected query: %+v", q)
+			}
+			return claude.SessionPage{Sessions: []claude.SessionInfo{{ID: "s1"}}, Total: 21, NextCursor: "after-s1"}, nil
+		},
+	}
+	app := &App{manager: manager, workingDir: "/my/project"}
+
+	page, err := app.QuerySessions(claude.SessionQuery{SortBy: claude.SortByLastActivity, Limit: 20, Cursor: "next"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Sessions) != 1 || page.Total != 21 || page.NextCursor != "after-s1" {
+		t.Errorf("unexpected page: %+v", page)
+	}
+}
+
+func TestListSessionsByBranch_UsesProjectDir(t *testing.T) {
+	manager := &mockManager{
+		filteredFn: func(projectPath string, filter claude.SessionFilter) ([]claude.SessionInfo, error) {
+			if projectPath != "/my/project" {
+				t.Errorf("expected project '/my/project', got %q", projectPath)
+			}
+			return []claude.SessionInfo{{ID: "s1", GitBranch: "main"}, {ID: "s2", GitBranch: "fix"}}, nil
+		},
+	}
+	app := &App{manager: manager, workingDir: "/my/project"}
+
+	groups, err := app.ListSessionsByBranch(claude.SessionFilter{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(groups) != 2 || groups[0].Branch != "main" || groups[1].Sessions[0].ID != "s2" {
+		t.Errorf("unexpected groups: %+v", groups)
+	}
+}
+
+func TestSessionManagementBindings_Forward(t *testing.T) {
+	manager := &mockManager{}
+	app := &App{manager: manager, workingDir: "/my/project"}
+
+	app.RenameSession("s1", "Fix login")
+	app.PinSession("s1", true)
+	app.SetSessionTags("s1", []string{"a", "b"})
+	app.ArchiveSession("s1", true)
+	app.DeleteSession("s1")
+	app.RestoreSession("s1")
+	if trash, _ := app.ListTrash(); len(trash) != 1 {
+		t.Errorf("unexpected trash: %+v", trash)
+	}
+
+	want := []string{
+		"rename s1 Fix login",
+		"pin s1 true",
+		"tags s1 [a b]",
+		"archive s1 true",
+		"trash /my/project s1",
+		"restore s1",
+		"list trash",
+	}
+	if len(manager.calls) != len(want) {
+		t.Fatalf("expected calls %v, got %v", want, manager.calls)
+	}
+	for i := range want {
+		if manager.calls[i] != want[i] {
+			t.Errorf("call[%d] = %q, want %q", i, manager.calls[i], want[i])
+		}
+	}
+}
+
+func TestDeleteSession_ReturnsError(t *testing.T) {
+	app := &App{manager: &mockManager{err: errors.New("busy")}, workingDir: "/my/project"}
+
+	if err := app.DeleteSession("s1"); err == nil || err.Error() != "busy" {
+		t.Errorf("expected error 'busy', got %v", err)
+	}
+}
+
+func TestSessionStorePath(t *testing.T) {
+	got := sessionStorePath(func() (string, error) { return "/config", nil })
+	if want := filepath.Join("/config", "dogma", "sessions.json"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+// --- ExportSession tests ---
+
+type mockExporter struct {
+	exportFn func(projectPath string, sessionID string, opts claude.ExportOptions) ([]byte, error)
+}
+
+func (m *mockExporter) ExportSession(projectPath string, sessionID string, opts claude.ExportOptions) ([]byte, error) {
+	return m.exportFn(projectPath, sessionID, opts)
+}
+
+func TestExportSession_WritesChosenFile(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "out.html")
+	exporter := &mockExporter{
+		exportFn: func(projectPath string, sessionID string, opts claude.ExportOptions) ([]byte, error) {
+			if projectPath != "/my/project" || sessionID != "s1" || opts.Format != claude.ExportHTML {
+				t.Errorf("unexpected export call: %q %q %+v", projectPath, sessionID, opts)
+			}
+			return []byte("<html>"), nil
+		},
+	}
+	app := &App{
+		ctx:        context.Background(),
+		exporter:   exporter,
+		workingDir: "/my/project",
+		saveDialog: func(ctx context.Context, opts runtime.SaveDialogOptions) (string, error) {
+			if opts.DefaultFilename != "s1.html" {
+				t.Errorf("expected default filename 's1.html', got %q", opts.DefaultFilename)
+			}
+			if len(opts.Filters) != 1 || opts.Filters[0].Pattern != "*.html" {
+				t.Errorf("unexpected filters: %+v", opts.Filters)
+			}
+			return target, nil
+		},
+	}
+
+	path, err := app.ExportSession("s1", claude.ExportOptions{Format: claude.ExportHTML})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != target {
+		t.Errorf("expected path %q, got %q", target, path)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "<html>" {
+		t.Errorf("unexpected file content: %q", data)
+	}
+}
+
+func TestExportSession_Cancelled(t *testing.T) {
+	app := &App{
+		ctx: context.Background(),
+		exporter: &mockExporter{exportFn: func(string, string, claude.ExportOptions) ([]byte, error) {
+			return []byte("x"), nil
+		}},
+		workingDir: "/my/project",
+		saveDialog: func(context.Context, runtime.SaveDialogOptions) (string, error) { return "", nil },
+	}
+
+	path, err := app.ExportSession("s1", claude.ExportOptions{})
+	if err != nil || path != "" {
+		t.Errorf("expected empty path and no error, got %q, %v", path, err)
+	}
+}
+
+func TestExportSession_ExportErrorSkipsDialog(t *testing.T) {
+	app := &App{
+		ctx: context.Background(),
+		exporter: &mockExporter{exportFn: func(string, string, claude.ExportOptions) ([]byte, error) {
+			return nil, errors.New("no such session")
+		}},
+		workingDir: "/my/project",
+		saveDialog: func(context.Context, runtime.SaveDialogOptions) (string, error) {
+			t.Error("dialog opened for failed export")
+			return "", nil
+		},
+	}
+
+	if _, err := app.ExportSession("s1", claude.ExportOptions{}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// --- Bundle tests ---
+
+type mockBundler struct {
+	exportFn func(projectPath string, sessionID string) ([]byte, error)
+	importFn func(data []byte, targetPath string, opts claude.ImportOptions) (claude.SessionInfo, error)
+}
+
+func (m *mockBundler) ExportBundle(projectPath string, sessionID string) ([]byte, error) {
+	return m.exportFn(projectPath, sessionID)
+}
+
+func (m *mockBundler) ImportBundle(data []byte, targetPath string, opts claude.ImportOptions) (claude.SessionInfo, error) {
+	return m.importFn(data, targetPath, opts)
+}
+
+func TestExportBundle_WritesChosenFile(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "s1.session.zip")
+	app := &App{
+		ctx:        context.Background(),
+		workingDir: "/my/project",
+		bundler: &mockBundler{exportFn: func(projectPath string, sessionID string) ([]byte, error) {
+			if projectPath != "/my/project" || sessionID != "s1" {
+				t.Errorf("unexpected export call: %q %q", projectPath, sessionID)
+			}
+			return []byte("zip"), nil
+		}},
+		saveDialog: func(ctx context.Context, opts runtime.SaveDialogOptions) (string, error) {
+			if opts.DefaultFilename != "s1"+claude.BundleExtension {
+				t.Errorf("unexpected default filename %q", opts.DefaultFilename)
+			}
+			return target, nil
+		},
+	}
+
+	path, err := app.ExportBundle("s1")
+	if err != nil || path != target {
+		t.Fatalf("expected %q, got %q, %v", target, path, err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "zip" {
+		t.Errorf("unexpected file content: %q", data)
+	}
+}
+
+func TestImportBundle_ReadsChosenFile(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "in.session.zip")
+	os.WriteFile(source, []byte("zip"), 0644)
+	app := &App{
+		ctx:        context.Background(),
+		workingDir: "/my/project",
+		spawner:    &mockSpawner{version: "2.0.14"},
+		bundler: &mockBundler{importFn: func(data []byte, targetPath string, opts claude.ImportOptions) (claude.SessionInfo, error) {
+			if string(data) != "zip" || targetPath != "/my/project" || !opts.NewSessionID || opts.CLIVersion != "2.0.14" {
+				t.Errorf("unexpected import call: %q %q %+v", data, targetPath, opts)
+			}
+			return claude.SessionInfo{ID: "s1"}, nil
+		}},
+		openDialog: func(context.Context, runtime.OpenDialogOptions) (string, error) { return source, nil },
+	}
+
+	info, err := app.ImportBundle(true)
+	if err != nil || info.ID != "s1" {
+		t.Errorf("expected session s1, got %+v, %v", info, err)
+	}
+}
+
+func TestImportBundle_Cancelled(t *testing.T) {
+	app := &App{
+		ctx:        context.Background(),
+		workingDir: "/my/project",
+		bundler: &mockBundler{importFn: func([]byte, string, claude.ImportOptions) (claude.SessionInfo, error) {
+			t.Error("import called after cancelled dialog")
+			return claude.SessionInfo{}, nil
+		}},
+		openDialog: func(context.Context, runtime.OpenDialogOptions) (string, error) { return "", nil },
+	}
+
+	if info, err := app.ImportBundle(false); err != nil || info.ID != "" {
+		t.Errorf("expected empty result, got %+v, %v", info, err)
+	}
+}
+
+// --- GetSessionStats tests ---
+
+func TestGetSessionStats_UsesProjectDir(t *testing.T) {
+	lister := &mockSessionLister{
+		statsFn: func(projectPath string, sessionID string) (claude.SessionStats, error) {
+			if projectPath != "/my/project" || sessionID != "s1" {
+				t.Errorf("unexpected call: %q %q", projectPath, sessionID)
+			}
+			return claude.SessionStats{ToolCalls: map[string]int{"Bash": 2}}, nil
+		},
+	}
+	app := &App{lister: lister, workingDir: "/my/project"}
+
+	stats, err := app.GetSessionStats("s1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.ToolCalls["Bash"] != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBranchBindings_UseProjectDir(t *testing.T) {
+	lister := &mockSessionLister{
+		branchesFn: func(projectPath string, sessionID string) (claude.ConversationTree, error) {
+			if projectPath != "/my/project" || sessionID != "s1" {
+				t.Errorf("unexpected call: %q %q", projectPath, sessionID)
+			}
+			return claude.ConversationTree{SessionID: "s1", ActiveLeaf: "a4"}, nil
+		},
+		loadBranchFn: func(projectPath string, sessionID string, leafUUID string) (claude.Transcript, error) {
+			if projectPath != "/my/project" || sessionID != "s1" || leafUUID != "a2" {
+				t.Errorf("unexpected call: %q %q %q", projectPath, sessionID, leafUUID)
+			}
+			return claude.Transcript{SessionID: "s1", Summary: "Tried A"}, nil
+		},
+	}
+	app := &App{lister: lister, workingDir: "/my/project"}
+
+	tree, err := app.GetSessionBranches("s1")
+	if err != nil || tree.ActiveLeaf != "a4" {
+		t.Errorf("GetSessionBranches() = %+v, %v", tree, err)
+	}
+	tr, err := app.LoadBranch("s1", "a2")
+	if err != nil || tr.Summary != "Tried A" {
+		t.Errorf("LoadBranch() = %+v, %v", tr, err)
+	}
+}
+
+// --- Request ID tests ---
+
+func TestStreamPrompt_AddsRequestIDToAllEvents(t *testing.T) {
+	emitter := &mockEmitter{}
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"system","session_id":"s1"}`)})
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"assistant","message":{"content":[{"type":"text","text":"hi"}]}}`)})
+			return nil
+		},
+	}
+
+	app := &App{
+		ctx:     context.Background(),
+		spawner: spawner,
+		emitter: emitter,
+	}
+
+	app.streamPrompt("hello", "", "req-123")
+
+	events := emitter.getEvents()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d: %+v", len(events), events)
+	}
+
+	// Check that all claude:event events have the request ID
+	for i, ev := range events {
+		if ev.name == "claude:event" {
+			bridge, ok := ev.data[0].(claude.BridgeEvent)
+			if !ok {
+				t.Fatalf("event[%d]: expected BridgeEvent, got %T", i, ev.data[0])
+			}
+			if bridge.RequestID != "req-123" {
+				t.Errorf("event[%d]: expected RequestID 'req-123', got %q", i, bridge.RequestID)
+			}
+		}
+	}
+}
+
+func TestSendPromptWithRequestId_LaunchesGoroutine(t *testing.T) {
+	emitter := &mockEmitter{}
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			if prompt != "test" {
+				t.Errorf("expected prompt 'test', got %q", prompt)
+			}
+			return nil
+		},
+	}
+
+	app := &App{
+		ctx:     context.Background(),
+		spawner: spawner,
+		emitter: emitter,
+	}
+
+	app.SendPromptWithRequestId("test", "req-456")
+
+	// Wait for goroutine to complete
+	time.Sleep(50 * time.Millisecond)
+
+	events := emitter.getEvents()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if events[0].name != "claude:done" {
+		t.Errorf("expected 'claude:done', got %q", events[0].name)
+	}
+}
+
+func TestSendPromptWithSessionAndRequestId_LaunchesGoroutine(t *testing.T) {
+	emitter := &mockEmitter{}
+	spawner := &mockSpawner{
+		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
+			if prompt != "continue" {
+				t.Errorf("expected prompt 'continue', got %q", prompt)
+			}
+			if sessionID != "sess-abc" {
+				t.Errorf("expected sessionID 'sess-abc', got %q", sessionID)
+			}
+			return nil
+		},
+	}
+
+	app := &App{
+		ctx:     context.Background(),
+		spawner: spawner,
+		emitter: emitter,
+	}
+
+	app.SendPromptWithSessionAndRequestId("continue", "sess-abc", "req-789")
+
+	// Wait for goroutine to complete
+	time.Sleep(50 * time.Millisecond)
+
+	events := emitter.getEvents()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	if events[0].name != "claude:done" {
+		t.Errorf("expected 'claude:done', got %q", events[0].name)
+	}
+}
+
+// --- GetClaudeConfigDir tests ---
+
+func TestGetClaudeConfigDir_ReturnsEnvValue(t *testing.T) {
+	result := getClaudeConfigDir(func(key string) string {
+		if key == "CLAUDE_CONFIG_DIR" {
+			return "/custom/claude/dir"
+		}
+		return ""
+	})
+	if result != "/custom/claude/dir" {
+		t.Errorf("expected '/custom/claude/dir', got %q", result)
+	}
+}
+
+func TestGetClaudeConfigDir_ReturnsEmptyWhenNotSet(t *testing.T) {
+	result := getClaudeConfigDir(func(key string) string {
+		return ""
+	})
+	if result != "" {
+		t.Errorf("expected empty string, got %q", result)
+	}
+}
+
+func TestSessionCachePath(t *testing.T) {
+	got := sessionCachePath(func() (string, error) { return "/cache", nil })
+	if want := filepath.Join("/cache", "dogma", "session-meta.json"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if got := sessionCachePath(func() (string, error) { return "", errors.New("no cache dir") }); got != "" {
+		t.Errorf("expected empty path without cache dir, got %q", got)
+	}
+}
+
+// --- Todo tests ---
+
+func TestStreamPrompt_EmitsTodosOnChange(t *testing.T) {
+	emitter := &mockEmitter{}
+	todoEvent := `{"type":"assistant","message":{"content":[{"type":"tool_use","name":"TodoWrite","input":{"todos":[{"content":"a","status":"pending"}]}}]}}`
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"system","session_id":"s1"}`)})
+			handler(claude.StreamEvent{Payload: []byte(todoEvent)})
+			// Same list again: no second claude:todos
+			handler(claude.StreamEvent{Payload: []byte(todoEvent)})
+			return nil
+		},
+	}
+
+	app := &App{
+		ctx:     context.Background(),
+		spawner: spawner,
+		emitter: emitter,
+	}
+
+	app.streamPrompt("plan", "", "req-1")
+
+	var updates []claude.TodoUpdate
+	for _, ev := range emitter.getEvents() {
+		if ev.name == "claude:todos" {
+			updates = append(updates, ev.data[0].(claude.TodoUpdate))
+		}
+	}
+	if len(updates) != 1 {
+		t.Fatalf("expected 1 claude:todos event, got %d", len(updates))
+	}
+	if updates[0].SessionID != "s1" || updates[0].RequestID != "req-1" {
+		t.Errorf("unexpected update ids: %+v", updates[0])
+	}
+	if len(updates[0].Todos) != 1 || updates[0].Todos[0].Content != "a" {
+		t.Errorf("unexpected todos: %+v", updates[0].Todos)
+	}
+
+	todos, err := app.GetTodos("s1")
+	if err != nil {
+		t.Fatalf("GetTodos() error = %v", err)
+	}
+	if len(todos) != 1 {
+		t.Errorf("expected tracked todos, got %v", todos)
+	}
+}
+
+func TestGetTodos_LoadsFromListerForUnknownSession(t *testing.T) {
+	var calledPath, calledID string
+	lister := &mockSessionLister{
+		loadTodosFn: func(projectPath string, sessionID string) ([]claude.TodoItem, error) {
+			calledPath, calledID = projectPath, sessionID
+			return []claude.TodoItem{{Content: "resume me", Status: "in_progress"}}, nil
+		},
+	}
+
+	app := &App{
+		lister:     lister,
+		workingDir: "/home/user/project",
+	}
+
+	todos, err := app.GetTodos("old-session")
+	if err != nil {
+		t.Fatalf("GetTodos() error = %v", err)
+	}
+	if calledPath != "/home/user/project" || calledID != "old-session" {
+		t.Errorf("lister called with %q, %q", calledPath, calledID)
+	}
+	if len(todos) != 1 || todos[0].Content != "resume me" {
+		t.Errorf("unexpected todos: %v", todos)
+	}
+
+	// Second call is served from the tracker
+	lister.loadTodosFn = func(projectPath string, sessionID string) ([]claude.TodoItem, error) {
+		t.Fatal("lister should not be called for a tracked session")
+		return nil, nil
+	}
+	if _, err := app.GetTodos("old-session"); err != nil {
+		t.Fatalf("GetTodos() error = %v", err)
+	}
+}
+
+func TestGetTodos_ReloadsChangedSessions(t *testing.T) {
+	content := "first"
+	lister := &mockSessionLister{
+		loadTodosFn: func(projectPath string, sessionID string) ([]claude.TodoItem, error) {
+			return []claude.TodoItem{{Content: content, Status: "pending"}}, nil
+		},
+	}
+	app := &App{lister: lister, emitter: &mockEmitter{}, workingDir: "/home/user/project"}
+	if _, err := app.GetTodos("s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run in a terminal updated the list
+	content = "second"
+	app.onSessionChanges(claude.SessionChanges{Updated: []claude.SessionInfo{{ID: "s1"}}})
+	todos, err := app.GetTodos("s1")
+	if err != nil || len(todos) != 1 || todos[0].Content != "second" {
+		t.Errorf("GetTodos() = %v, %v; want the reloaded list", todos, err)
+	}
+}
+
+func TestGetTodos_PropagatesErrors(t *testing.T) {
+	lister := &mockSessionLister{
+		loadTodosFn: func(projectPath string, sessionID string) ([]claude.TodoItem, error) {
+			return nil, errors.New("no such session")
+		},
+	}
+
+	app := &App{
+		lister:     lister,
+		workingDir: "/some/path",
+	}
+
+	if _, err := app.GetTodos("missing"); err == nil || err.Error() != "no such session" {
+		t.Errorf("expected 'no such session' error, got %v", err)
+	}
+}
+
+// --- Event bus tests ---
+
+func TestStreamPrompt_PublishesLifecycleAndUsageOnBus(t *testing.T) {
+	emitter := &mockEmitter{}
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"system","session_id":"s1"}`)})
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"result","subtype":"success","session_id":"s1","total_cost_usd":0.25,"num_turns":3,"usage":{"input_tokens":10,"output_tokens":20}}`)})
+			return nil
+		},
+	}
+
+	app := &App{
+		ctx:     context.Background(),
+		spawner: spawner,
+		emitter: emitter,
+	}
+
+	var topics []string
+	var usage events.UsageReport
+	app.eventBus().Subscribe(events.SubscriberFunc(func(ev events.Event) {
+		topics = append(topics, ev.Topic)
+		if report, ok := ev.Payload.(events.UsageReport); ok {
+			usage = report
+		}
+	}), nil)
+
+	app.streamPrompt("hello", "", "req-1")
+
+	want := []string{"run:started", "claude:event", "claude:event", "claude:usage", "run:finished"}
+	if len(topics) != len(want) {
+		t.Fatalf("expected topics %v, got %v", want, topics)
+	}
+	for i := range want {
+		if topics[i] != want[i] {
+			t.Errorf("topic[%d] = %q, want %q", i, topics[i], want[i])
+		}
+	}
+	if usage.SessionID != "s1" || usage.RequestID != "req-1" || usage.TotalCostUSD != 0.25 || usage.Usage.OutputTokens != 20 {
+		t.Errorf("unexpected usage report: %+v", usage)
+	}
+
+	// The frontend only sees its legacy events
+	for _, ev := range emitter.getEvents() {
+		if ev.name == "run:started" || ev.name == "claude:usage" {
+			t.Errorf("unexpected frontend event %q", ev.name)
+		}
+	}
+}
+
+func TestStreamPrompt_ErrorPublishesRunError(t *testing.T) {
+	spawner := &mockSpawner{
+		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
+			return errors.New("boom")
+		},
+	}
+
+	app := &App{
+		ctx:     context.Background(),
+		spawner: spawner,
+	}
+
+	var runErr events.RunError
+	var end events.RunEnd
+	events.Subscribe(app.eventBus(), events.Errors, func(p events.RunError) { runErr = p })
+	events.Subscribe(app.eventBus(), events.RunFinished, func(p events.RunEnd) { end = p })
+
+	app.streamPrompt("hello", "sess-1", "req-1")
+
+	if runErr.Message != "boom" || runErr.SessionID != "sess-1" {
+		t.Errorf("unexpected run error: %+v", runErr)
+	}
+	if end.Error != "boom" || end.RequestID != "req-1" {
+		t.Errorf("unexpected run end: %+v", end)
+	}
+}
+
+// --- Session watcher tests ---
+
+func TestPublishSessionChanges_EmitsNonEmptyKinds(t *testing.T) {
+	emitter := &mockEmitter{}
+	app := &App{ctx: context.Background(), emitter: emitter}
+
+	app.publishSessionChanges(claude.SessionChanges{
+		Added:   []claude.SessionInfo{{ID: "new"}},
+		Removed: []string{"gone"},
+	})
+
+	evts := emitter.getEvents()
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 events, got %d: %+v", len(evts), evts)
+	}
+	if evts[0].name != "sessions:added" {
+		t.Errorf("expected sessions:added, got %q", evts[0].name)
+	}
+	if added, ok := evts[0].data[0].([]claude.SessionInfo); !ok || len(added) != 1 || added[0].ID != "new" {
+		t.Errorf("unexpected sessions:added payload: %+v", evts[0].data)
+	}
+	if evts[1].name != "sessions:removed" {
+		t.Errorf("expected sessions:removed, got %q", evts[1].name)
+	}
+	if removed, ok := evts[1].data[0].([]string); !ok || len(removed) != 1 || removed[0] != "gone" {
+		t.Errorf("unexpected sessions:removed payload: %+v", evts[1].data)
+	}
+}
+
+func TestPublishSessionChanges_Updated(t *testing.T) {
+	app := &App{ctx: context.Background()}
+
+	var updated []claude.SessionInfo
+	events.Subscribe(app.eventBus(), events.SessionsUpdated, func(p []claude.SessionInfo) { updated = p })
+
+	app.publishSessionChanges(claude.SessionChanges{Updated: []claude.SessionInfo{{ID: "s1", Summary: "More"}}})
+
+	if len(updated) != 1 || updated[0].Summary != "More" {
+		t.Errorf("unexpected sessions:updated payload: %+v", updated)
+	}
+}
+
+// --- Attach tests ---
+
+func TestAttachSession_PublishesReadOnlyEvents(t *testing.T) {
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-my-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(projectDir, "ext.jsonl")
+	line := func(uuid, text string) string {
+		return `{"type":"user","uuid":"` + uuid + `","timestamp":"2026-01-20T10:00:00Z","message":{"role":"user","content":"` + text + `"}}` + "\n"
+	}
+	if err := os.WriteFile(path, []byte(line("u1", "Long run")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spawner := &mockSpawner{
+		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
+			t.Error("prompt sent to an attached session")
+			return nil
+		},
+	}
+	app := &App{ctx: context.Background(), spawner: spawner, tailer: claude.NewSessionLister(base), workingDir: "/my/project"}
+	received := make(chan claude.BridgeEvent, 10)
+	events.Subscribe(app.eventBus(), events.BridgeEvents, func(ev claude.BridgeEvent) { received <- ev })
+	var runErr events.RunError
+	events.Subscribe(app.eventBus(), events.Errors, func(e events.RunError) { runErr = e })
+	var detached string
+	events.Subscribe(app.eventBus(), events.SessionDetached, func(id string) { detached = id })
+
+	history, err := app.AttachSession("ext")
+	if err != nil {
+		t.Fatalf("AttachSession() error = %v", err)
+	}
+	if len(history.Events) != 1 || history.Events[0].Text != "Long run" {
+		t.Errorf("unexpected history: %+v", history.Events)
+	}
+	if _, err := app.AttachSession("ext"); err != nil {
+		t.Errorf("second AttachSession() error = %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(line("u2", "Next step"))
+	f.Close()
+	select {
+	case ev := <-received:
+		if ev.UUID != "u2" || !ev.ReadOnly {
+			t.Errorf("unexpected event: %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event for appended entry")
+	}
+
+	app.streamPrompt("interrupt", "ext", "req-1")
+	if runErr.RequestID != "req-1" || !strings.Contains(runErr.Message, "attached") {
+		t.Errorf("unexpected run error: %+v", runErr)
+	}
+
+	app.DetachSession("ext")
+	if detached != "ext" {
+		t.Errorf("expected session:detached for ext, got %q", detached)
+	}
+	app.DetachSession("ext")
+	if app.isAttached("ext") {
+		t.Error("session still attached after DetachSession")
+	}
+}
+
+func TestStreamPrompt_RefusesSessionWrittenElsewhere(t *testing.T) {
+	var mu sync.Mutex
+	var written time.Time
+	setWritten := func(ts time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		written = ts
+	}
+	runs := 0
+	spawner := &mockSpawner{
+		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
+			runs++
+			setWritten(time.Now()) // The app's own run writes the file
+			return nil
+		},
+	}
+	lister := &mockSessionLister{
+		modTimeFn: func(projectPath string, sessionID string) (time.Time, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return written, nil
+		},
+	}
+	app := &App{ctx: context.Background(), spawner: spawner, lister: lister, workingDir: "/my/project"}
+	var runErr events.RunError
+	events.Subscribe(app.eventBus(), events.Errors, func(e events.RunError) { runErr = e })
+
+	// Written by the CLI in a terminal a moment ago
+	setWritten(time.Now().Add(-time.Second))
+	app.streamPrompt("hello", "s1", "req-1")
+	if runs != 0 || runErr.RequestID != "req-1" || !strings.Contains(runErr.Message, "another process") {
+		t.Fatalf("runs = %d, run error = %+v; want the prompt refused", runs, runErr)
+	}
+
+	// Idle long enough
+	setWritten(time.Now().Add(-2 * recentWriteWindow))
+	app.streamPrompt("hello", "s1", "req-2")
+	if runs != 1 {
+		t.Fatalf("runs = %d, want the idle session resumed", runs)
+	}
+
+	// The app's own write does not block the next prompt
+	app.streamPrompt("again", "s1", "req-3")
+	if runs != 2 || runErr.RequestID != "req-1" {
+		t.Errorf("runs = %d, run error = %+v; want the follow-up prompt sent", runs, runErr)
+	}
+}
+
+// --- Checkpoint tests ---
+
+func TestStreamPrompt_CheckpointsEditedFiles(t *testing.T) {
+	work := t.TempDir()
+	target := filepath.Join(work, "main.go")
+	if err := os.WriteFile(target, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			handler(claude.StreamEvent{Type: "system", Payload: json.RawMessage(`{"type":"system","subtype":"init","session_id":"s1"}`)})
+			input, _ := json.Marshal(map[string]string{"file_path": target, "content": "broken"})
+			handler(claude.StreamEvent{Type: "assistant", Payload: json.RawMessage(`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Write","input":` + string(input) + `}]}}`)})
+			// The CLI runs the tool after announcing it
+			return os.WriteFile(target, []byte("broken"), 0644)
+		},
+	}
+	app := &App{ctx: context.Background(), spawner: spawner, emitter: &mockEmitter{}, workingDir: work, checkpoints: checkpoint.NewStore(t.TempDir())}
+
+	app.streamPrompt("Rewrite main", "", "")
+
+	checkpoints, err := app.ListCheckpoints("s1")
+	if err != nil || len(checkpoints) != 1 || checkpoints[0].Prompt != "Rewrite main" || len(checkpoints[0].Files) != 1 {
+		t.Fatalf("ListCheckpoints() = %+v, %v", checkpoints, err)
+	}
+	preview, err := app.PreviewRestore("s1", 1)
+	if err != nil || len(preview) != 1 || preview[0].Path != target || preview[0].Action != checkpoint.ActionModify {
+		t.Fatalf("PreviewRestore() = %+v, %v", preview, err)
+	}
+	if _, err := app.RestoreCheckpoint("s1", 1); err != nil {
+		t.Fatalf("RestoreCheckpoint() error = %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "package main\n" {
+		t.Errorf("main.go = %q after restore", data)
+	}
+}
+
+func TestCheckpoints_Unavailable(t *testing.T) {
+	app := &App{}
+	if checkpoints, err := app.ListCheckpoints("s1"); err != nil || len(checkpoints) != 0 {
+		t.Errorf("ListCheckpoints() = %+v, %v; want empty", checkpoints, err)
+	}
+	if _, err := app.PreviewRestore("s1", 1); err == nil {
+		t.Error("PreviewRestore() error = nil, want error")
+	}
+	if _, err := app.RestoreCheckpoint("s1", 1); err == nil {
+		t.Error("RestoreCheckpoint() error = nil, want error")
+	}
+}
+
+func TestCheckpointDir(t *testing.T) {
+	if got := checkpointDir(func() (string, error) { return "/home/u/.config", nil }); got != filepath.Join("/home/u/.config", "dogma", "checkpoints") {
+		t.Errorf("checkpointDir() = %q", got)
+	}
+	if got := checkpointDir(func() (string, error) { return "", errors.New("no home") }); got != "" {
+		t.Errorf("checkpointDir() = %q, want empty", got)
+	}
+}
+
+func TestTrashDir(t *testing.T) {
+	if got := trashDir(func() (string, error) { return "/home/u/.config", nil }); got != filepath.Join("/home/u/.config", "dogma", "trash") {
+		t.Errorf("trashDir() = %q", got)
+	}
+	if got := trashDir(func() (string, error) { return "", errors.New("no home") }); got != "" {
+		t.Errorf("trashDir() = %q, want empty", got)
+	}
+}
+
+// --- Git tests ---
+
+type mockGitRepo struct {
+	changes git.Changes
+	err     error
+	calls   []string
+}
+
+func (m *mockGitRepo) Changes(ctx context.Context) (git.Changes, error) {
+	m.calls = append(m.calls, "changes")
+	return m.changes, m.err
+}
+
+func (m *mockGitRepo) Stage(ctx context.Context, paths ...string) error {
+	m.calls = append(m.calls, "stage "+strings.Join(paths, " "))
+	return m.err
+}
+
+func (m *mockGitRepo) Discard(ctx context.Context, path string) error {
+	m.calls = append(m.calls, "discard "+path)
+	return m.err
+}
+
+func (m *mockGitRepo) Worktrees(ctx context.Context) ([]git.Worktree, error) {
+	m.calls = append(m.calls, "worktrees")
+	return []git.Worktree{
+		{Path: "/my/project", Branch: "main", Main: true},
+		{Path: "/wt/project-a", Branch: "dogma/session-a"},
+		{Path: "/wt/project-b", Branch: "dogma/session-b"},
+		{Path: "/wt/project-mine", Branch: "feature"},
+		{Path: "/my/project-feature", Branch: "dogma/feature"},
+	}, m.err
+}
+
+func (m *mockGitRepo) AddWorktree(ctx context.Context, path string, branch string) error {
+	m.calls = append(m.calls, "add "+path+" "+branch)
+	return m.err
+}
+
+func (m *mockGitRepo) MergeWorktree(ctx context.Context, path string, message string) error {
+	m.calls = append(m.calls, "merge "+path+" "+message)
+	return m.err
+}
+
+func (m *mockGitRepo) RemoveWorktree(ctx context.Context, path string) error {
+	m.calls = append(m.calls, "remove "+path)
+	return m.err
+}
+
+func (m *mockGitRepo) Commit(ctx context.Context, message string) (string, error) {
+	m.calls = append(m.calls, "commit "+message)
+	return "abc123", m.err
+}
+
+func TestStreamPrompt_PublishesGitChangesAfterResult(t *testing.T) {
+	emitter := &mockEmitter{}
+	repo := &mockGitRepo{changes: git.Changes{Root: "/my/project", Files: []git.FileChange{{Path: "main.go", Status: git.StatusModified}}}}
+	var openedDir string
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"system","session_id":"s1"}`)})
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"result","subtype":"success","session_id":"s1"}`)})
+			return nil
+		},
+	}
+	app := &App{
+		ctx:        context.Background(),
+		spawner:    spawner,
+		emitter:    emitter,
+		workingDir: "/my/project",
+		openGit: func(dir string) gitRepo {
+			openedDir = dir
+			return repo
+		},
+	}
+
+	app.streamPrompt("hello", "", "req-1")
+
+	if openedDir != "/my/project" {
+		t.Errorf("opened git repo in %q", openedDir)
+	}
+	var names []string
+	var report events.GitReport
+	for _, ev := range emitter.getEvents() {
+		names = append(names, ev.name)
+		if r, ok := ev.data[0].(events.GitReport); ok {
+			report = r
+		}
+	}
+	if strings.Join(names, ",") != "claude:event,claude:event,git:changes,claude:done" {
+		t.Errorf("frontend events = %v", names)
+	}
+	if report.RequestID != "req-1" || report.SessionID != "s1" || len(report.Changes.Files) != 1 {
+		t.Errorf("unexpected git report: %+v", report)
+	}
+}
+
+func TestStreamPrompt_NoGitChangesOutsideRepository(t *testing.T) {
+	emitter := &mockEmitter{}
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"result","subtype":"success","session_id":"s1"}`)})
+			return nil
+		},
+	}
+	repo := &mockGitRepo{err: git.ErrNotRepository}
+	app := &App{ctx: context.Background(), spawner: spawner, emitter: emitter, workingDir: "/tmp", openGit: func(string) gitRepo { return repo }}
+
+	app.streamPrompt("hello", "", "")
+
+	for _, ev := range emitter.getEvents() {
+		if ev.name == "git:changes" {
+			t.Errorf("unexpected git:changes event: %+v", ev.data)
+		}
+	}
+}
+
+func TestGitBindings(t *testing.T) {
+	emitter := &mockEmitter{}
+	repo := &mockGitRepo{}
+	app := &App{ctx: context.Background(), emitter: emitter, workingDir: "/my/project", openGit: func(string) gitRepo { return repo }}
+
+	if _, err := app.GitChanges(""); err != nil {
+		t.Fatalf("GitChanges() error = %v", err)
+	}
+	if err := app.StageFiles("", []string{"a.go", "b.go"}); err != nil {
+		t.Fatalf("StageFiles() error = %v", err)
+	}
+	if err := app.DiscardFile("", "c.go"); err != nil {
+		t.Fatalf("DiscardFile() error = %v", err)
+	}
+	if hash, err := app.CommitChanges("", "Fix bug"); err != nil || hash != "abc123" {
+		t.Fatalf("CommitChanges() = %q, %v", hash, err)
+	}
+
+	want := []string{"changes", "stage a.go b.go", "changes", "discard c.go", "changes", "commit Fix bug", "changes"}
+	if strings.Join(repo.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("calls = %v, want %v", repo.calls, want)
+	}
+	if got := len(emitter.getEvents()); got != 3 {
+		t.Errorf("expected 3 git:changes events, got %d", got)
+	}
+
+	repo.err = errors.New("boom")
+	if err := app.StageFiles("", []string{"a.go"}); err == nil {
+		t.Error("StageFiles() error = nil, want error")
+	}
+	if _, err := (&App{}).GitChanges(""); err == nil {
+		t.Error("GitChanges() without git error = nil, want error")
+	}
+}
+
+func TestGitBindings_UseSessionWorktree(t *testing.T) {
+	repos := map[string]*mockGitRepo{"/my/project": {}, "/wt/project-a": {}}
+	lister := &mockSessionLister{workDirFn: func(projectPath string, sessionID string) (string, error) {
+		if sessionID == "wt-session" {
+			return "/wt/project-a", nil
+		}
+		return projectPath, nil
+	}}
+	app := &App{ctx: context.Background(), lister: lister, emitter: &mockEmitter{}, workingDir: "/my/project", openGit: func(dir string) gitRepo { return repos[dir] }}
+
+	if err := app.DiscardFile("wt-session", "c.go"); err != nil {
+		t.Fatalf("DiscardFile() error = %v", err)
+	}
+	if err := app.StageFiles("main-session", []string{"a.go"}); err != nil {
+		t.Fatalf("StageFiles() error = %v", err)
+	}
+	if got := strings.Join(repos["/wt/project-a"].calls, ","); got != "discard c.go,changes" {
+		t.Errorf("worktree calls = %q", got)
+	}
+	if got := strings.Join(repos["/my/project"].calls, ","); got != "stage a.go,changes" {
+		t.Errorf("checkout calls = %q", got)
+	}
+}
+
+// --- Worktree tests ---
+
+func TestSendPromptInWorktree(t *testing.T) {
+	repo := &mockGitRepo{}
+	ran := make(chan string, 1)
+	spawner := &mockSpawner{
+		sendInDirFn: func(ctx context.Context, prompt string, sessionID string, dir string, handler claude.EventHandler) error {
+			if prompt != "Fix it" || sessionID != "" {
+				t.Errorf("unexpected run: %q in session %q", prompt, sessionID)
+			}
+			ran <- dir
+			return nil
+		},
+	}
+	app := &App{ctx: context.Background(), spawner: spawner, workingDir: "/my/project", worktreeDir: "/wt", openGit: func(string) gitRepo { return repo }}
+
+	path, err := app.SendPromptInWorktree("Fix it", "req-1")
+	if err != nil {
+		t.Fatalf("SendPromptInWorktree() error = %v", err)
+	}
+	if !strings.HasPrefix(path, filepath.Join("/wt", "project-session-")) {
+		t.Errorf("worktree path = %q", path)
+	}
+	if got := <-ran; got != path {
+		t.Errorf("ran in %q, want %q", got, path)
+	}
+	name := strings.TrimPrefix(filepath.Base(path), "project-")
+	if len(repo.calls) == 0 || repo.calls[0] != "add "+path+" dogma/"+name {
+		t.Errorf("calls = %v", repo.calls)
+	}
+
+	if _, err := (&App{workingDir: "/my/project", openGit: func(string) gitRepo { return repo }}).SendPromptInWorktree("x", ""); err == nil {
+		t.Error("SendPromptInWorktree() without worktree dir error = nil, want error")
+	}
+}
+
+func TestStreamPrompt_ResumesWorktreeSessionInWorktree(t *testing.T) {
+	var ranIn string
+	spawner := &mockSpawner{
+		sendInDirFn: func(ctx context.Context, prompt string, sessionID string, dir string, handler claude.EventHandler) error {
+			ranIn = dir + " " + sessionID
+			return nil
+		},
+		sendWithSessFn: func(ctx context.Context, prompt string, sessionID string, handler claude.EventHandler) error {
+			ranIn = "checkout " + sessionID
+			return nil
+		},
+	}
+	lister := &mockSessionLister{workDirFn: func(projectPath string, sessionID string) (string, error) {
+		if sessionID == "wt-session" {
+			return "/wt/project-a", nil
+		}
+		return projectPath, nil
+	}}
+	app := &App{ctx: context.Background(), spawner: spawner, lister: lister, emitter: &mockEmitter{}, workingDir: "/my/project"}
+
+	app.streamPrompt("continue", "wt-session", "")
+	if ranIn != "/wt/project-a wt-session" {
+		t.Errorf("ran %q, want in worktree", ranIn)
+	}
+	app.streamPrompt("continue", "main-session", "")
+	if ranIn != "checkout main-session" {
+		t.Errorf("ran %q, want in checkout", ranIn)
+	}
+}
+
+func TestMergeAndRemoveWorktree(t *testing.T) {
+	repo := &mockGitRepo{}
+	manager := &mockManager{}
+	emitter := &mockEmitter{}
+	lister := &mockSessionLister{workDirFn: func(projectPath string, sessionID string) (string, error) {
+		if sessionID == "main-session" {
+			return projectPath, nil
+		}
+		return "/wt/project-a", nil
+	}}
+	app := &App{ctx: context.Background(), lister: lister, manager: manager, emitter: emitter, workingDir: "/my/project", worktreeDir: "/wt", openGit: func(string) gitRepo { return repo }}
+
+	if err := app.MergeWorktree("wt-session"); err != nil {
+		t.Fatalf("MergeWorktree() error = %v", err)
+	}
+	if err := app.RemoveWorktree("wt-session"); err != nil {
+		t.Fatalf("RemoveWorktree() error = %v", err)
+	}
+	want := []string{"worktrees", "merge /wt/project-a Changes from session wt-session", "changes", "worktrees", "remove /wt/project-a", "changes"}
+	if strings.Join(repo.calls, ",") != strings.Join(want, ",") {
+		t.Errorf("git calls = %v, want %v", repo.calls, want)
+	}
+	if len(manager.calls) != 2 || manager.calls[0] != "adopt /my/project /wt/project-a" {
+		t.Errorf("manager calls = %v", manager.calls)
+	}
+
+	if err := app.MergeWorktree("main-session"); err == nil || !strings.Contains(err.Error(), "does not run in a worktree") {
+		t.Errorf("MergeWorktree(checkout session) error = %v", err)
+	}
+
+	// A failed merge keeps the sessions where they are
+	repo.err = errors.New("conflict")
+	manager.calls = nil
+	if err := app.MergeWorktree("wt-session"); err == nil {
+		t.Error("MergeWorktree() error = nil, want conflict")
+	}
+	if len(manager.calls) != 0 {
+		t.Errorf("sessions adopted after failed merge: %v", manager.calls)
+	}
+}
+
+func TestMergeAndRemoveWorktree_RefusesForeignWorktrees(t *testing.T) {
+	for _, path := range []string{"/wt/project-mine", "/my/project-feature", "/elsewhere/checkout"} {
+		repo := &mockGitRepo{}
+		manager := &mockManager{}
+		lister := &mockSessionLister{workDirFn: func(projectPath string, sessionID string) (string, error) {
+			return path, nil
+		}}
+		app := &App{ctx: context.Background(), lister: lister, manager: manager, emitter: &mockEmitter{}, workingDir: "/my/project", worktreeDir: "/wt", openGit: func(string) gitRepo { return repo }}
+
+		if err := app.RemoveWorktree("cli-session"); err == nil || !strings.Contains(err.Error(), "not a session worktree") {
+			t.Errorf("RemoveWorktree(%s) error = %v, want refusal", path, err)
+		}
+		if err := app.MergeWorktree("cli-session"); err == nil || !strings.Contains(err.Error(), "not a session worktree") {
+			t.Errorf("MergeWorktree(%s) error = %v, want refusal", path, err)
+		}
+		for _, call := range repo.calls {
+			if call != "worktrees" {
+				t.Errorf("%s: git call %q on a foreign worktree", path, call)
+			}
+		}
+		if len(manager.calls) != 0 {
+			t.Errorf("%s: sessions adopted from a foreign worktree: %v", path, manager.calls)
+		}
+	}
+}
+
+func TestSessionWorktreePaths(t *testing.T) {
+	got := sessionWorktreePaths(context.Background(), &mockGitRepo{}, "/wt")
+	if strings.Join(got, ",") != "/wt/project-a,/wt/project-b" {
+		t.Errorf("sessionWorktreePaths() = %v", got)
+	}
+	if got := sessionWorktreePaths(context.Background(), &mockGitRepo{}, ""); len(got) != 0 {
+		t.Errorf("sessionWorktreePaths(no worktree dir) = %v, want none", got)
+	}
+	if got := sessionWorktreePaths(context.Background(), &mockGitRepo{err: git.ErrNotRepository}, "/wt"); len(got) != 0 {
+		t.Errorf("sessionWorktreePaths(not a repo) = %v, want none", got)
+	}
+}
+
+// --- Changeset tests ---
+
+func TestStreamPrompt_RecordsChangeset(t *testing.T) {
+	work := t.TempDir()
+	if err := os.WriteFile(filepath.Join(work, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	emitter := &mockEmitter{}
+	spawner := &mockSpawner{
+		sendPromptFn: func(ctx context.Context, prompt string, handler claude.EventHandler) error {
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"system","subtype":"init","session_id":"s1"}`)})
+			handler(claude.StreamEvent{Payload: []byte(`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"rm main.go"}}]}}`)})
+			return os.Remove(filepath.Join(work, "main.go"))
+		},
+	}
+	app := &App{ctx: context.Background(), spawner: spawner, emitter: emitter, workingDir: work, changesets: changeset.NewStore("")}
+
+	var end events.RunEnd
+	app.eventBus().Subscribe(events.SubscriberFunc(func(ev events.Event) {
+		if p, ok := ev.Payload.(events.RunEnd); ok {
+			end = p
+		}
+	}), nil)
+	app.streamPrompt("Clean up", "", "req-1")
+
+	if end.Changes == nil || end.Changes.SessionID != "s1" || end.Changes.RequestID != "req-1" {
+		t.Fatalf("RunEnd.Changes = %+v", end.Changes)
+	}
+	if files := end.Changes.Files; len(files) != 1 || files[0].Path != "main.go" || files[0].Action != changeset.ActionDeleted {
+		t.Errorf("changed files = %+v", files)
+	}
+	if cmds := end.Changes.Commands; len(cmds) != 1 || cmds[0].Command != "rm main.go" {
+		t.Errorf("commands = %+v", cmds)
+	}
+
+	// The frontend gets the run end with claude:done
+	evs := emitter.getEvents()
+	if last := evs[len(evs)-1]; last.name != "claude:done" || last.data[0].(events.RunEnd).Changes == nil {
+		t.Errorf("last frontend event = %+v", last)
+	}
+
+	list, err := app.ListChangesets("s1")
+	if err != nil || len(list) != 1 || list[0].RequestID != "req-1" {
+		t.Errorf("ListChangesets() = %+v, %v", list, err)
+	}
+	if list, err := (&App{}).ListChangesets("s1"); err != nil || len(list) != 0 {
+		t.Errorf("ListChangesets() without store = %+v, %v", list, err)
+	}
+}
+
+func TestHeadlessToken(t *testing.T) {
+	token, err := headlessToken(func(string) string { return "from-env" })
+	if err != nil || token != "from-env" {
+		t.Errorf("headlessToken() = %q, %v, want from-env", token, err)
+	}
+
+	token, err = headlessToken(func(string) string { return "" })
+	if err != nil || len(token) != 48 {
+		t.Errorf("headlessToken() = %q, %v, want a 48 character token", token, err)
 	}
 }
@@ -193,7 +193,7 @@ export function App() {
           style={{ background: 'var(--bg-color)', borderColor: 'var(--arctic-border)' }}
         >
           {showSessions && (
-            <SessionList onSelect={handleSelectSession} selectedId={sessionId.value || undefined} listFn={backend.listSessions} />
+            <SessionList onSelect={handleSelectSession} selectedId={sessionId.value || undefined} listFn={() => backend.listSessions()} />
           )}
         </div>
         <div data-testid="main-content" class="flex flex-col flex-1" onClick={() => { if (showSessions) setShowSessions(false) }}>
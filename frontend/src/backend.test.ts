@@ -3,6 +3,7 @@ import {
   isWailsEnvironment,
   createBackend,
   WailsBackend,
+  HttpBackend,
 } from './backend'
 import { MockBackend } from './backend.mock'
 import type { BridgeEvent, SessionInfo } from './types'
@@ -33,6 +34,7 @@ describe('backend adapter', () => {
 
   afterEach(() => {
     delete (window as unknown as Record<string, unknown>)['go']
+    window.sessionStorage.clear()
   })
 
   describe('isWailsEnvironment', () => {
@@ -166,6 +168,84 @@ describe('backend adapter', () => {
     })
   })
 
+  describe('HttpBackend', () => {
+    class FakeSocket {
+      static instances: FakeSocket[] = []
+      onmessage: ((msg: { data: string }) => void) | null = null
+      onclose: (() => void) | null = null
+      close = vi.fn()
+      constructor(public url: string) {
+        FakeSocket.instances.push(this)
+      }
+    }
+
+    let fetchMock: ReturnType<typeof vi.fn>
+
+    beforeEach(() => {
+      FakeSocket.instances = []
+      fetchMock = vi.fn().mockResolvedValue({ ok: true, json: () => Promise.resolve([]) })
+      vi.stubGlobal('fetch', fetchMock)
+      vi.stubGlobal('WebSocket', FakeSocket)
+    })
+
+    afterEach(() => {
+      vi.unstubAllGlobals()
+    })
+
+    it('createBackend returns HttpBackend when a token is in the URL', () => {
+      window.history.replaceState(null, '', '/?token=abc')
+      const backend = createBackend()
+      expect(backend).toBeInstanceOf(HttpBackend)
+      expect(window.location.search).toBe('')
+      window.history.replaceState(null, '', '/')
+    })
+
+    it('posts prompts with the bearer token', async () => {
+      const backend = new HttpBackend('abc', 'http://localhost:7777')
+      await backend.sendPromptWithSessionAndRequestId('hello', 's1', 'r1')
+      expect(fetchMock).toHaveBeenCalledWith('http://localhost:7777/api/prompt', expect.objectContaining({
+        method: 'POST',
+        headers: expect.objectContaining({ Authorization: 'Bearer abc' }),
+        body: JSON.stringify({ prompt: 'hello', session_id: 's1', request_id: 'r1' }),
+      }))
+    })
+
+    it('methods work when called detached', async () => {
+      fetchMock.mockResolvedValue({ ok: true, json: () => Promise.resolve([{ id: 's1' }]) })
+      const backend = new HttpBackend('abc', 'http://localhost:7777')
+      const { listSessions, cancelPrompt } = backend
+      await expect(listSessions()).resolves.toEqual([{ id: 's1' }])
+      await cancelPrompt()
+      expect(fetchMock).toHaveBeenCalledWith('http://localhost:7777/api/cancel', expect.objectContaining({ method: 'POST' }))
+    })
+
+    it('throws the server error message', async () => {
+      fetchMock.mockResolvedValue({ ok: false, status: 401, json: () => Promise.resolve({ error: 'missing or invalid token' }) })
+      const backend = new HttpBackend('wrong', 'http://localhost:7777')
+      await expect(backend.listSessions()).rejects.toThrow('missing or invalid token')
+    })
+
+    it('onEvent maps WebSocket messages to BridgeEvents', () => {
+      const backend = new HttpBackend('abc', 'http://localhost:7777')
+      const cb = vi.fn()
+      const unsub = backend.onEvent(cb)
+      const socket = FakeSocket.instances[0]
+      expect(socket.url).toBe('ws://localhost:7777/api/events?token=abc')
+
+      socket.onmessage!({ data: JSON.stringify({ event: 'claude:event', data: { type: 'assistant', text: 'hi' } }) })
+      socket.onmessage!({ data: JSON.stringify({ event: 'claude:done', data: null }) })
+      socket.onmessage!({ data: JSON.stringify({ event: 'claude:error', data: 'boom' }) })
+      expect(cb.mock.calls.map((c: unknown[]) => c[0])).toEqual([
+        { type: 'assistant', text: 'hi' },
+        { type: 'result', result: 'done' },
+        { type: 'result', result: 'boom', is_error: true },
+      ])
+
+      unsub()
+      expect(socket.close).toHaveBeenCalled()
+    })
+  })
+
   describe('MockBackend', () => {
     it('listSessions returns 3 sessions with all fields populated', async () => {
       const { MockBackend } = await import('./backend.mock')
@@ -70,9 +70,128 @@ export class WailsBackend implements BackendAdapter {
   }
 }
 
+/** serverToken returns the access token of a headless dogma server, taken
+ * from the token URL parameter on first load and kept for reloads. */
+export function serverToken(): string | null {
+  if (typeof window === 'undefined') return null
+  const url = new URL(window.location.href)
+  const token = url.searchParams.get('token')
+  if (token) {
+    window.sessionStorage.setItem('dogma-token', token)
+    // Keep the token out of the history and of copied links
+    url.searchParams.delete('token')
+    window.history.replaceState(null, '', url.toString())
+    return token
+  }
+  return window.sessionStorage.getItem('dogma-token')
+}
+
+/** HttpBackend talks to a headless dogma server (dogma -serve) through its
+ * JSON API and event WebSocket. */
+export class HttpBackend implements BackendAdapter {
+  constructor(private token: string, private baseUrl: string = window.location.origin) {
+    // Bound like the Wails bindings, so methods can be passed around
+    this.sendPrompt = this.sendPrompt.bind(this)
+    this.sendPromptWithSession = this.sendPromptWithSession.bind(this)
+    this.sendPromptWithRequestId = this.sendPromptWithRequestId.bind(this)
+    this.sendPromptWithSessionAndRequestId = this.sendPromptWithSessionAndRequestId.bind(this)
+    this.cancelPrompt = this.cancelPrompt.bind(this)
+    this.listSessions = this.listSessions.bind(this)
+    this.onEvent = this.onEvent.bind(this)
+  }
+
+  private async request(method: string, path: string, body?: unknown): Promise<Response> {
+    const res = await fetch(this.baseUrl + path, {
+      method,
+      headers: {
+        Authorization: `Bearer ${this.token}`,
+        ...(body !== undefined ? { 'Content-Type': 'application/json' } : {}),
+      },
+      body: body !== undefined ? JSON.stringify(body) : undefined,
+    })
+    if (!res.ok) {
+      const data = await res.json().catch(() => ({}))
+      throw new Error(data.error ?? `${method} ${path} failed: ${res.status}`)
+    }
+    return res
+  }
+
+  private async prompt(text: string, sessionId: string, requestId: string): Promise<void> {
+    await this.request('POST', '/api/prompt', { prompt: text, session_id: sessionId, request_id: requestId })
+  }
+
+  async sendPrompt(text: string): Promise<void> {
+    return this.prompt(text, '', '')
+  }
+
+  async sendPromptWithSession(text: string, sessionId: string): Promise<void> {
+    return this.prompt(text, sessionId, '')
+  }
+
+  async sendPromptWithRequestId(text: string, requestId: string): Promise<void> {
+    return this.prompt(text, '', requestId)
+  }
+
+  async sendPromptWithSessionAndRequestId(text: string, sessionId: string, requestId: string): Promise<void> {
+    return this.prompt(text, sessionId, requestId)
+  }
+
+  async cancelPrompt(): Promise<void> {
+    await this.request('POST', '/api/cancel')
+  }
+
+  async listSessions(): Promise<SessionInfo[]> {
+    const res = await this.request('GET', '/api/sessions')
+    return res.json()
+  }
+
+  onEvent(callback: (event: BridgeEvent) => void): () => void {
+    const url = new URL('/api/events', this.baseUrl)
+    url.protocol = url.protocol === 'https:' ? 'wss:' : 'ws:'
+    url.searchParams.set('token', this.token)
+
+    let socket: WebSocket | null = null
+    let closed = false
+    let retry: ReturnType<typeof setTimeout> | undefined
+
+    const connect = () => {
+      socket = new WebSocket(url.toString())
+      socket.onmessage = (msg: MessageEvent) => {
+        const { event, data } = JSON.parse(msg.data as string)
+        switch (event) {
+          case 'claude:event':
+            callback(data as BridgeEvent)
+            break
+          case 'claude:done':
+            callback({ type: 'result', result: 'done' })
+            break
+          case 'claude:error':
+            callback({ type: 'result', result: data as string, is_error: true })
+            break
+        }
+      }
+      // Reconnect after dropped tunnels or server restarts
+      socket.onclose = () => {
+        if (!closed) retry = setTimeout(connect, 1000)
+      }
+    }
+    connect()
+
+    return () => {
+      closed = true
+      clearTimeout(retry)
+      socket?.close()
+    }
+  }
+}
+
 export function createBackend(): BackendAdapter {
   if (isWailsEnvironment()) {
     return new WailsBackend()
   }
+  const token = serverToken()
+  if (token) {
+    return new HttpBackend(token)
+  }
   return new MockBackend()
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Marcel-Bich/dogma/internal/server"
+)
+
+// The headless server offers the same operations as the desktop bindings.
+var _ server.App = (*App)(nil)
+
+// headlessToken returns the API token from DOGMA_TOKEN, or a new random
+// one. The environment keeps it out of the process list.
+func headlessToken(getenv func(string) string) (string, error) {
+	if token := getenv("DOGMA_TOKEN"); token != "" {
+		return token, nil
+	}
+	return server.NewToken()
+}
+
+// runHeadless serves app and the frontend over HTTP on addr until the
+// process is interrupted. Frontend events go to the server's WebSockets
+// instead of a desktop window.
+func runHeadless(app *App, addr string) error {
+	token, err := headlessToken(os.Getenv)
+	if err != nil {
+		return err
+	}
+	dist, err := fs.Sub(assets, "frontend/dist")
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(app, token, dist)
+	app.emitter = srv
+	app.setup(ctx)
+
+	if !server.IsLoopback(addr) {
+		log.Printf("[SERVER] WARNING: listening on %s, which is reachable from other machines", addr)
+	}
+	fmt.Printf("dogma is serving on http://%s/?token=%s\n", addr, token)
+	return srv.ListenAndServe(ctx, addr)
+}
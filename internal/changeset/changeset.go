@@ -0,0 +1,399 @@
+// Package changeset records which files a prompt run changed. It combines
+// the files named by edit tool calls, the Bash commands that may have
+// changed files, and a comparison of the working directory before and
+// after the run.
+package changeset
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/claude"
+	"github.com/Marcel-Bich/dogma/internal/git"
+)
+
+// Scan limits. Files above maxHashSize are compared by size and mtime
+// only; a snapshot stops after maxScanFiles files and is marked partial.
+const (
+	maxHashSize  = 1 << 20
+	maxScanFiles = 50000
+)
+
+// skipDirs are not scanned: they are large and not edited by hand. In a
+// git repository the files git ignores are skipped too.
+var skipDirs = map[string]bool{
+	".git":         true,
+	"node_modules": true,
+}
+
+// editTools are the tools whose file inputs are recorded.
+var editTools = map[string]bool{
+	"Write":        true,
+	"Edit":         true,
+	"MultiEdit":    true,
+	"NotebookEdit": true,
+}
+
+// Actions found by comparing the snapshots.
+const (
+	ActionCreated  = "created"
+	ActionModified = "modified"
+	ActionDeleted  = "deleted"
+)
+
+// File is a file a run changed or announced to change.
+type File struct {
+	Path   string   `json:"path"`             // Relative to the working directory with forward slashes; absolute outside it
+	Action string   `json:"action,omitempty"` // Empty if the file did not change on disk, e.g. a failed edit
+	Tools  []string `json:"tools,omitempty"`  // Edit tools whose input named the file
+}
+
+// Command is a Bash command of the run that may have changed files.
+type Command struct {
+	ToolUseID string `json:"tool_use_id"`
+	Command   string `json:"command"`
+}
+
+// Changeset is the set of changes of one prompt run.
+type Changeset struct {
+	RequestID  string    `json:"request_id,omitempty"`
+	SessionID  string    `json:"session_id,omitempty"`
+	WorkDir    string    `json:"work_dir"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Files      []File    `json:"files"`
+	Commands   []Command `json:"commands,omitempty"`
+	Partial    bool      `json:"partial,omitempty"` // The working directory was too large to scan completely
+}
+
+// Tracker builds the changeset of one run. Start it before the run, feed
+// it the run's events with Observe and call Finish when the run is over.
+// A Tracker is used from one goroutine.
+type Tracker struct {
+	workDir   string
+	sessionID string
+	started   time.Time
+	before    snapshot
+	tools     map[string][]string // Tool names by file path
+	commands  []Command
+	now       func() time.Time // For testing; defaults to time.Now
+
+	// The contents of the files in before are hashed in the background
+	// while the run goes on; hashes is owned by that goroutine until
+	// hashed is closed.
+	hashes   map[string]string
+	stopHash chan struct{}
+	hashed   chan struct{}
+}
+
+// Start snapshots the sizes and mtimes of the files in workDir and returns
+// a Tracker for a run in it. Only metadata is read before the run; file
+// contents are hashed in the background.
+func Start(workDir string) *Tracker {
+	t := &Tracker{
+		workDir:  workDir,
+		started:  time.Now().UTC(),
+		before:   scan(workDir),
+		tools:    make(map[string][]string),
+		now:      time.Now,
+		hashes:   make(map[string]string),
+		stopHash: make(chan struct{}),
+		hashed:   make(chan struct{}),
+	}
+	go t.hashBefore()
+	return t
+}
+
+// hashBefore hashes the files of the before snapshot. A file whose size or
+// mtime no longer matches the snapshot was changed by the run already and
+// is left unhashed, so it is compared by size and mtime instead.
+func (t *Tracker) hashBefore() {
+	defer close(t.hashed)
+	for rel, state := range t.before.files {
+		select {
+		case <-t.stopHash:
+			return
+		default:
+		}
+		if state.size > maxHashSize {
+			continue
+		}
+		path := filepath.Join(t.workDir, filepath.FromSlash(rel))
+		hash := hashFile(path)
+		if hash == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err != nil || !state.sameStat(info) {
+			continue
+		}
+		t.hashes[rel] = hash
+	}
+}
+
+// Observe records the files and commands of the tool calls in a stream
+// event, and the session ID of the run.
+func (t *Tracker) Observe(ev claude.ParsedEvent) {
+	if ev.System != nil && t.sessionID == "" {
+		t.sessionID = ev.System.SessionID
+	}
+	if ev.Assistant == nil {
+		return
+	}
+	for _, block := range ev.Assistant.Message.Content {
+		if block.Type != "tool_use" {
+			continue
+		}
+		input, err := claude.DecodeToolInput(block.Name, block.Input)
+		if err != nil || input == nil {
+			continue
+		}
+		if bash, ok := input.(*claude.BashInput); ok {
+			if MayChangeFiles(bash.Command) {
+				t.commands = append(t.commands, Command{ToolUseID: block.ID, Command: bash.Command})
+			}
+			continue
+		}
+		if !editTools[block.Name] {
+			continue
+		}
+		for _, p := range input.Files() {
+			path := t.relPath(p)
+			if !contains(t.tools[path], block.Name) {
+				t.tools[path] = append(t.tools[path], block.Name)
+			}
+		}
+	}
+}
+
+// Finish snapshots the working directory again and returns the changeset.
+// Files whose size is unchanged but whose mtime moved are hashed to tell
+// rewrites with the same content from real changes.
+func (t *Tracker) Finish() Changeset {
+	close(t.stopHash)
+	<-t.hashed
+	after := scan(t.workDir)
+	cs := Changeset{
+		SessionID:  t.sessionID,
+		WorkDir:    t.workDir,
+		StartedAt:  t.started,
+		FinishedAt: t.now().UTC(),
+		Files:      []File{},
+		Commands:   t.commands,
+		Partial:    t.before.partial || after.partial,
+	}
+
+	actions := make(map[string]string)
+	for path, state := range after.files {
+		old, ok := t.before.files[path]
+		switch {
+		case !ok:
+			actions[path] = ActionCreated
+		case !t.sameContent(path, old, state):
+			actions[path] = ActionModified
+		}
+	}
+	for path := range t.before.files {
+		if _, ok := after.files[path]; !ok {
+			actions[path] = ActionDeleted
+		}
+	}
+
+	for path, action := range actions {
+		cs.Files = append(cs.Files, File{Path: path, Action: action, Tools: t.tools[path]})
+	}
+	for path, tools := range t.tools {
+		if _, ok := actions[path]; !ok {
+			cs.Files = append(cs.Files, File{Path: path, Tools: tools})
+		}
+	}
+	sort.Slice(cs.Files, func(i, j int) bool { return cs.Files[i].Path < cs.Files[j].Path })
+	return cs
+}
+
+// ExistedBefore reports whether the file at p existed when the run started.
+// known is false when the snapshot taken then does not cover the file: it
+// lies outside the working directory or in a skipped directory, the scan
+// was cut short, or git ignores it.
+func (t *Tracker) ExistedBefore(p string) (existed bool, known bool) {
+	rel := t.relPath(p)
+	if filepath.IsAbs(rel) {
+		return false, false
+	}
+	if _, ok := t.before.files[rel]; ok {
+		return true, true
+	}
+	if t.before.partial || inSkipDir(rel) {
+		return false, false
+	}
+	if t.before.git {
+		// Only a file git lists now would have been listed before
+		paths, err := git.Open(t.workDir).Files(context.Background())
+		if err != nil || !contains(paths, rel) {
+			return false, false
+		}
+	}
+	return false, true
+}
+
+// relPath returns p relative to the working directory with forward
+// slashes, or cleaned and absolute if it is outside.
+func (t *Tracker) relPath(p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(t.workDir, p)
+	}
+	p = filepath.Clean(p)
+	rel, err := filepath.Rel(t.workDir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+	return filepath.ToSlash(rel)
+}
+
+// sameContent reports whether a file has the same content after the run
+// as before. Equal size and mtime count as unchanged; a file whose mtime
+// moved is hashed again if its earlier hash is known.
+func (t *Tracker) sameContent(rel string, before fileState, after fileState) bool {
+	if before.size != after.size {
+		return false
+	}
+	if before.modTime.Equal(after.modTime) {
+		return true
+	}
+	hash, ok := t.hashes[rel]
+	if !ok {
+		return false
+	}
+	return hashFile(filepath.Join(t.workDir, filepath.FromSlash(rel))) == hash
+}
+
+// fileState is a file as seen by a snapshot.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileState) sameStat(info fs.FileInfo) bool {
+	return s.size == info.Size() && s.modTime.Equal(info.ModTime())
+}
+
+// snapshot is the state of the regular files below a directory, keyed by
+// slash-separated relative path.
+type snapshot struct {
+	files   map[string]fileState
+	partial bool
+	git     bool // Listed by git, so ignored files are left out
+}
+
+// scan snapshots the size and mtime of the regular files below root. In a
+// git repository it takes the file list from git, which leaves out ignored
+// files; elsewhere it walks the directory. A missing or unreadable root
+// yields an empty snapshot.
+func scan(root string) snapshot {
+	if paths, err := git.Open(root).Files(context.Background()); err == nil {
+		snap := scanPaths(root, paths)
+		snap.git = true
+		return snap
+	}
+	return walk(root)
+}
+
+// scanPaths snapshots the given paths, relative to root with forward
+// slashes.
+func scanPaths(root string, paths []string) snapshot {
+	snap := snapshot{files: make(map[string]fileState)}
+	for _, rel := range paths {
+		if inSkipDir(rel) {
+			continue
+		}
+		if len(snap.files) >= maxScanFiles {
+			snap.partial = true
+			break
+		}
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		snap.files[rel] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+	return snap
+}
+
+// inSkipDir reports whether the slash-separated path lies in one of
+// skipDirs.
+func inSkipDir(rel string) bool {
+	dirs := strings.Split(rel, "/")
+	for _, dir := range dirs[:len(dirs)-1] {
+		if skipDirs[dir] {
+			return true
+		}
+	}
+	return false
+}
+
+// walk snapshots the regular files below root by walking the directory.
+func walk(root string) snapshot {
+	snap := snapshot{files: make(map[string]fileState)}
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && skipDirs[d.Name()] {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(snap.files) >= maxScanFiles {
+			snap.partial = true
+			return fs.SkipAll
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		snap.files[filepath.ToSlash(rel)] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return snap
+}
+
+// hashFile returns the hex SHA-256 of a file, or "" if it cannot be read.
+func hashFile(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
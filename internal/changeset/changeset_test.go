@@ -0,0 +1,225 @@
+package changeset
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/claude/claudetest"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTracker(t *testing.T) {
+	work := t.TempDir()
+	writeFile(t, work, "main.go", "package main\n")
+	writeFile(t, work, "old.txt", "old\n")
+	writeFile(t, work, "same.txt", "same\n")
+	writeFile(t, work, "failed.go", "untouched\n")
+	writeFile(t, work, ".git/index", "before")
+	writeFile(t, work, "node_modules/x/index.js", "before")
+
+	tr := Start(work)
+	// Hashing normally finishes while the CLI starts up
+	<-tr.hashed
+	tr.Observe(claudetest.Parse(t, `{"type":"system","subtype":"init","session_id":"s1"}`))
+	tr.Observe(claudetest.ToolUse(t, "t1", "Edit", map[string]interface{}{"file_path": filepath.Join(work, "main.go"), "old_string": "a", "new_string": "b"}))
+	tr.Observe(claudetest.ToolUse(t, "t2", "Write", map[string]interface{}{"file_path": "sub/new.go", "content": "package sub"}))
+	tr.Observe(claudetest.ToolUse(t, "t3", "MultiEdit", map[string]interface{}{"file_path": filepath.Join(work, "main.go"), "edits": []interface{}{}}))
+	tr.Observe(claudetest.ToolUse(t, "t4", "Edit", map[string]interface{}{"file_path": filepath.Join(work, "failed.go"), "old_string": "a", "new_string": "b"}))
+	tr.Observe(claudetest.ToolUse(t, "t5", "Bash", map[string]interface{}{"command": "rm old.txt"}))
+	tr.Observe(claudetest.ToolUse(t, "t6", "Bash", map[string]interface{}{"command": "go test ./..."}))
+	tr.Observe(claudetest.ToolUse(t, "t7", "Read", map[string]interface{}{"file_path": filepath.Join(work, "same.txt")}))
+
+	writeFile(t, work, "main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, work, "sub/new.go", "package sub")
+	os.Remove(filepath.Join(work, "old.txt"))
+	// Rewritten with the same content: not a change
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(work, "same.txt"), later, later)
+	writeFile(t, work, ".git/index", "after")
+	writeFile(t, work, "node_modules/x/index.js", "after")
+
+	cs := tr.Finish()
+	if cs.SessionID != "s1" || cs.WorkDir != work || cs.Partial || cs.FinishedAt.Before(cs.StartedAt) {
+		t.Errorf("changeset = %+v", cs)
+	}
+	want := []File{
+		{Path: "failed.go", Tools: []string{"Edit"}},
+		{Path: "main.go", Action: ActionModified, Tools: []string{"Edit", "MultiEdit"}},
+		{Path: "old.txt", Action: ActionDeleted},
+		{Path: "sub/new.go", Action: ActionCreated, Tools: []string{"Write"}},
+	}
+	if len(cs.Files) != len(want) {
+		t.Fatalf("Files = %+v, want %+v", cs.Files, want)
+	}
+	for i, f := range cs.Files {
+		if f.Path != want[i].Path || f.Action != want[i].Action || len(f.Tools) != len(want[i].Tools) {
+			t.Errorf("Files[%d] = %+v, want %+v", i, f, want[i])
+			continue
+		}
+		for j := range f.Tools {
+			if f.Tools[j] != want[i].Tools[j] {
+				t.Errorf("Files[%d].Tools = %v, want %v", i, f.Tools, want[i].Tools)
+			}
+		}
+	}
+	if len(cs.Commands) != 1 || cs.Commands[0].ToolUseID != "t5" || cs.Commands[0].Command != "rm old.txt" {
+		t.Errorf("Commands = %+v, want the rm command only", cs.Commands)
+	}
+}
+
+func TestTracker_EditedBeforeHashing(t *testing.T) {
+	work := t.TempDir()
+	writeFile(t, work, "a.txt", "before\n")
+	tr := &Tracker{workDir: work, before: scan(work), tools: map[string][]string{}, now: time.Now,
+		hashes: map[string]string{}, stopHash: make(chan struct{}), hashed: make(chan struct{})}
+
+	// The run rewrites the file, same size, before it was hashed; the new
+	// content must not be taken for the old
+	writeFile(t, work, "a.txt", "after!\n")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(work, "a.txt"), later, later)
+	go tr.hashBefore()
+
+	cs := tr.Finish()
+	if _, ok := tr.hashes["a.txt"]; ok {
+		t.Error("hashed the content written by the run")
+	}
+	if len(cs.Files) != 1 || cs.Files[0].Action != ActionModified {
+		t.Errorf("Files = %+v, want a.txt modified", cs.Files)
+	}
+}
+
+func TestTracker_HonoursGitignore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	work := t.TempDir()
+	if out, err := exec.Command("git", "-C", work, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	writeFile(t, work, ".gitignore", "target/\n")
+	writeFile(t, work, "src/main.rs", "fn main() {}\n")
+	writeFile(t, work, "target/debug/app", "binary")
+
+	tr := Start(work)
+	if _, ok := tr.before.files["target/debug/app"]; ok {
+		t.Error("ignored file was scanned")
+	}
+	writeFile(t, work, "src/main.rs", "fn main() { run() }\n")
+	writeFile(t, work, "target/debug/app", "rebuilt binary")
+	writeFile(t, work, "src/lib.rs", "pub fn run() {}\n")
+
+	for path, want := range map[string][2]bool{
+		"src/main.rs":      {true, true},
+		"src/lib.rs":       {false, true},
+		"target/debug/app": {false, false}, // Ignored, so not scanned
+	} {
+		existed, known := tr.ExistedBefore(filepath.Join(work, path))
+		if existed != want[0] || known != want[1] {
+			t.Errorf("ExistedBefore(%s) = %v, %v; want %v, %v", path, existed, known, want[0], want[1])
+		}
+	}
+
+	cs := tr.Finish()
+	var got []string
+	for _, f := range cs.Files {
+		got = append(got, f.Path+":"+f.Action)
+	}
+	if strings.Join(got, ",") != "src/lib.rs:created,src/main.rs:modified" {
+		t.Errorf("Files = %v, want the source changes only", got)
+	}
+}
+
+func TestTracker_OutsideWorkDir(t *testing.T) {
+	work := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "notes.md")
+
+	tr := Start(work)
+	tr.Observe(claudetest.ToolUse(t, "t1", "Write", map[string]interface{}{"file_path": outside, "content": "x"}))
+	cs := tr.Finish()
+	if len(cs.Files) != 1 || cs.Files[0].Path != outside || cs.Files[0].Action != "" {
+		t.Errorf("Files = %+v, want the absolute outside path without action", cs.Files)
+	}
+}
+
+func TestTracker_ExistedBefore(t *testing.T) {
+	work := t.TempDir()
+	writeFile(t, work, "old.txt", "x")
+	writeFile(t, work, "node_modules/pkg/index.js", "x")
+
+	tr := Start(work)
+	writeFile(t, work, "new.txt", "x")
+	for path, want := range map[string][2]bool{
+		filepath.Join(work, "old.txt"): {true, true},
+		"new.txt":                      {false, true},
+		filepath.Join(work, "node_modules/pkg/index.js"): {false, false},
+		filepath.Join(t.TempDir(), "outside.txt"):        {false, false},
+	} {
+		existed, known := tr.ExistedBefore(path)
+		if existed != want[0] || known != want[1] {
+			t.Errorf("ExistedBefore(%s) = %v, %v; want %v, %v", path, existed, known, want[0], want[1])
+		}
+	}
+	tr.Finish()
+}
+
+func TestTracker_MissingWorkDir(t *testing.T) {
+	tr := Start(filepath.Join(t.TempDir(), "missing"))
+	if cs := tr.Finish(); len(cs.Files) != 0 {
+		t.Errorf("Files = %+v, want none", cs.Files)
+	}
+}
+
+func TestMayChangeFiles(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"ls -la", false},
+		{"go test ./... 2>&1 | head", false},
+		{"cat main.go > /dev/null", false},
+		{"grep -rn foo . | sort", false},
+		{"git status && git diff", false},
+		{"git -C sub log", false},
+		{"sed -n 1,10p file", false},
+		{"echo hi > out.txt", true},
+		{"echo hi >>log.txt", true},
+		{"cd sub && rm -rf build", true},
+		{"FOO=1 sudo /bin/mv a b", true},
+		{"git checkout -- main.go", true},
+		{"git --no-pager stash", true},
+		{"sed -i 's/a/b/' main.go", true},
+		{"sed -i.bak 's/a/b/' main.go", true},
+		{"npm install left-pad", true},
+		{"go mod tidy", true},
+		{"tar -xzf archive.tgz", true},
+		{"tar xzf archive.tgz", true},
+		{"tar -zxf archive.tgz", true},
+		{"tar --extract --file=archive.tgz", true},
+		{"tar -tzf archive.tgz --exclude=x", false},
+		{"sed -ni 's/a/b/p' main.go", true},
+		{"prettier --write src", true},
+		{"go build ./... ; gofmt -l .", true}, // gofmt always counts
+	}
+	for _, tt := range tests {
+		if got := MayChangeFiles(tt.command); got != tt.want {
+			t.Errorf("MayChangeFiles(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
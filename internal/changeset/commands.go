@@ -0,0 +1,158 @@
+package changeset
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// mutatingCommands are programs that change files whatever their
+// arguments.
+var mutatingCommands = map[string]bool{
+	"rm": true, "rmdir": true, "mv": true, "cp": true, "mkdir": true,
+	"touch": true, "ln": true, "chmod": true, "chown": true, "truncate": true,
+	"dd": true, "tee": true, "patch": true, "unzip": true, "install": true,
+	"rsync": true, "gofmt": true, "goimports": true, "prettier": true,
+	"black": true, "rustfmt": true,
+}
+
+// mutatingSubcommands are subcommands that change files, by program.
+var mutatingSubcommands = map[string]map[string]bool{
+	"git": {
+		"add": true, "am": true, "apply": true, "checkout": true, "cherry-pick": true,
+		"clean": true, "commit": true, "merge": true, "mv": true, "pull": true,
+		"rebase": true, "reset": true, "restore": true, "revert": true, "rm": true,
+		"stash": true, "switch": true,
+	},
+	"go":    {"generate": true, "get": true, "mod": true, "fmt": true},
+	"npm":   {"install": true, "i": true, "ci": true, "uninstall": true, "update": true, "init": true},
+	"yarn":  {"add": true, "install": true, "remove": true, "upgrade": true},
+	"pnpm":  {"add": true, "install": true, "i": true, "remove": true, "update": true},
+	"cargo": {"add": true, "fmt": true, "fix": true, "new": true, "init": true, "remove": true},
+	"pip":   {"install": true, "uninstall": true},
+}
+
+// mutatingFlags are flags that make an otherwise reading program write,
+// by program.
+var mutatingFlags = map[string][]string{
+	"sed":      {"-i", "--in-place"},
+	"perl":     {"-i"},
+	"tar":      {"-x", "--extract", "--get"},
+	"eslint":   {"--fix"},
+	"prettier": {"--write"},
+	"ruff":     {"--fix", "format"},
+}
+
+// dashlessFlags are programs whose first argument may bundle short flags
+// without a dash, as in "tar xzf archive.tgz".
+var dashlessFlags = map[string]bool{"tar": true}
+
+// MayChangeFiles reports whether a shell command may change files: it
+// redirects output into a file or runs a program known to write. The check
+// is a heuristic on the command text; commands it does not know, like
+// build scripts, are reported as not changing files.
+func MayChangeFiles(command string) bool {
+	for _, segment := range splitCommands(command) {
+		words := strings.Fields(segment)
+		if writesRedirect(words) {
+			return true
+		}
+		words = skipPrefixes(words)
+		if len(words) == 0 {
+			continue
+		}
+		name := filepath.Base(words[0])
+		if mutatingCommands[name] {
+			return true
+		}
+		if subs := mutatingSubcommands[name]; subs != nil {
+			for _, w := range words[1:] {
+				if strings.HasPrefix(w, "-") {
+					continue
+				}
+				if subs[w] {
+					return true
+				}
+				break
+			}
+		}
+		args := words[1:]
+		if dashlessFlags[name] && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			args = append([]string{"-" + args[0]}, args[1:]...)
+		}
+		for _, flag := range mutatingFlags[name] {
+			for _, w := range args {
+				if hasFlag(w, flag) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasFlag reports whether the word w sets flag: it is the flag itself, the
+// flag with a value attached ("-i.bak", "--in-place=.bak"), or, for a
+// single-letter flag, a bundle of short flags that contains it ("-zxf").
+func hasFlag(w, flag string) bool {
+	switch {
+	case w == flag:
+		return true
+	case strings.HasPrefix(flag, "--"):
+		return strings.HasPrefix(w, flag+"=")
+	case len(flag) == 2 && flag[0] == '-' && strings.HasPrefix(w, "-") && !strings.HasPrefix(w, "--"):
+		for _, c := range w[1:] {
+			if c == rune(flag[1]) {
+				return true
+			}
+			if !unicode.IsLetter(c) {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// splitCommands splits a command line at the shell's command separators.
+// Quoting is not honoured, which at worst reports a harmless command.
+func splitCommands(command string) []string {
+	return strings.FieldsFunc(command, func(r rune) bool {
+		return r == ';' || r == '&' || r == '|' || r == '\n' || r == '(' || r == ')'
+	})
+}
+
+// writesRedirect reports whether words redirect output into a file other
+// than /dev/null.
+func writesRedirect(words []string) bool {
+	for i, w := range words {
+		idx := strings.Index(w, ">")
+		if idx < 0 {
+			continue
+		}
+		target := strings.TrimLeft(w[idx:], ">")
+		if target == "" && i+1 < len(words) {
+			target = words[i+1]
+		}
+		if target != "" && target != "/dev/null" && !strings.HasPrefix(target, "&") {
+			return true
+		}
+	}
+	return false
+}
+
+// skipPrefixes drops environment assignments and wrappers like sudo that
+// precede the program name.
+func skipPrefixes(words []string) []string {
+	for len(words) > 0 {
+		w := words[0]
+		switch {
+		case w == "sudo" || w == "env" || w == "time" || w == "nice" || w == "command":
+			words = words[1:]
+		case strings.Contains(w, "=") && !strings.HasPrefix(w, "-"):
+			words = words[1:]
+		default:
+			return words
+		}
+	}
+	return words
+}
@@ -0,0 +1,185 @@
+package changeset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Marcel-Bich/dogma/internal/atomicfile"
+	"github.com/Marcel-Bich/dogma/internal/claude"
+)
+
+// maxPerSession bounds the changesets kept per session; the oldest are
+// dropped first. Files may grow to twice the bound before they are cut
+// back, so most additions are a plain append.
+const maxPerSession = 500
+
+// Store keeps the changesets of all sessions, one JSONL file per session
+// below Dir. With an empty Dir they are kept in memory only.
+type Store struct {
+	Dir string
+
+	mu     sync.Mutex
+	mem    map[string][]Changeset // Used when Dir is empty
+	counts map[string]int         // Lines per session file, once known
+}
+
+// NewStore creates a Store that keeps changesets in dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Add records a changeset under its session ID by appending it to the
+// session's file.
+func (s *Store) Add(cs Changeset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.sessionFile(cs.SessionID)
+	if err != nil {
+		return err
+	}
+	if s.Dir == "" {
+		if s.mem == nil {
+			s.mem = make(map[string][]Changeset)
+		}
+		list := append(s.mem[cs.SessionID], cs)
+		if len(list) > maxPerSession {
+			list = list[len(list)-maxPerSession:]
+		}
+		s.mem[cs.SessionID] = list
+		return nil
+	}
+
+	line, err := json.Marshal(cs)
+	if err != nil {
+		return fmt.Errorf("encode changeset: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("write changesets: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("write changesets: %w", err)
+	}
+	// Start a new line after one cut short by a crash
+	if info, statErr := f.Stat(); statErr == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, readErr := f.ReadAt(last, info.Size()-1); readErr == nil && last[0] != '\n' {
+			line = append([]byte{'\n'}, line...)
+		}
+	}
+	_, err = f.Write(append(line, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write changesets: %w", err)
+	}
+
+	if s.counts == nil {
+		s.counts = make(map[string]int)
+	}
+	count, ok := s.counts[cs.SessionID]
+	if !ok {
+		list, err := s.load(path)
+		if err != nil {
+			return err
+		}
+		count = len(list)
+	} else {
+		count++
+	}
+	s.counts[cs.SessionID] = count
+	if count > 2*maxPerSession {
+		return s.trim(cs.SessionID, path)
+	}
+	return nil
+}
+
+// List returns the changesets of a session, oldest first.
+func (s *Store) List(sessionID string) ([]Changeset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path, err := s.sessionFile(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Dir == "" {
+		return append([]Changeset{}, s.mem[sessionID]...), nil
+	}
+	list, err := s.load(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) > maxPerSession {
+		list = list[len(list)-maxPerSession:]
+	}
+	return list, nil
+}
+
+// trim rewrites a session file with its latest maxPerSession changesets.
+func (s *Store) trim(sessionID string, path string) error {
+	list, err := s.load(path)
+	if err != nil {
+		return err
+	}
+	if len(list) > maxPerSession {
+		list = list[len(list)-maxPerSession:]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, cs := range list {
+		if err := enc.Encode(cs); err != nil {
+			return fmt.Errorf("encode changesets: %w", err)
+		}
+	}
+	if err := atomicfile.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("write changesets: %w", err)
+	}
+	s.counts[sessionID] = len(list)
+	return nil
+}
+
+// load reads all changesets of a session file. Lines that do not parse,
+// such as one cut short by a crash, are skipped.
+func (s *Store) load(path string) ([]Changeset, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []Changeset{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read changesets: %w", err)
+	}
+	defer f.Close()
+
+	list := []Changeset{}
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			var cs Changeset
+			if json.Unmarshal(line, &cs) == nil {
+				list = append(list, cs)
+			}
+		}
+		if err == io.EOF {
+			return list, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read changesets: %w", err)
+		}
+	}
+}
+
+// sessionFile returns the changeset file of a session.
+func (s *Store) sessionFile(sessionID string) (string, error) {
+	if err := claude.ValidateSessionID(sessionID); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, sessionID+".jsonl"), nil
+}
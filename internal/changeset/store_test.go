@@ -0,0 +1,84 @@
+package changeset
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	for name, dir := range map[string]string{"disk": t.TempDir(), "memory": ""} {
+		t.Run(name, func(t *testing.T) {
+			s := NewStore(dir)
+			if list, err := s.List("s1"); err != nil || len(list) != 0 {
+				t.Fatalf("List(empty) = %+v, %v", list, err)
+			}
+			for _, req := range []string{"r1", "r2"} {
+				if err := s.Add(Changeset{SessionID: "s1", RequestID: req, Files: []File{{Path: "a.go", Action: ActionModified}}}); err != nil {
+					t.Fatalf("Add() error = %v", err)
+				}
+			}
+			if err := s.Add(Changeset{SessionID: "s2", RequestID: "other"}); err != nil {
+				t.Fatal(err)
+			}
+
+			list, err := s.List("s1")
+			if err != nil || len(list) != 2 || list[0].RequestID != "r1" || list[1].Files[0].Path != "a.go" {
+				t.Errorf("List(s1) = %+v, %v", list, err)
+			}
+			if err := s.Add(Changeset{SessionID: "../x"}); err == nil {
+				t.Error("Add(invalid session) error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestStore_KeepsLatest(t *testing.T) {
+	for name, dir := range map[string]string{"disk": t.TempDir(), "memory": ""} {
+		t.Run(name, func(t *testing.T) {
+			s := NewStore(dir)
+			n := 2*maxPerSession + 5
+			for i := 0; i < n; i++ {
+				if err := s.Add(Changeset{SessionID: "s1", RequestID: fmt.Sprint(i), Files: []File{}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			list, _ := s.List("s1")
+			if len(list) != maxPerSession || list[len(list)-1].RequestID != fmt.Sprint(n-1) {
+				t.Errorf("List() = %d changesets, want the latest %d", len(list), maxPerSession)
+			}
+			if dir == "" {
+				return
+			}
+			// The file was cut back once it passed twice the bound
+			data, err := os.ReadFile(filepath.Join(dir, "s1.jsonl"))
+			if lines := bytes.Count(data, []byte("\n")); err != nil || lines > 2*maxPerSession {
+				t.Errorf("file holds %d lines, %v", lines, err)
+			}
+		})
+	}
+}
+
+func TestStore_AppendsLines(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	for _, req := range []string{"r1", "r2"} {
+		if err := s.Add(Changeset{SessionID: "s1", RequestID: req}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A line cut short by a crash is skipped, and later ones still count
+	f, _ := os.OpenFile(filepath.Join(dir, "s1.jsonl"), os.O_APPEND|os.O_WRONLY, 0600)
+	f.WriteString(`{"request_id":"tor`)
+	f.Close()
+	s = NewStore(dir)
+	if err := s.Add(Changeset{SessionID: "s1", RequestID: "r3"}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.List("s1")
+	if err != nil || len(list) != 3 || list[1].RequestID != "r2" || list[2].RequestID != "r3" {
+		t.Errorf("List() = %+v, %v", list, err)
+	}
+}
@@ -0,0 +1,457 @@
+// Package checkpoint snapshots the files Claude edits, so the changes of a
+// session can be rolled back turn by turn without git. Snapshots are taken
+// when an edit tool call is announced on the stream. The CLI does not wait
+// for that, so a fast edit can land first; such snapshots are marked Late.
+//
+// Checkpoints of a session live in <Dir>/<session ID>/. turns.json lists
+// each turn that edited files together with the state of those files before
+// the turn, and blobs/ holds the file contents by SHA-256, shared between
+// turns.
+package checkpoint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Marcel-Bich/dogma/internal/atomicfile"
+	"github.com/Marcel-Bich/dogma/internal/claude"
+)
+
+// maxFileSize is the largest file that is snapshotted. Larger files are
+// left out of checkpoints.
+const maxFileSize = 16 << 20
+
+// editTools are the tools whose target files are snapshotted.
+var editTools = map[string]bool{"Write": true, "Edit": true, "MultiEdit": true, "NotebookEdit": true}
+
+// Change actions reported by Preview and Restore.
+const (
+	ActionModify = "modify"
+	ActionCreate = "create"
+	ActionDelete = "delete"
+	ActionNone   = "none" // The file already matches a Late snapshot; restoring may not undo the edit
+)
+
+// File is the state of a file before the first edit of a turn, as far as
+// the snapshot could tell; see Late.
+type File struct {
+	Path      string      `json:"path"`
+	Existed   bool        `json:"existed"`        // False if the turn created the file
+	Hash      string      `json:"hash,omitempty"` // Blob holding the content; empty if the file did not exist
+	Mode      os.FileMode `json:"mode,omitempty"` // Permission bits of the file
+	Tool      string      `json:"tool"`           // Tool call that triggered the snapshot
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	// Late is set when the file was modified after the run started and
+	// before the snapshot, which may then already hold the edit.
+	Late bool `json:"late,omitempty"`
+}
+
+// Checkpoint holds the files one turn edited, as they were before the turn.
+type Checkpoint struct {
+	Turn      int       `json:"turn"` // 1-based, in the order the turns ran
+	Prompt    string    `json:"prompt"`
+	CreatedAt time.Time `json:"created_at"`
+	Files     []File    `json:"files"`
+}
+
+// Change is the effect restoring a checkpoint has on one file.
+type Change struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`         // ActionModify, ActionCreate or ActionDelete
+	Diff   string `json:"diff,omitempty"` // From the current to the restored content; empty for binary files
+	Late   bool   `json:"late,omitempty"` // Restored from a Late snapshot
+}
+
+// Store keeps the checkpoints of all sessions below Dir.
+type Store struct {
+	Dir string
+
+	mu  sync.Mutex
+	now func() time.Time // For testing; defaults to time.Now
+}
+
+// NewStore creates a Store that keeps checkpoints in dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Recorder snapshots the files edited during one prompt run. All snapshots
+// of a run form one turn, and each file is snapshotted once per turn, when
+// its first edit is announced.
+type Recorder struct {
+	// ExistedBefore, if set, reports whether a file existed when the run
+	// started and whether that is known. A Late snapshot of a file that did
+	// not exist then is recorded as created by the turn.
+	ExistedBefore func(path string) (existed bool, known bool)
+
+	store     *Store
+	workDir   string
+	prompt    string
+	sessionID string
+	startedAt time.Time
+	turn      int // 0 until the first snapshot
+	seen      map[string]bool
+}
+
+// NewRecorder returns a Recorder for a run in workDir. sessionID may be
+// empty for new sessions; it is then taken from the run's init event.
+// Only files inside workDir are snapshotted.
+func (s *Store) NewRecorder(workDir string, sessionID string, prompt string) *Recorder {
+	return &Recorder{store: s, workDir: workDir, prompt: prompt, sessionID: sessionID, startedAt: s.clock(), seen: make(map[string]bool)}
+}
+
+// Observe inspects a stream event and snapshots the target files of edit
+// tool calls. The CLI announces a tool call before running it but does not
+// wait for the event to be read, so a fast edit can land before the
+// snapshot. Files modified since the run started are therefore marked Late.
+func (r *Recorder) Observe(ev claude.ParsedEvent) error {
+	if ev.System != nil && r.sessionID == "" {
+		r.sessionID = ev.System.SessionID
+	}
+	if ev.Assistant == nil {
+		return nil
+	}
+	var errs []error
+	for _, block := range ev.Assistant.Message.Content {
+		if block.Type != "tool_use" || !editTools[block.Name] {
+			continue
+		}
+		input, err := claude.DecodeToolInput(block.Name, block.Input)
+		if err != nil || input == nil {
+			continue
+		}
+		if err := r.snapshot(block.Name, block.ID, input.Files()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// snapshot records the current state of paths that were not yet recorded
+// in this turn.
+func (r *Recorder) snapshot(tool string, toolUseID string, paths []string) error {
+	if r.sessionID == "" {
+		return fmt.Errorf("snapshot %s: session id not known yet", tool)
+	}
+	var files []File
+	for _, p := range paths {
+		path, ok := r.resolve(p)
+		if !ok || r.seen[path] {
+			continue
+		}
+		f, err := r.store.capture(r.sessionID, path, r.startedAt)
+		if err != nil {
+			return err
+		}
+		r.seen[path] = true
+		if f == nil {
+			continue
+		}
+		if f.Late && f.Existed && r.ExistedBefore != nil {
+			if existed, known := r.ExistedBefore(path); known && !existed {
+				f = &File{Path: path, Late: true}
+			}
+		}
+		f.Tool, f.ToolUseID = tool, toolUseID
+		files = append(files, *f)
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	turn, err := r.store.addFiles(r.sessionID, r.turn, r.prompt, files)
+	if err != nil {
+		return err
+	}
+	r.turn = turn
+	return nil
+}
+
+// resolve returns the absolute, cleaned form of p if it lies inside the
+// working directory.
+func (r *Recorder) resolve(p string) (string, bool) {
+	if r.workDir == "" || p == "" {
+		return "", false
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(r.workDir, p)
+	}
+	p = filepath.Clean(p)
+	rel, err := filepath.Rel(r.workDir, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return p, true
+}
+
+// List returns the checkpoints of a session in turn order.
+func (s *Store) List(sessionID string) ([]Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(sessionID)
+}
+
+// Preview returns the changes Restore would make for the given turn
+// without touching any file.
+func (s *Store) Preview(sessionID string, turn int) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes, _, err := s.plan(sessionID, turn)
+	return changes, err
+}
+
+// Restore puts every file edited in the given turn or later back into the
+// state it had before the turn, permissions included. The current state of
+// those files is first saved as a new checkpoint, so a restore can itself
+// be undone. Files with ActionNone are reported but left alone.
+func (s *Store) Restore(sessionID string, turn int) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes, targets, err := s.plan(sessionID, turn)
+	if err != nil {
+		return nil, err
+	}
+
+	var before []File
+	for _, c := range changes {
+		if c.Action == ActionNone {
+			continue
+		}
+		f, err := s.capture(sessionID, c.Path, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			return nil, fmt.Errorf("restore %s: file exceeds %d bytes", c.Path, maxFileSize)
+		}
+		f.Tool = "Restore"
+		before = append(before, *f)
+	}
+	if len(before) == 0 {
+		return changes, nil
+	}
+	if _, err := s.addFilesLocked(sessionID, 0, fmt.Sprintf("Before restoring turn %d", turn), before); err != nil {
+		return nil, err
+	}
+
+	for _, c := range changes {
+		target := targets[c.Path]
+		if c.Action == ActionNone {
+			continue
+		}
+		if !target.Existed {
+			if err := os.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("restore %s: %w", c.Path, err)
+			}
+			continue
+		}
+		data, err := s.readBlob(sessionID, target.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", c.Path, err)
+		}
+		mode := target.Mode.Perm()
+		if mode == 0 {
+			// Snapshots taken before modes were recorded
+			mode = 0644
+		}
+		if err := os.WriteFile(c.Path, data, mode); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", c.Path, err)
+		}
+		// WriteFile only applies the mode to files it creates
+		if err := os.Chmod(c.Path, mode); err != nil {
+			return nil, fmt.Errorf("restore %s: %w", c.Path, err)
+		}
+	}
+	return changes, nil
+}
+
+// plan finds, for every file edited in turn or later, its state before the
+// earliest such turn and compares it with the current content. Files that
+// already match are left out, unless the snapshot is Late: those are kept
+// with ActionNone, as the edit may not have been undone.
+func (s *Store) plan(sessionID string, turn int) ([]Change, map[string]File, error) {
+	checkpoints, err := s.load(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if turn < 1 || turn > len(checkpoints) {
+		return nil, nil, fmt.Errorf("session %s has no checkpoint for turn %d", sessionID, turn)
+	}
+
+	targets := make(map[string]File)
+	var order []string
+	for _, cp := range checkpoints[turn-1:] {
+		for _, f := range cp.Files {
+			if _, ok := targets[f.Path]; !ok {
+				targets[f.Path] = f
+				order = append(order, f.Path)
+			}
+		}
+	}
+
+	changes := []Change{}
+	for _, path := range order {
+		target := targets[path]
+		current, err := os.ReadFile(path)
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		var want []byte
+		if target.Existed {
+			if want, err = s.readBlob(sessionID, target.Hash); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		c := Change{Path: path, Late: target.Late}
+		switch {
+		case !target.Existed && !exists:
+			continue
+		case !target.Existed:
+			c.Action = ActionDelete
+		case !exists:
+			c.Action = ActionCreate
+		case bytes.Equal(current, want):
+			if !target.Late {
+				continue
+			}
+			c.Action = ActionNone
+		default:
+			c.Action = ActionModify
+		}
+		if utf8.Valid(current) && utf8.Valid(want) {
+			c.Diff = claude.UnifiedDiff(path, path, string(current), string(want))
+		}
+		changes = append(changes, c)
+	}
+	return changes, targets, nil
+}
+
+// capture stores the current content of path as a blob. It returns nil
+// for files larger than maxFileSize. Files modified after since are marked
+// Late; a zero since marks none.
+func (s *Store) capture(sessionID string, path string, since time.Time) (*File, error) {
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return &File{Path: path}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	if stat.IsDir() || stat.Size() > maxFileSize {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	dir, err := s.sessionDir(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	blob := filepath.Join(dir, "blobs", hash)
+	if _, err := os.Stat(blob); os.IsNotExist(err) {
+		if err := atomicfile.WriteFile(blob, data, 0600); err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", path, err)
+		}
+	}
+	late := !since.IsZero() && stat.ModTime().After(since)
+	return &File{Path: path, Existed: true, Hash: hash, Mode: stat.Mode().Perm(), Late: late}, nil
+}
+
+// addFiles appends files to a turn, creating the turn when turn is 0, and
+// returns the turn number.
+func (s *Store) addFiles(sessionID string, turn int, prompt string, files []File) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addFilesLocked(sessionID, turn, prompt, files)
+}
+
+func (s *Store) addFilesLocked(sessionID string, turn int, prompt string, files []File) (int, error) {
+	checkpoints, err := s.load(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if turn < 1 || turn > len(checkpoints) {
+		checkpoints = append(checkpoints, Checkpoint{Turn: len(checkpoints) + 1, Prompt: prompt, CreatedAt: s.clock(), Files: []File{}})
+		turn = len(checkpoints)
+	}
+	checkpoints[turn-1].Files = append(checkpoints[turn-1].Files, files...)
+	return turn, s.save(sessionID, checkpoints)
+}
+
+func (s *Store) clock() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+func (s *Store) load(sessionID string) ([]Checkpoint, error) {
+	dir, err := s.sessionDir(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "turns.json"))
+	if os.IsNotExist(err) {
+		return []Checkpoint{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoints: %w", err)
+	}
+	var checkpoints []Checkpoint
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, fmt.Errorf("parse checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+func (s *Store) save(sessionID string, checkpoints []Checkpoint) error {
+	dir, err := s.sessionDir(sessionID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode checkpoints: %w", err)
+	}
+	if err := atomicfile.WriteFile(filepath.Join(dir, "turns.json"), data, 0600); err != nil {
+		return fmt.Errorf("write checkpoints: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) readBlob(sessionID string, hash string) ([]byte, error) {
+	dir, err := s.sessionDir(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "blobs", hash))
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// sessionDir returns the checkpoint directory of a session.
+func (s *Store) sessionDir(sessionID string) (string, error) {
+	if err := claude.ValidateSessionID(sessionID); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Dir, sessionID), nil
+}
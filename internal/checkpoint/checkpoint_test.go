@@ -0,0 +1,243 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/claude/claudetest"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "<missing>"
+	}
+	return string(data)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordTwoTurns edits a.txt in two turns and creates new.txt in the first.
+func recordTwoTurns(t *testing.T) (*Store, string) {
+	t.Helper()
+	work := t.TempDir()
+	store := NewStore(t.TempDir())
+	a := filepath.Join(work, "a.txt")
+	writeFile(t, a, "v1\n")
+
+	r := store.NewRecorder(work, "", "First")
+	if err := r.Observe(claudetest.Parse(t, `{"type":"system","subtype":"init","session_id":"s1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Observe(claudetest.ToolUse(t, "t1", "Edit", map[string]interface{}{"file_path": a, "old_string": "v1", "new_string": "v2"})); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, a, "v2\n")
+	// A second edit of the same file in the turn keeps the first snapshot.
+	if err := r.Observe(claudetest.ToolUse(t, "t2", "Edit", map[string]interface{}{"file_path": a, "old_string": "v2", "new_string": "v2b"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Observe(claudetest.ToolUse(t, "t3", "Write", map[string]interface{}{"file_path": "new.txt", "content": "created"})); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(work, "new.txt"), "created")
+	if err := r.Observe(claudetest.ToolUse(t, "t4", "Write", map[string]interface{}{"file_path": filepath.Join(filepath.Dir(work), "outside.txt"), "content": "x"})); err != nil {
+		t.Fatal(err)
+	}
+
+	r2 := store.NewRecorder(work, "s1", "Second")
+	if err := r2.Observe(claudetest.ToolUse(t, "t5", "MultiEdit", map[string]interface{}{"file_path": a, "edits": []interface{}{}})); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, a, "v3\n")
+	return store, work
+}
+
+func TestRecorder(t *testing.T) {
+	store, work := recordTwoTurns(t)
+
+	checkpoints, err := store.List("s1")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(checkpoints) != 2 {
+		t.Fatalf("List() = %+v, want 2 turns", checkpoints)
+	}
+	first, second := checkpoints[0], checkpoints[1]
+	if first.Turn != 1 || first.Prompt != "First" || len(first.Files) != 2 {
+		t.Fatalf("turn 1 = %+v", first)
+	}
+	if f := first.Files[0]; f.Path != filepath.Join(work, "a.txt") || !f.Existed || f.Tool != "Edit" || f.ToolUseID != "t1" {
+		t.Errorf("turn 1 a.txt = %+v", f)
+	}
+	if f := first.Files[1]; f.Path != filepath.Join(work, "new.txt") || f.Existed || f.Hash != "" {
+		t.Errorf("turn 1 new.txt = %+v, want recorded as not existing", f)
+	}
+	if second.Turn != 2 || len(second.Files) != 1 || second.Files[0].Tool != "MultiEdit" {
+		t.Errorf("turn 2 = %+v", second)
+	}
+	if data, _ := store.readBlob("s1", second.Files[0].Hash); string(data) != "v2\n" {
+		t.Errorf("turn 2 snapshot = %q, want v2", data)
+	}
+}
+
+func TestPreviewAndRestore(t *testing.T) {
+	store, work := recordTwoTurns(t)
+	a, created := filepath.Join(work, "a.txt"), filepath.Join(work, "new.txt")
+
+	preview, err := store.Preview("s1", 2)
+	if err != nil {
+		t.Fatalf("Preview() error = %v", err)
+	}
+	if len(preview) != 1 || preview[0].Action != ActionModify || !strings.Contains(preview[0].Diff, "-v3") || !strings.Contains(preview[0].Diff, "+v2") {
+		t.Errorf("Preview(2) = %+v", preview)
+	}
+	if readFile(t, a) != "v3\n" {
+		t.Error("Preview changed a file")
+	}
+
+	changes, err := store.Restore("s1", 1)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if len(changes) != 2 || changes[0].Action != ActionModify || changes[1].Action != ActionDelete {
+		t.Errorf("Restore(1) = %+v", changes)
+	}
+	if readFile(t, a) != "v1\n" || readFile(t, created) != "<missing>" {
+		t.Errorf("after restore: a.txt = %q, new.txt = %q", readFile(t, a), readFile(t, created))
+	}
+
+	// The restore saved the previous state as turn 3, so it can be undone.
+	checkpoints, _ := store.List("s1")
+	if len(checkpoints) != 3 || checkpoints[2].Prompt != "Before restoring turn 1" {
+		t.Fatalf("List() after restore = %+v", checkpoints)
+	}
+	if _, err := store.Restore("s1", 3); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, a) != "v3\n" || readFile(t, created) != "created" {
+		t.Errorf("after undo: a.txt = %q, new.txt = %q", readFile(t, a), readFile(t, created))
+	}
+
+	// Restoring the state the files are already in changes nothing and
+	// records no checkpoint.
+	if changes, err := store.Restore("s1", 3); err != nil || len(changes) != 0 {
+		t.Errorf("Restore(3) again = %+v, %v; want no changes", changes, err)
+	}
+	if checkpoints, _ := store.List("s1"); len(checkpoints) != 4 {
+		t.Errorf("List() = %d turns, want 4", len(checkpoints))
+	}
+}
+
+func TestRestore_KeepsMode(t *testing.T) {
+	work := t.TempDir()
+	store := NewStore(t.TempDir())
+	script := filepath.Join(work, "run.sh")
+	writeFile(t, script, "#!/bin/sh\necho v1\n")
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	r := store.NewRecorder(work, "s1", "Rewrite")
+	if err := r.Observe(claudetest.ToolUse(t, "t1", "Write", map[string]interface{}{"file_path": script, "content": "x"})); err != nil {
+		t.Fatal(err)
+	}
+	// The edit replaces the file, dropping its executable bit
+	os.Remove(script)
+	writeFile(t, script, "echo v2\n")
+
+	if _, err := store.Restore("s1", 1); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	stat, err := os.Stat(script)
+	if err != nil || stat.Mode().Perm() != 0755 {
+		t.Errorf("mode after restore = %v, %v; want 0755", stat.Mode().Perm(), err)
+	}
+}
+
+func TestRecorder_MarksLateSnapshots(t *testing.T) {
+	work := t.TempDir()
+	store := NewStore(t.TempDir())
+	a := filepath.Join(work, "a.txt")
+	writeFile(t, a, "before\n")
+
+	// The run started an hour ago, so the file changed after its start
+	store.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	r := store.NewRecorder(work, "s1", "Edit")
+	writeFile(t, a, "after\n")
+	if err := r.Observe(claudetest.ToolUse(t, "t1", "Edit", map[string]interface{}{"file_path": a, "old_string": "before", "new_string": "after"})); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints, _ := store.List("s1")
+	if len(checkpoints) != 1 || !checkpoints[0].Files[0].Late {
+		t.Fatalf("List() = %+v, want a late snapshot", checkpoints)
+	}
+	// The snapshot holds the edit, so restoring cannot undo it; it says so
+	// instead of silently reporting nothing to do.
+	changes, err := store.Restore("s1", 1)
+	if err != nil || len(changes) != 1 || changes[0].Action != ActionNone || !changes[0].Late {
+		t.Errorf("Restore() = %+v, %v; want one late no-op", changes, err)
+	}
+	if checkpoints, _ := store.List("s1"); len(checkpoints) != 1 {
+		t.Errorf("Restore() recorded a checkpoint without changing files: %d turns", len(checkpoints))
+	}
+}
+
+func TestRecorder_LateCreatedFile(t *testing.T) {
+	work := t.TempDir()
+	store := NewStore(t.TempDir())
+	created := filepath.Join(work, "new.txt")
+
+	r := store.NewRecorder(work, "s1", "Create")
+	r.ExistedBefore = func(path string) (bool, bool) { return false, path == created }
+	// The Write lands before its tool call is seen
+	later := time.Now().Add(time.Hour)
+	writeFile(t, created, "created")
+	if err := os.Chtimes(created, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Observe(claudetest.ToolUse(t, "t1", "Write", map[string]interface{}{"file_path": created, "content": "created"})); err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoints, _ := store.List("s1")
+	if len(checkpoints) != 1 || checkpoints[0].Files[0].Existed || !checkpoints[0].Files[0].Late {
+		t.Fatalf("List() = %+v, want a late snapshot of a created file", checkpoints)
+	}
+	changes, err := store.Restore("s1", 1)
+	if err != nil || len(changes) != 1 || changes[0].Action != ActionDelete {
+		t.Errorf("Restore() = %+v, %v; want the file deleted", changes, err)
+	}
+	if got := readFile(t, created); got != "<missing>" {
+		t.Errorf("new.txt = %q after restore", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	store, _ := recordTwoTurns(t)
+
+	if _, err := store.Preview("s1", 9); err == nil {
+		t.Error("Preview(unknown turn) error = nil, want error")
+	}
+	if _, err := store.List("../s1"); err == nil {
+		t.Error("List(invalid id) error = nil, want error")
+	}
+	if checkpoints, err := store.List("other"); err != nil || len(checkpoints) != 0 {
+		t.Errorf("List(other) = %+v, %v; want empty", checkpoints, err)
+	}
+
+	r := store.NewRecorder(t.TempDir(), "", "No init")
+	if err := r.Observe(claudetest.ToolUse(t, "t1", "Write", map[string]interface{}{"file_path": "x.txt", "content": "x"})); err == nil {
+		t.Error("Observe() before init error = nil, want error")
+	}
+}
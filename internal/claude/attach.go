@@ -0,0 +1,108 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultTailInterval is how often an attached session file is checked for
+// new entries.
+const defaultTailInterval = 250 * time.Millisecond
+
+// SessionTail follows a session file another process appends to, e.g. a
+// CLI run in a terminal. It only ever reads the file, so the run it
+// follows is never disturbed.
+type SessionTail struct {
+	Interval time.Duration // Poll interval; defaults to defaultTailInterval
+
+	path      string
+	sessionID string
+	offset    int64 // End of the last complete line read
+}
+
+// TailSession opens a session for live tailing. It returns the transcript
+// up to the last complete line and a SessionTail that continues from
+// there, so no entry is missed or reported twice.
+func (sl *SessionLister) TailSession(projectPath string, sessionID string) (*SessionTail, Transcript, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return nil, Transcript{}, err
+	}
+	t := &SessionTail{path: path, sessionID: sessionID}
+	events, summary, err := t.poll()
+	if err != nil {
+		return nil, Transcript{}, err
+	}
+	return t, Transcript{SessionID: sessionID, Summary: summary, Events: events}, nil
+}
+
+// Run calls handler with the events of entries appended to the session
+// file until ctx is done. Every event is marked ReadOnly. Read errors,
+// e.g. while the file is briefly missing, are retried on the next poll.
+func (t *SessionTail) Run(ctx context.Context, handler func(BridgeEvent)) {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = defaultTailInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			events, _, err := t.poll()
+			if err != nil {
+				continue
+			}
+			for _, ev := range events {
+				if ctx.Err() != nil {
+					return
+				}
+				handler(ev)
+			}
+		}
+	}
+}
+
+// poll reads the complete lines added since the last poll and returns
+// their events and the last summary among them. A file that shrank was
+// replaced and is read again from the start.
+func (t *SessionTail) poll() ([]BridgeEvent, string, error) {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return nil, "", fmt.Errorf("open session: %w", err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, "", fmt.Errorf("stat session: %w", err)
+	}
+	if stat.Size() < t.offset {
+		t.offset = 0
+	}
+
+	events := []BridgeEvent{}
+	var summary string
+	end, err := scanCompleteLines(f, t.offset, func(_ int64, line []byte) {
+		var entry sessionEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return
+		}
+		if entry.Type == "summary" {
+			summary = entry.Summary
+		}
+		for _, ev := range entryToBridgeEvents(entry, t.sessionID) {
+			ev.ReadOnly = true
+			events = append(events, ev)
+		}
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("read session: %w", err)
+	}
+	t.offset = end
+	return events, summary, nil
+}
@@ -0,0 +1,100 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTailSession(t *testing.T) {
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(projectDir, "live.jsonl")
+	appendLine := func(s string) {
+		t.Helper()
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+	appendLine(`{"type":"user","uuid":"u1","timestamp":"2026-01-20T10:00:00Z","message":{"role":"user","content":"Run the tests"}}` + "\n")
+	// The CLI is halfway through writing the next entry.
+	appendLine(`{"type":"assistant","uuid":"a1","timestamp":"2026-01-20T10:00:01Z",`)
+
+	sl := NewSessionLister(base)
+	tail, history, err := sl.TailSession("/home/user/project", "live")
+	if err != nil {
+		t.Fatalf("TailSession() error = %v", err)
+	}
+	if len(history.Events) != 1 || history.Events[0].UUID != "u1" || !history.Events[0].ReadOnly {
+		t.Fatalf("history = %+v, want u1 only", history.Events)
+	}
+
+	tail.Interval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan BridgeEvent, 10)
+	done := make(chan struct{})
+	go func() {
+		tail.Run(ctx, func(ev BridgeEvent) { received <- ev })
+		close(done)
+	}()
+
+	appendLine(`"message":{"role":"assistant","model":"claude-sonnet-4-5","content":[{"type":"text","text":"Running"},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}]}}` + "\n")
+	var got []BridgeEvent
+	for len(got) < 2 {
+		select {
+		case ev := <-received:
+			got = append(got, ev)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %+v, want text and tool_use events of a1", got)
+		}
+	}
+	if got[0].Text != "Running" || got[1].ToolName != "Bash" || !got[0].ReadOnly || !got[1].ReadOnly || got[1].SessionID != "live" {
+		t.Errorf("events = %+v", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not stop after cancel")
+	}
+	select {
+	case ev := <-received:
+		t.Errorf("unexpected event %+v", ev)
+	default:
+	}
+}
+
+func TestSessionTail_ReplacedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.jsonl")
+	line := `{"type":"user","uuid":"u1","timestamp":"2026-01-20T10:00:00Z","message":{"role":"user","content":"First"}}` + "\n"
+	if err := os.WriteFile(path, []byte(line+line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tail := &SessionTail{path: path, sessionID: "s"}
+	if events, _, err := tail.poll(); err != nil || len(events) != 2 {
+		t.Fatalf("poll() = %d events, %v", len(events), err)
+	}
+
+	if err := os.WriteFile(path, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if events, _, err := tail.poll(); err != nil || len(events) != 1 {
+		t.Errorf("poll() after replace = %d events, %v; want the new file from the start", len(events), err)
+	}
+}
+
+func TestTailSession_Missing(t *testing.T) {
+	sl := NewSessionLister(t.TempDir())
+	if _, _, err := sl.TailSession("/home/user/project", "missing"); err == nil {
+		t.Error("TailSession(missing) error = nil, want error")
+	}
+}
@@ -5,17 +5,24 @@ import "encoding/json"
 // BridgeEvent is the frontend-friendly event struct emitted to JS via Wails EventsEmit.
 // It flattens ParsedEvent into a simple structure suitable for JSON serialization.
 type BridgeEvent struct {
-	Type      string          `json:"type"`
-	SessionID string          `json:"session_id,omitempty"`
-	RequestID string          `json:"request_id,omitempty"`
-	Text      string          `json:"text,omitempty"`
-	Thinking  string          `json:"thinking,omitempty"`
-	ToolName  string          `json:"tool_name,omitempty"`
-	ToolInput json.RawMessage `json:"tool_input,omitempty"`
-	IsError   bool            `json:"is_error,omitempty"`
-	Result    string          `json:"result,omitempty"`
-	Model     string          `json:"model,omitempty"`
-	Subtype   string          `json:"subtype,omitempty"`
+	Type        string          `json:"type"`
+	SessionID   string          `json:"session_id,omitempty"`
+	RequestID   string          `json:"request_id,omitempty"`
+	Text        string          `json:"text,omitempty"`
+	Thinking    string          `json:"thinking,omitempty"`
+	ToolName    string          `json:"tool_name,omitempty"`
+	ToolUseID   string          `json:"tool_use_id,omitempty"`
+	ToolInput   json.RawMessage `json:"tool_input,omitempty"`
+	ToolSummary string          `json:"tool_summary,omitempty"`
+	ToolFiles   []string        `json:"tool_files,omitempty"`
+	ToolDiff    string          `json:"tool_diff,omitempty"`
+	IsError     bool            `json:"is_error,omitempty"`
+	Result      string          `json:"result,omitempty"`
+	Model       string          `json:"model,omitempty"`
+	Subtype     string          `json:"subtype,omitempty"`
+	UUID        string          `json:"uuid,omitempty"`
+	Timestamp   string          `json:"timestamp,omitempty"`
+	ReadOnly    bool            `json:"read_only,omitempty"` // From an attached session run outside the app
 }
 
 // ToBridgeEvent converts a ParsedEvent into a BridgeEvent for the frontend.
@@ -39,7 +46,9 @@ func ToBridgeEvent(ev ParsedEvent) BridgeEvent {
 				be.Thinking = block.Thinking
 			case "tool_use":
 				be.ToolName = block.Name
+				be.ToolUseID = block.ID
 				be.ToolInput = block.Input
+				describeToolInput(&be)
 			}
 		}
 
@@ -52,3 +61,18 @@ func ToBridgeEvent(ev ParsedEvent) BridgeEvent {
 
 	return be
 }
+
+// describeToolInput fills the derived tool fields of be from its tool name and input.
+// Inputs of unknown tools or inputs that fail to decode leave the fields empty.
+func describeToolInput(be *BridgeEvent) {
+	be.ToolSummary, be.ToolFiles, be.ToolDiff = "", nil, ""
+	input, err := DecodeToolInput(be.ToolName, be.ToolInput)
+	if err != nil || input == nil {
+		return
+	}
+	be.ToolSummary = input.Summary()
+	be.ToolFiles = input.Files()
+	if d, ok := input.(ToolDiffer); ok {
+		be.ToolDiff = d.Diff()
+	}
+}
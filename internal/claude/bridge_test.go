@@ -287,3 +287,50 @@ func TestToBridgeEvent_NoSpecificEvent(t *testing.T) {
 		t.Errorf("expected empty Result, got %q", be.Result)
 	}
 }
+
+func TestToBridgeEvent_DescribesKnownToolInput(t *testing.T) {
+	input := json.RawMessage(`{"file_path":"/src/a.go","old_string":"a","new_string":"b"}`)
+	ev := ParsedEvent{
+		Type: "assistant",
+		Assistant: &AssistantEvent{
+			Type: "assistant",
+			Message: AssistantMessage{
+				Content: []ContentBlock{
+					{Type: "tool_use", Name: "Edit", Input: input},
+				},
+			},
+		},
+	}
+
+	be := ToBridgeEvent(ev)
+
+	if be.ToolSummary != "Edit /src/a.go" {
+		t.Errorf("expected ToolSummary='Edit /src/a.go', got %q", be.ToolSummary)
+	}
+	if len(be.ToolFiles) != 1 || be.ToolFiles[0] != "/src/a.go" {
+		t.Errorf("expected ToolFiles=[/src/a.go], got %v", be.ToolFiles)
+	}
+	if be.ToolDiff == "" {
+		t.Error("expected non-empty ToolDiff")
+	}
+}
+
+func TestToBridgeEvent_UnknownToolHasNoDescription(t *testing.T) {
+	ev := ParsedEvent{
+		Type: "assistant",
+		Assistant: &AssistantEvent{
+			Type: "assistant",
+			Message: AssistantMessage{
+				Content: []ContentBlock{
+					{Type: "tool_use", Name: "mcp__x__y", Input: json.RawMessage(`{"a":1}`)},
+				},
+			},
+		},
+	}
+
+	be := ToBridgeEvent(ev)
+
+	if be.ToolSummary != "" || be.ToolFiles != nil || be.ToolDiff != "" {
+		t.Errorf("expected no derived tool fields, got %q %v %q", be.ToolSummary, be.ToolFiles, be.ToolDiff)
+	}
+}
@@ -0,0 +1,494 @@
+package claude
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/atomicfile"
+)
+
+// bundleVersion is the bundle format written by ExportBundle. ImportBundle
+// accepts this version and older ones.
+const bundleVersion = 1
+
+// Bundle archive layout.
+const (
+	bundleManifestName = "manifest.json"
+	bundleSessionName  = "session.jsonl"
+	bundleFilesDir     = "files/" // The CLI's per-session directory, e.g. subagent transcripts
+)
+
+// Limits on the unpacked content of a bundle, so a small archive cannot
+// expand into more memory than any real session needs.
+const (
+	maxBundleEntrySize = 512 << 20
+	maxBundleSize      = 1 << 30
+)
+
+// BundleExtension is the file extension of session bundles. Bundles are
+// plain zip archives, so they can be inspected with standard tools.
+const BundleExtension = ".session.zip"
+
+// ErrSessionExists is returned by ImportBundle when the session ID is
+// already used on this machine.
+var ErrSessionExists = errors.New("session already exists")
+
+// ErrNewerCLI is returned by ImportBundle when the session was recorded by
+// a newer Claude CLI than the local one, which may not read its entries.
+var ErrNewerCLI = errors.New("session was recorded by a newer Claude CLI")
+
+// BundleManifest describes a session bundle.
+type BundleManifest struct {
+	Version     int         `json:"version"`
+	SessionID   string      `json:"session_id"`
+	ProjectPath string      `json:"project_path"` // Checkout the session was recorded in
+	CLIVersion  string      `json:"cli_version,omitempty"`
+	ExportedAt  time.Time   `json:"exported_at"`
+	Meta        SessionMeta `json:"meta,omitzero"`
+}
+
+// ImportOptions controls ImportBundle.
+type ImportOptions struct {
+	// NewSessionID imports the session under a fresh ID instead of failing
+	// when its ID is already in use.
+	NewSessionID bool `json:"new_session_id"`
+	// CLIVersion is the version of the local Claude CLI. Bundles recorded
+	// by a newer version are rejected; empty skips the check.
+	CLIVersion string `json:"cli_version,omitempty"`
+}
+
+// bundleEntry holds the fields of a session entry that describe its origin.
+type bundleEntry struct {
+	Cwd     string `json:"cwd,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// ExportBundle packs a session's JSONL file, its per-session directory and
+// its user-managed metadata into a zip archive.
+func (sl *SessionLister) ExportBundle(projectPath string, sessionID string) ([]byte, error) {
+	src, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+	meta, err := sl.metaStore().Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	meta.TrashedAt = time.Time{}
+
+	manifest := BundleManifest{
+		Version:     bundleVersion,
+		SessionID:   sessionID,
+		ProjectPath: projectPath,
+		ExportedAt:  time.Now().UTC(),
+		Meta:        meta,
+	}
+	// The recorded cwd is the authoritative source path: projectPath may
+	// only have matched through the cwd fallback.
+	origin := sessionOrigin(data)
+	if origin.Cwd != "" {
+		manifest.ProjectPath = origin.Cwd
+	}
+	manifest.CLIVersion = origin.Version
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode manifest: %w", err)
+	}
+	if err := writeZipFile(zw, bundleManifestName, manifestData); err != nil {
+		return nil, err
+	}
+	if err := writeZipFile(zw, bundleSessionName, data); err != nil {
+		return nil, err
+	}
+
+	sessionDir := strings.TrimSuffix(src, ".jsonl")
+	if stat, err := os.Stat(sessionDir); err == nil && stat.IsDir() {
+		err := filepath.WalkDir(sessionDir, func(p string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(sessionDir, p)
+			if err != nil {
+				return err
+			}
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return writeZipFile(zw, bundleFilesDir+filepath.ToSlash(rel), content)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("bundle session files: %w", err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("write bundle: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportBundle unpacks a session bundle into the project directory of
+// targetPath. Paths under the bundle's original project path are rewritten
+// to targetPath, so the CLI can resume the session in the local checkout.
+// Bundles from a newer format version or a newer CLI than opts.CLIVersion
+// are rejected, as are session IDs that already exist in any project unless
+// opts.NewSessionID is set.
+func (sl *SessionLister) ImportBundle(data []byte, targetPath string, opts ImportOptions) (SessionInfo, error) {
+	manifest, files, err := readBundle(data)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	if compareVersions(manifest.CLIVersion, opts.CLIVersion) > 0 {
+		return SessionInfo{}, fmt.Errorf("import session %s: recorded by CLI %s, local CLI is %s: %w", manifest.SessionID, manifest.CLIVersion, opts.CLIVersion, ErrNewerCLI)
+	}
+
+	id := manifest.SessionID
+	exists, err := sl.sessionExists(id)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	if exists {
+		if !opts.NewSessionID {
+			return SessionInfo{}, fmt.Errorf("import session %s: %w", id, ErrSessionExists)
+		}
+		if id, err = newSessionID(); err != nil {
+			return SessionInfo{}, err
+		}
+	}
+
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	dir := filepath.Join(base, "projects", encodeProjectPath(targetPath))
+	rewrite := func(b []byte) []byte {
+		b = rewriteProjectPath(b, manifest.ProjectPath, targetPath)
+		if id != manifest.SessionID {
+			b = bytes.ReplaceAll(b, []byte(manifest.SessionID), []byte(id))
+		}
+		return b
+	}
+
+	// Session directory files first, so the JSONL never appears without them.
+	for name, content := range files {
+		if name == bundleSessionName {
+			continue
+		}
+		rel := strings.TrimPrefix(name, bundleFilesDir)
+		if strings.HasSuffix(rel, ".jsonl") {
+			content = rewrite(content)
+		}
+		if err := atomicfile.WriteFile(filepath.Join(dir, id, filepath.FromSlash(rel)), content, 0600); err != nil {
+			return SessionInfo{}, fmt.Errorf("write session files: %w", err)
+		}
+	}
+	dst := filepath.Join(dir, id+".jsonl")
+	if err := atomicfile.WriteFile(dst, rewrite(files[bundleSessionName]), 0600); err != nil {
+		return SessionInfo{}, fmt.Errorf("write session: %w", err)
+	}
+
+	store := sl.metaStore()
+	meta := manifest.Meta
+	if meta.Title != "" {
+		if err := store.Rename(id, meta.Title); err != nil {
+			return SessionInfo{}, err
+		}
+	}
+	if len(meta.Tags) > 0 {
+		if err := store.SetTags(id, meta.Tags); err != nil {
+			return SessionInfo{}, err
+		}
+	}
+
+	info, err := parseSessionFile(dst)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	current, err := store.Get(id)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	applyMeta(&info, current)
+	return info, nil
+}
+
+// readBundle validates a bundle and returns its manifest and files.
+func readBundle(data []byte) (BundleManifest, map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return BundleManifest{}, nil, fmt.Errorf("open bundle: %w", err)
+	}
+
+	files := make(map[string][]byte)
+	var total int64
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		clean := path.Clean(f.Name)
+		if clean != f.Name || path.IsAbs(clean) || strings.HasPrefix(clean, "../") || strings.Contains(clean, `\`) {
+			return BundleManifest{}, nil, fmt.Errorf("invalid file name %q in bundle", f.Name)
+		}
+		if clean != bundleManifestName && clean != bundleSessionName && !strings.HasPrefix(clean, bundleFilesDir) {
+			return BundleManifest{}, nil, fmt.Errorf("unexpected file %q in bundle", f.Name)
+		}
+		if f.UncompressedSize64 > maxBundleEntrySize {
+			return BundleManifest{}, nil, fmt.Errorf("file %q in bundle exceeds %d bytes", f.Name, maxBundleEntrySize)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return BundleManifest{}, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		// The recorded size may lie, so the reads are capped as well
+		limit := min(maxBundleEntrySize, maxBundleSize-total)
+		content, err := io.ReadAll(io.LimitReader(rc, limit+1))
+		rc.Close()
+		if err != nil {
+			return BundleManifest{}, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if int64(len(content)) > limit {
+			return BundleManifest{}, nil, fmt.Errorf("bundle content exceeds %d bytes per file or %d bytes in total", maxBundleEntrySize, maxBundleSize)
+		}
+		total += int64(len(content))
+		files[clean] = content
+	}
+
+	var manifest BundleManifest
+	manifestData, ok := files[bundleManifestName]
+	if !ok {
+		return BundleManifest{}, nil, errors.New("bundle has no manifest")
+	}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return BundleManifest{}, nil, fmt.Errorf("parse manifest: %w", err)
+	}
+	if manifest.Version < 1 || manifest.Version > bundleVersion {
+		return BundleManifest{}, nil, fmt.Errorf("unsupported bundle version %d (supported up to %d)", manifest.Version, bundleVersion)
+	}
+	// The ID names files and is matched with globs, so only the CLI's
+	// UUIDs are accepted from a bundle
+	if !isUUID(manifest.SessionID) {
+		return BundleManifest{}, nil, fmt.Errorf("invalid session id %q in bundle", manifest.SessionID)
+	}
+	if _, ok := files[bundleSessionName]; !ok {
+		return BundleManifest{}, nil, errors.New("bundle has no session transcript")
+	}
+	return manifest, files, nil
+}
+
+// sessionExists reports whether a session ID is used by any project or the
+// trash. The ID must be a UUID, so it cannot act as a glob pattern.
+func (sl *SessionLister) sessionExists(sessionID string) (bool, error) {
+	if !isUUID(sessionID) {
+		return false, fmt.Errorf("invalid session id %q", sessionID)
+	}
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return false, err
+	}
+	trash, err := sl.trashDir()
+	if err != nil {
+		return false, err
+	}
+	for _, pattern := range []string{
+		filepath.Join(base, "projects", "*", sessionID+".jsonl"),
+		filepath.Join(trash, "*", sessionID+".jsonl"),
+	} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return false, err
+		}
+		if len(matches) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// compareVersions compares two dotted version numbers such as "2.0.14",
+// returning -1, 0 or 1. Anything after the numbers, like a pre-release
+// suffix, is ignored. Versions that are empty or do not start with a
+// number compare as equal to everything.
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	if pa == nil || pb == nil {
+		return 0
+	}
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// versionParts returns the leading dot-separated numbers of a version, or
+// nil if it does not start with one.
+func versionParts(v string) []int {
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		end := 0
+		for end < len(field) && field[end] >= '0' && field[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(field[:end])
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+		if end < len(field) {
+			break
+		}
+	}
+	return parts
+}
+
+// sessionOrigin returns the first recorded cwd and CLI version of a session.
+func sessionOrigin(data []byte) bundleEntry {
+	var origin bundleEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
+	for scanner.Scan() && (origin.Cwd == "" || origin.Version == "") {
+		var entry bundleEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		if origin.Cwd == "" {
+			origin.Cwd = entry.Cwd
+		}
+		if origin.Version == "" {
+			origin.Version = entry.Version
+		}
+	}
+	return origin
+}
+
+// rewriteProjectPath replaces from with to inside the JSON text b wherever
+// from appears as a whole path or a path prefix, so "/a/proj" does not
+// match "/a/project". Both paths are compared in their JSON-escaped form,
+// which covers Windows backslashes.
+func rewriteProjectPath(b []byte, from, to string) []byte {
+	if from == "" || from == to {
+		return b
+	}
+	oldEsc, err := json.Marshal(from)
+	if err != nil {
+		return b
+	}
+	newEsc, err := json.Marshal(to)
+	if err != nil {
+		return b
+	}
+	return replacePath(b, oldEsc[1:len(oldEsc)-1], newEsc[1:len(newEsc)-1])
+}
+
+// replacePath replaces from with to in b wherever from is followed by the
+// end of b or a path boundary, so it only matches whole paths or path
+// prefixes.
+func replacePath(b []byte, from, to []byte) []byte {
+	var out bytes.Buffer
+	for {
+		i := bytes.Index(b, from)
+		if i < 0 {
+			out.Write(b)
+			return out.Bytes()
+		}
+		end := i + len(from)
+		out.Write(b[:i])
+		if atPathEnd(b, end) {
+			out.Write(to)
+		} else {
+			out.Write(from)
+		}
+		b = b[end:]
+	}
+}
+
+// atPathEnd reports whether a path ending before b[i] is complete: i is
+// the end of b or a path boundary, or a full stop ending a sentence.
+func atPathEnd(b []byte, i int) bool {
+	if i == len(b) || isPathBoundary(b[i]) {
+		return true
+	}
+	return b[i] == '.' && (i+1 == len(b) || b[i+1] == ' ' || b[i+1] == '\n')
+}
+
+// isPathBoundary reports whether c can follow a complete path component in
+// JSON or plain text: a separator (escaped backslashes start with '\'), the
+// closing quote, or whitespace and punctuation in prose.
+func isPathBoundary(c byte) bool {
+	switch c {
+	case '/', '\\', '"', ' ', '\t', '\n', '\r', ':', ',', ')', ']', '\'', '`':
+		return true
+	}
+	return false
+}
+
+// newSessionID returns a random version 4 UUID, the format the CLI uses.
+func newSessionID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate session id: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// isUUID reports whether s is a UUID in its canonical, hyphenated form.
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	return nil
+}
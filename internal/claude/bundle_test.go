@@ -0,0 +1,256 @@
+package claude
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// bundleSessionID is the session exported by writeBundleSource. Bundled
+// session IDs must be UUIDs, as the CLI creates them.
+const bundleSessionID = "6f1c9a52-3d4e-4b8a-9c2d-1e5f7a8b9c0d"
+
+// writeBundleSource creates a session recorded in /home/alice/proj with a
+// subagent transcript in its session directory.
+func writeBundleSource(t *testing.T) *SessionLister {
+	t.Helper()
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-alice-proj")
+	if err := os.MkdirAll(filepath.Join(projectDir, bundleSessionID, "subagents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(projectDir, bundleSessionID+".jsonl"), []map[string]interface{}{
+		{"type": "user", "sessionId": bundleSessionID, "cwd": "/home/alice/proj", "version": "2.0.1", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Edit /home/alice/proj/main.go, not /home/alice/project2"}},
+		{"type": "assistant", "sessionId": bundleSessionID, "cwd": "/home/alice/proj/sub", "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{"role": "assistant", "content": []interface{}{}, "model": "claude-opus"}},
+	})
+	writeJSONLFile(t, filepath.Join(projectDir, bundleSessionID, "subagents", "agent-1.jsonl"), []map[string]interface{}{
+		{"type": "user", "sessionId": bundleSessionID, "cwd": "/home/alice/proj", "timestamp": "2026-01-20T10:00:02Z"},
+	})
+	sl := NewSessionLister(base)
+	sl.RenameSession(bundleSessionID, "Shared session")
+	sl.SetSessionTags(bundleSessionID, []string{"handover"})
+	sl.SetSessionPinned(bundleSessionID, true)
+	return sl
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	src := writeBundleSource(t)
+	data, err := src.ExportBundle("/home/alice/proj", bundleSessionID)
+	if err != nil {
+		t.Fatalf("ExportBundle() error = %v", err)
+	}
+
+	manifest, files, err := readBundle(data)
+	if err != nil {
+		t.Fatalf("readBundle() error = %v", err)
+	}
+	if manifest.Version != bundleVersion || manifest.ProjectPath != "/home/alice/proj" || manifest.CLIVersion != "2.0.1" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+	if _, ok := files["files/subagents/agent-1.jsonl"]; !ok {
+		t.Errorf("bundle files = %v, want subagent transcript", keys(files))
+	}
+
+	dst := NewSessionLister(t.TempDir())
+	info, err := dst.ImportBundle(data, "/work/bob/checkout", ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportBundle() error = %v", err)
+	}
+	if info.ID != bundleSessionID || info.Title != "Shared session" || len(info.Tags) != 1 || info.Pinned {
+		t.Errorf("imported info = %+v, want title and tags but not pin", info)
+	}
+
+	imported, err := os.ReadFile(filepath.Join(dst.BasePath, "projects", "-work-bob-checkout", bundleSessionID+".jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(imported)
+	for _, want := range []string{
+		`"cwd":"/work/bob/checkout"`,
+		`"cwd":"/work/bob/checkout/sub"`,
+		"Edit /work/bob/checkout/main.go",
+		"not /home/alice/project2",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("imported session missing %q:\n%s", want, text)
+		}
+	}
+	sub, err := os.ReadFile(filepath.Join(dst.BasePath, "projects", "-work-bob-checkout", bundleSessionID, "subagents", "agent-1.jsonl"))
+	if err != nil || !strings.Contains(string(sub), "/work/bob/checkout") {
+		t.Errorf("subagent transcript not imported and rewritten: %s, %v", sub, err)
+	}
+
+	sessions, err := dst.ListSessions("/work/bob/checkout")
+	if err != nil || len(sessions) != 1 {
+		t.Errorf("ListSessions() = %+v, %v; want imported session", sessions, err)
+	}
+}
+
+func TestImportBundle_Collision(t *testing.T) {
+	src := writeBundleSource(t)
+	data, err := src.ExportBundle("/home/alice/proj", bundleSessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Importing on the source machine collides with the original.
+	if _, err := src.ImportBundle(data, "/other/checkout", ImportOptions{}); !errors.Is(err, ErrSessionExists) {
+		t.Fatalf("ImportBundle() error = %v, want ErrSessionExists", err)
+	}
+	if _, err := os.Stat(filepath.Join(src.BasePath, "projects", "-other-checkout")); !os.IsNotExist(err) {
+		t.Error("colliding import wrote files")
+	}
+
+	info, err := src.ImportBundle(data, "/other/checkout", ImportOptions{NewSessionID: true})
+	if err != nil {
+		t.Fatalf("ImportBundle(NewSessionID) error = %v", err)
+	}
+	if info.ID == bundleSessionID || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(info.ID) {
+		t.Errorf("new ID = %q, want fresh UUID", info.ID)
+	}
+	content, _ := os.ReadFile(filepath.Join(src.BasePath, "projects", "-other-checkout", info.ID+".jsonl"))
+	if strings.Contains(string(content), `"sessionId":"`+bundleSessionID+`"`) {
+		t.Errorf("old session ID left in transcript:\n%s", content)
+	}
+}
+
+func TestImportBundle_CLIVersion(t *testing.T) {
+	src := writeBundleSource(t)
+	data, err := src.ExportBundle("/home/alice/proj", bundleSessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewSessionLister(t.TempDir())
+	if _, err := dst.ImportBundle(data, "/home/bob/proj", ImportOptions{CLIVersion: "1.9.20"}); !errors.Is(err, ErrNewerCLI) {
+		t.Fatalf("ImportBundle(older local CLI) error = %v, want ErrNewerCLI", err)
+	}
+	if _, err := dst.ImportBundle(data, "/home/bob/proj", ImportOptions{CLIVersion: "2.0.14"}); err != nil {
+		t.Errorf("ImportBundle(newer local CLI) error = %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"2.0.1", "2.0.1", 0},
+		{"2.0.1", "2.0.14", -1},
+		{"2.1", "2.0.14", 1},
+		{"2.0", "2.0.0", 0},
+		{"2.0.2-beta", "2.0.1", 1},
+		{"", "2.0.1", 0},
+		{"2.0.1", "unknown", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestReadBundle_Rejects(t *testing.T) {
+	build := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		for name, content := range files {
+			w, _ := zw.Create(name)
+			w.Write([]byte(content))
+		}
+		zw.Close()
+		return buf.Bytes()
+	}
+	manifest := func(m BundleManifest) string {
+		data, _ := json.Marshal(m)
+		return string(data)
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"newer version", map[string]string{
+			bundleManifestName: manifest(BundleManifest{Version: bundleVersion + 1, SessionID: bundleSessionID}),
+			bundleSessionName:  "{}",
+		}, "unsupported bundle version"},
+		{"path traversal", map[string]string{
+			bundleManifestName:    manifest(BundleManifest{Version: 1, SessionID: bundleSessionID}),
+			bundleSessionName:     "{}",
+			"files/../../evil.sh": "x",
+		}, "invalid file name"},
+		{"glob in session id", map[string]string{
+			bundleManifestName: manifest(BundleManifest{Version: 1, SessionID: "*"}),
+			bundleSessionName:  "{}",
+		}, "invalid session id"},
+		{"bad session id", map[string]string{
+			bundleManifestName: manifest(BundleManifest{Version: 1, SessionID: "../" + bundleSessionID}),
+			bundleSessionName:  "{}",
+		}, "invalid session id"},
+		{"missing transcript", map[string]string{
+			bundleManifestName: manifest(BundleManifest{Version: 1, SessionID: bundleSessionID}),
+		}, "no session transcript"},
+		{"missing manifest", map[string]string{
+			bundleSessionName: "{}",
+		}, "no manifest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := readBundle(build(tt.files))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("readBundle() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := readBundle([]byte("not a zip")); err == nil {
+		t.Error("readBundle(garbage) error = nil, want error")
+	}
+
+	// An entry claiming to unpack beyond the cap is refused before reading.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{Name: bundleSessionName, Method: zip.Store, CompressedSize64: 2, UncompressedSize64: maxBundleEntrySize + 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("{}"))
+	zw.Close()
+	if _, _, err := readBundle(buf.Bytes()); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("readBundle(oversized entry) error = %v, want size error", err)
+	}
+}
+
+func TestRewriteProjectPath(t *testing.T) {
+	tests := []struct {
+		name, in, from, to, want string
+	}{
+		{"exact", `{"cwd":"/a/proj"}`, "/a/proj", "/b/x", `{"cwd":"/b/x"}`},
+		{"prefix", `{"p":"/a/proj/f.go"}`, "/a/proj", "/b/x", `{"p":"/b/x/f.go"}`},
+		{"sibling untouched", `{"p":"/a/project"}`, "/a/proj", "/b/x", `{"p":"/a/project"}`},
+		{"windows source", `{"cwd":"C:\\a\\proj\\f.go"}`, `C:\a\proj`, "/b/x", `{"cwd":"/b/x\\f.go"}`},
+		{"windows target", `{"cwd":"/a/proj"}`, "/a/proj", `D:\w`, `{"cwd":"D:\\w"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(rewriteProjectPath([]byte(tt.in), tt.from, tt.to)); got != tt.want {
+				t.Errorf("rewriteProjectPath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func keys(m map[string][]byte) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
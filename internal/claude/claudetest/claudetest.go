@@ -0,0 +1,30 @@
+// Package claudetest builds CLI stream events for tests of packages that
+// consume them.
+package claudetest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Marcel-Bich/dogma/internal/claude"
+)
+
+// Parse parses one line of stream JSON, failing the test on errors.
+func Parse(t testing.TB, line string) claude.ParsedEvent {
+	t.Helper()
+	ev, err := claude.ParseEvent([]byte(line))
+	if err != nil {
+		t.Fatalf("ParseEvent(%s) error = %v", line, err)
+	}
+	return ev
+}
+
+// ToolUse returns an assistant event with a single tool_use block.
+func ToolUse(t testing.TB, id, name string, input map[string]interface{}) claude.ParsedEvent {
+	t.Helper()
+	raw, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Parse(t, `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"`+id+`","name":"`+name+`","input":`+string(raw)+`}]}}`)
+}
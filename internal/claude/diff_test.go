@@ -0,0 +1,156 @@
+package claude
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldName string
+		newName string
+		oldText string
+		newText string
+		want    string
+	}{
+		{
+			name:    "identical inputs",
+			oldName: "a.txt",
+			newName: "a.txt",
+			oldText: "same\n",
+			newText: "same\n",
+			want:    "",
+		},
+		{
+			name:    "new file",
+			oldName: "",
+			newName: "/tmp/new.txt",
+			oldText: "",
+			newText: "one\ntwo\n",
+			want:    "--- /dev/null\n+++ b/tmp/new.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name:    "deleted file",
+			oldName: "old.txt",
+			newName: "",
+			oldText: "gone\n",
+			newText: "",
+			want:    "--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-gone\n",
+		},
+		{
+			name:    "single line change",
+			oldName: "f.go",
+			newName: "f.go",
+			oldText: "a\nb\nc\n",
+			newText: "a\nB\nc\n",
+			want:    "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "insertion in the middle",
+			oldName: "f.go",
+			newName: "f.go",
+			oldText: "a\nb\n",
+			newText: "a\nx\nb\n",
+			want:    "--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,3 @@\n a\n+x\n b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff(tt.oldName, tt.newName, tt.oldText, tt.newText)
+			if got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff_SplitsDistantChangesIntoHunks(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 1; i <= 20; i++ {
+		oldLines = append(oldLines, fmt.Sprintf("line %d", i))
+		switch i {
+		case 2:
+			newLines = append(newLines, "changed 2")
+		case 18:
+			newLines = append(newLines, "changed 18")
+		default:
+			newLines = append(newLines, fmt.Sprintf("line %d", i))
+		}
+	}
+
+	got := UnifiedDiff("f", "f", strings.Join(oldLines, "\n")+"\n", strings.Join(newLines, "\n")+"\n")
+
+	if n := strings.Count(got, "@@ -"); n != 2 {
+		t.Fatalf("expected 2 hunks, got %d:\n%s", n, got)
+	}
+	if !strings.Contains(got, "@@ -1,5 +1,5 @@") {
+		t.Errorf("missing first hunk header:\n%s", got)
+	}
+	if !strings.Contains(got, "@@ -15,6 +15,6 @@") {
+		t.Errorf("missing second hunk header:\n%s", got)
+	}
+}
+
+func TestUnifiedDiff_MergesCloseChanges(t *testing.T) {
+	oldText := "1\n2\n3\n4\n5\n6\n7\n8\n"
+	newText := "1\nX\n3\n4\n5\n6\nY\n8\n"
+
+	got := UnifiedDiff("f", "f", oldText, newText)
+
+	if n := strings.Count(got, "@@ -"); n != 1 {
+		t.Fatalf("expected 1 hunk, got %d:\n%s", n, got)
+	}
+}
+
+func TestDiffLines_IsMinimal(t *testing.T) {
+	a := splitLines("a\nb\nc\na\nb\nb\na\n")
+	b := splitLines("c\nb\na\nb\na\nc\n")
+
+	ops := diffLines(a, b)
+
+	edits := 0
+	var gotA, gotB []string
+	for _, op := range ops {
+		switch op.kind {
+		case ' ':
+			gotA = append(gotA, op.text)
+			gotB = append(gotB, op.text)
+		case '-':
+			edits++
+			gotA = append(gotA, op.text)
+		case '+':
+			edits++
+			gotB = append(gotB, op.text)
+		}
+	}
+	// The classic Myers example has an edit distance of 5.
+	if edits != 5 {
+		t.Errorf("expected 5 edits, got %d", edits)
+	}
+	if strings.Join(gotA, ",") != strings.Join(a, ",") {
+		t.Errorf("edit script does not reproduce a: %v", gotA)
+	}
+	if strings.Join(gotB, ",") != strings.Join(b, ",") {
+		t.Errorf("edit script does not reproduce b: %v", gotB)
+	}
+}
+
+func TestDiffLines_FallsBackBeyondEditLimit(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxDiffEdits; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+
+	ops := diffLines(a, b)
+
+	if len(ops) != 2*maxDiffEdits {
+		t.Fatalf("expected %d ops, got %d", 2*maxDiffEdits, len(ops))
+	}
+	if ops[0].kind != '-' || ops[len(ops)-1].kind != '+' {
+		t.Errorf("expected deletions followed by insertions")
+	}
+}
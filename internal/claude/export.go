@@ -0,0 +1,350 @@
+package claude
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+)
+
+// Export formats.
+const (
+	ExportMarkdown = "markdown"
+	ExportHTML     = "html"
+	ExportJSON     = "json"
+)
+
+// ExportOptions controls how a session is exported.
+type ExportOptions struct {
+	Format             string `json:"format"` // ExportMarkdown (default), ExportHTML or ExportJSON
+	IncludeThinking    bool   `json:"include_thinking"`
+	IncludeToolInputs  bool   `json:"include_tool_inputs"`
+	IncludeToolOutputs bool   `json:"include_tool_outputs"`
+	RedactPaths        bool   `json:"redact_paths"` // Replace the project path with <project> and the home dir with ~
+}
+
+// ExportDocument is the normalized form of an exported session. The JSON
+// export is this document; Markdown and HTML are rendered from it.
+type ExportDocument struct {
+	SessionID string          `json:"session_id"`
+	Title     string          `json:"title"`
+	Summary   string          `json:"summary,omitempty"`
+	Messages  []ExportMessage `json:"messages"`
+}
+
+// ExportMessage is one block of an exported conversation.
+type ExportMessage struct {
+	Role        string          `json:"role"` // user, assistant, system or tool
+	Kind        string          `json:"kind"` // text, thinking, tool_use or tool_result
+	Timestamp   string          `json:"timestamp,omitempty"`
+	Model       string          `json:"model,omitempty"`
+	Text        string          `json:"text,omitempty"`
+	ToolName    string          `json:"tool_name,omitempty"`
+	ToolUseID   string          `json:"tool_use_id,omitempty"`
+	ToolSummary string          `json:"tool_summary,omitempty"`
+	ToolInput   json.RawMessage `json:"tool_input,omitempty"`
+	ToolDiff    string          `json:"tool_diff,omitempty"`
+	IsError     bool            `json:"is_error,omitempty"`
+}
+
+// ExportExtension returns the file extension for an export format.
+func ExportExtension(format string) string {
+	switch format {
+	case ExportHTML:
+		return ".html"
+	case ExportJSON:
+		return ".json"
+	}
+	return ".md"
+}
+
+// ExportSession renders a session of the given project in the requested format.
+func (sl *SessionLister) ExportSession(projectPath string, sessionID string, opts ExportOptions) ([]byte, error) {
+	tr, err := sl.LoadSession(projectPath, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := sl.metaStore().Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var redact func(string) string
+	if opts.RedactPaths {
+		homeFn := sl.homeDirFunc
+		if homeFn == nil {
+			homeFn = os.UserHomeDir
+		}
+		home, _ := homeFn()
+		redact = pathRedactor(projectPath, home)
+	}
+	doc := NewExportDocument(tr, meta.Title, opts, redact)
+	return RenderExport(doc, opts.Format)
+}
+
+// NewExportDocument builds the normalized export of a transcript. title
+// overrides the summary-based title when set. redact, if not nil, is applied
+// to every text field.
+func NewExportDocument(tr Transcript, title string, opts ExportOptions, redact func(string) string) ExportDocument {
+	clean := func(s string) string {
+		if redact == nil {
+			return s
+		}
+		return redact(s)
+	}
+
+	doc := ExportDocument{SessionID: tr.SessionID, Summary: clean(tr.Summary), Messages: []ExportMessage{}}
+	for _, ev := range tr.Events {
+		msg := ExportMessage{Role: ev.Type, Kind: "text", Timestamp: ev.Timestamp, Model: ev.Model}
+		switch {
+		case ev.Type == "summary":
+			continue
+		case ev.Type == "tool_result":
+			if !opts.IncludeToolOutputs {
+				continue
+			}
+			msg.Role, msg.Kind = "tool", "tool_result"
+			msg.ToolUseID = ev.ToolUseID
+			msg.Text = clean(ev.Result)
+			msg.IsError = ev.IsError
+		case ev.ToolName != "":
+			msg.Kind = "tool_use"
+			msg.ToolName = ev.ToolName
+			msg.ToolUseID = ev.ToolUseID
+			if opts.IncludeToolInputs {
+				msg.ToolSummary = clean(ev.ToolSummary)
+				msg.ToolInput = json.RawMessage(clean(string(ev.ToolInput)))
+				msg.ToolDiff = clean(ev.ToolDiff)
+			}
+		case ev.Thinking != "":
+			if !opts.IncludeThinking {
+				continue
+			}
+			msg.Kind = "thinking"
+			msg.Text = clean(ev.Thinking)
+		case ev.Text != "":
+			msg.Text = clean(ev.Text)
+		default:
+			continue
+		}
+		if doc.Title == "" && msg.Role == "user" && msg.Kind == "text" {
+			doc.Title = firstLine(msg.Text)
+		}
+		doc.Messages = append(doc.Messages, msg)
+	}
+
+	switch {
+	case title != "":
+		doc.Title = clean(title)
+	case doc.Summary != "":
+		doc.Title = doc.Summary
+	case doc.Title == "":
+		doc.Title = "Session " + tr.SessionID
+	}
+	return doc
+}
+
+// RenderExport renders an export document in the given format.
+func RenderExport(doc ExportDocument, format string) ([]byte, error) {
+	switch format {
+	case ExportMarkdown, "":
+		return renderMarkdown(doc), nil
+	case ExportHTML:
+		return renderHTML(doc), nil
+	case ExportJSON:
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("encode export: %w", err)
+		}
+		return append(data, '\n'), nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+// pathRedactor returns a function that replaces the project path with
+// <project> and the home directory with ~, including their JSON-escaped
+// forms inside tool inputs. Paths only match whole, so a sibling such as
+// <project>2 is left alone. The project path comes first because it
+// usually lies inside home.
+func pathRedactor(projectPath string, home string) func(string) string {
+	var pairs [][2][]byte
+	add := func(path, repl string) {
+		if path == "" || path == "/" {
+			return
+		}
+		pairs = append(pairs, [2][]byte{[]byte(path), []byte(repl)})
+		if escaped, err := json.Marshal(path); err == nil {
+			if s := escaped[1 : len(escaped)-1]; string(s) != path {
+				pairs = append(pairs, [2][]byte{s, []byte(repl)})
+			}
+		}
+	}
+	add(projectPath, "<project>")
+	add(home, "~")
+	return func(s string) string {
+		b := []byte(s)
+		for _, pair := range pairs {
+			b = replacePath(b, pair[0], pair[1])
+		}
+		return string(b)
+	}
+}
+
+// roleLabels are the headings used for each message role.
+var roleLabels = map[string]string{
+	"user":      "User",
+	"assistant": "Assistant",
+	"system":    "System",
+	"tool":      "Tool output",
+}
+
+func renderMarkdown(doc ExportDocument) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n\n", doc.Title)
+	fmt.Fprintf(&b, "Session `%s`\n", doc.SessionID)
+	if doc.Summary != "" && doc.Summary != doc.Title {
+		fmt.Fprintf(&b, "\n> %s\n", doc.Summary)
+	}
+
+	lastRole := ""
+	for _, m := range doc.Messages {
+		if m.Role != lastRole {
+			fmt.Fprintf(&b, "\n## %s\n", roleLabels[m.Role])
+			if m.Timestamp != "" {
+				fmt.Fprintf(&b, "\n_%s_\n", m.Timestamp)
+			}
+			lastRole = m.Role
+		}
+		b.WriteString("\n")
+		switch m.Kind {
+		case "thinking":
+			b.WriteString("<details>\n<summary>Thinking</summary>\n\n")
+			b.WriteString(m.Text)
+			b.WriteString("\n\n</details>\n")
+		case "tool_use":
+			fmt.Fprintf(&b, "**Tool: %s**", m.ToolName)
+			if m.ToolSummary != "" {
+				fmt.Fprintf(&b, " %s", m.ToolSummary)
+			}
+			b.WriteString("\n")
+			if m.ToolDiff != "" {
+				b.WriteString("\n")
+				writeFenced(&b, "diff", m.ToolDiff)
+			} else if len(m.ToolInput) > 0 {
+				b.WriteString("\n")
+				writeFenced(&b, "json", indentJSON(m.ToolInput))
+			}
+		case "tool_result":
+			if m.IsError {
+				b.WriteString("**Error**\n\n")
+			}
+			writeFenced(&b, "", m.Text)
+		default:
+			b.WriteString(m.Text)
+			b.WriteString("\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// writeFenced writes text as a fenced code block whose fence is longer than
+// any backtick run inside text.
+func writeFenced(b *bytes.Buffer, lang string, text string) {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	fmt.Fprintf(b, "%s%s\n%s\n%s\n", fence, lang, strings.TrimRight(text, "\n"), fence)
+}
+
+// exportCSS styles the HTML export so it needs no external resources.
+const exportCSS = `body{font-family:system-ui,-apple-system,sans-serif;max-width:860px;margin:2rem auto;padding:0 1rem;color:#1f2328;line-height:1.5}
+h1{font-size:1.6rem}.meta{color:#656d76}
+section{border-left:3px solid #d0d7de;margin:1.2rem 0;padding:.2rem 1rem}
+section.user{border-color:#0969da}section.assistant{border-color:#8250df}section.tool{border-color:#6e7781}
+h2{font-size:1rem;margin:.4rem 0}time{color:#656d76;font-size:.85rem;margin-left:.5rem;font-weight:normal}
+.text{white-space:pre-wrap}pre{background:#f6f8fa;padding:.75rem;overflow-x:auto;border-radius:6px}
+pre.error{background:#ffebe9}details{color:#656d76;margin:.5rem 0}.tool-name{font-weight:600}`
+
+func renderHTML(doc ExportDocument) []byte {
+	var b bytes.Buffer
+	esc := html.EscapeString
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n", esc(doc.Title), exportCSS)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<p class=\"meta\">Session <code>%s</code></p>\n", esc(doc.Title), esc(doc.SessionID))
+	if doc.Summary != "" && doc.Summary != doc.Title {
+		fmt.Fprintf(&b, "<blockquote>%s</blockquote>\n", esc(doc.Summary))
+	}
+
+	lastRole := ""
+	for _, m := range doc.Messages {
+		if m.Role != lastRole {
+			if lastRole != "" {
+				b.WriteString("</section>\n")
+			}
+			fmt.Fprintf(&b, "<section class=\"%s\">\n<h2>%s", esc(m.Role), esc(roleLabels[m.Role]))
+			if m.Timestamp != "" {
+				fmt.Fprintf(&b, "<time>%s</time>", esc(m.Timestamp))
+			}
+			b.WriteString("</h2>\n")
+			lastRole = m.Role
+		}
+		switch m.Kind {
+		case "thinking":
+			fmt.Fprintf(&b, "<details><summary>Thinking</summary><div class=\"text\">%s</div></details>\n", esc(m.Text))
+		case "tool_use":
+			fmt.Fprintf(&b, "<p><span class=\"tool-name\">Tool: %s</span>", esc(m.ToolName))
+			if m.ToolSummary != "" {
+				fmt.Fprintf(&b, " %s", esc(m.ToolSummary))
+			}
+			b.WriteString("</p>\n")
+			if m.ToolDiff != "" {
+				fmt.Fprintf(&b, "<pre>%s</pre>\n", esc(m.ToolDiff))
+			} else if len(m.ToolInput) > 0 {
+				fmt.Fprintf(&b, "<pre>%s</pre>\n", esc(indentJSON(m.ToolInput)))
+			}
+		case "tool_result":
+			class := ""
+			if m.IsError {
+				class = " class=\"error\""
+			}
+			fmt.Fprintf(&b, "<pre%s>%s</pre>\n", class, esc(m.Text))
+		default:
+			fmt.Fprintf(&b, "<div class=\"text\">%s</div>\n", esc(m.Text))
+		}
+	}
+	if lastRole != "" {
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.Bytes()
+}
+
+// indentJSON pretty-prints raw JSON, returning it unchanged if invalid.
+func indentJSON(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Indent(&b, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
+
+// firstLine returns the first non-empty line of s, truncated for a title.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return truncateSummary(line)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,215 @@
+package claude
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// exportTranscript covers every kind of event the exporter handles.
+func exportTranscript() Transcript {
+	return Transcript{
+		SessionID: "sess-1",
+		Summary:   "Fix the login bug",
+		Events: []BridgeEvent{
+			{Type: "summary", Text: "Fix the login bug"},
+			{Type: "user", Text: "Please fix /home/me/proj/login.go", Timestamp: "2026-01-20T10:00:00Z"},
+			{Type: "assistant", Thinking: "Let me look", Model: "claude-opus"},
+			{Type: "assistant", Text: "Reading the file", Model: "claude-opus"},
+			{Type: "assistant", ToolName: "Read", ToolUseID: "t1", ToolSummary: "Read /home/me/proj/login.go", ToolInput: json.RawMessage(`{"file_path":"/home/me/proj/login.go"}`)},
+			{Type: "tool_result", ToolUseID: "t1", Result: "package main\n```\n", IsError: false},
+			{Type: "assistant", Text: "Done <b>now</b>"},
+		},
+	}
+}
+
+func TestNewExportDocument_Options(t *testing.T) {
+	tr := exportTranscript()
+
+	t.Run("minimal", func(t *testing.T) {
+		doc := NewExportDocument(tr, "", ExportOptions{}, nil)
+		if doc.Title != "Fix the login bug" {
+			t.Errorf("Title = %q, want summary", doc.Title)
+		}
+		for _, m := range doc.Messages {
+			if m.Kind == "thinking" || m.Kind == "tool_result" {
+				t.Errorf("unexpected %s message without option", m.Kind)
+			}
+			if m.Kind == "tool_use" && (m.ToolInput != nil || m.ToolSummary != "") {
+				t.Errorf("tool input exported without option: %+v", m)
+			}
+		}
+		if len(doc.Messages) != 4 {
+			t.Errorf("got %d messages, want 4: %+v", len(doc.Messages), doc.Messages)
+		}
+	})
+
+	t.Run("everything", func(t *testing.T) {
+		doc := NewExportDocument(tr, "Custom", ExportOptions{IncludeThinking: true, IncludeToolInputs: true, IncludeToolOutputs: true}, nil)
+		if doc.Title != "Custom" {
+			t.Errorf("Title = %q, want Custom", doc.Title)
+		}
+		if len(doc.Messages) != 6 {
+			t.Fatalf("got %d messages, want 6", len(doc.Messages))
+		}
+		if m := doc.Messages[1]; m.Kind != "thinking" || m.Text != "Let me look" {
+			t.Errorf("Messages[1] = %+v, want thinking", m)
+		}
+		if m := doc.Messages[4]; m.Role != "tool" || m.Kind != "tool_result" || m.ToolUseID != "t1" {
+			t.Errorf("Messages[4] = %+v, want tool result", m)
+		}
+	})
+
+	t.Run("title falls back to first prompt", func(t *testing.T) {
+		noSummary := tr
+		noSummary.Summary = ""
+		doc := NewExportDocument(noSummary, "", ExportOptions{}, nil)
+		if doc.Title != "Please fix /home/me/proj/login.go" {
+			t.Errorf("Title = %q, want first prompt", doc.Title)
+		}
+	})
+
+	t.Run("redacts paths", func(t *testing.T) {
+		doc := NewExportDocument(tr, "", ExportOptions{IncludeToolInputs: true}, pathRedactor("/home/me/proj", "/home/me"))
+		data, _ := json.Marshal(doc)
+		if strings.Contains(string(data), "/home/me") {
+			t.Errorf("export still contains home path: %s", data)
+		}
+		if doc.Messages[0].Text != "Please fix <project>/login.go" {
+			t.Errorf("Text = %q, want redacted project path", doc.Messages[0].Text)
+		}
+	})
+}
+
+func TestPathRedactor_JSONEscapedPaths(t *testing.T) {
+	r := pathRedactor(`C:\Users\me\proj`, `C:\Users\me`)
+	got := r(`{"file_path":"C:\\Users\\me\\proj\\main.go","other":"C:\\Users\\me\\x"}`)
+	want := `{"file_path":"<project>\\main.go","other":"~\\x"}`
+	if got != want {
+		t.Errorf("Replace() = %s, want %s", got, want)
+	}
+}
+
+func TestPathRedactor_WholePathsOnly(t *testing.T) {
+	r := pathRedactor("/home/me/app", "/home/me")
+	tests := map[string]string{
+		"/home/me/app/x":          "<project>/x",
+		"/home/me/app2/x":         "~/app2/x",
+		"/home/me/app.go":         "~/app.go",
+		"cd /home/me/app.":        "cd <project>.",
+		"/home/meg/notes":         "/home/meg/notes",
+		`{"cwd":"/home/me/app"}`:  `{"cwd":"<project>"}`,
+		"in /home/me/app\nand so": "in <project>\nand so",
+	}
+	for in, want := range tests {
+		if got := r(in); got != want {
+			t.Errorf("redact(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRenderExport(t *testing.T) {
+	doc := NewExportDocument(exportTranscript(), "", ExportOptions{IncludeThinking: true, IncludeToolInputs: true, IncludeToolOutputs: true}, nil)
+
+	t.Run("markdown", func(t *testing.T) {
+		out, err := RenderExport(doc, ExportMarkdown)
+		if err != nil {
+			t.Fatal(err)
+		}
+		md := string(out)
+		for _, want := range []string{
+			"# Fix the login bug\n",
+			"## User\n",
+			"## Assistant\n",
+			"<summary>Thinking</summary>",
+			"**Tool: Read** Read /home/me/proj/login.go",
+			"```json\n{\n  \"file_path\"",
+			// Tool output containing a fence gets a longer fence.
+			"## Tool output\n\n````\npackage main\n```\n````",
+		} {
+			if !strings.Contains(md, want) {
+				t.Errorf("markdown missing %q:\n%s", want, md)
+			}
+		}
+		if strings.Count(md, "## Assistant") != 2 {
+			t.Errorf("consecutive assistant blocks should share a heading:\n%s", md)
+		}
+	})
+
+	t.Run("html", func(t *testing.T) {
+		out, err := RenderExport(doc, ExportHTML)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page := string(out)
+		if !strings.HasPrefix(page, "<!DOCTYPE html>") || !strings.Contains(page, "<style>") {
+			t.Errorf("html is not a self-contained page:\n%s", page)
+		}
+		if !strings.Contains(page, "Done &lt;b&gt;now&lt;/b&gt;") {
+			t.Errorf("html does not escape text:\n%s", page)
+		}
+		if strings.Count(page, "<section") != strings.Count(page, "</section>") {
+			t.Errorf("unbalanced sections:\n%s", page)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out, err := RenderExport(doc, ExportJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got ExportDocument
+		if err := json.Unmarshal(out, &got); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		if got.SessionID != "sess-1" || len(got.Messages) != len(doc.Messages) {
+			t.Errorf("round trip = %+v", got)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if _, err := RenderExport(doc, "pdf"); err == nil {
+			t.Error("RenderExport(pdf) error = nil, want error")
+		}
+	})
+}
+
+func TestExportSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-me-proj")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(projectDir, "s1.jsonl"), []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Look at /home/me/proj/a.go and /home/me/notes"}},
+	})
+	sl := NewSessionLister(tmpDir)
+	sl.homeDirFunc = func() (string, error) { return "/home/me", nil }
+	sl.RenameSession("s1", "Named")
+
+	out, err := sl.ExportSession("/home/me/proj", "s1", ExportOptions{Format: ExportMarkdown, RedactPaths: true})
+	if err != nil {
+		t.Fatalf("ExportSession() error = %v", err)
+	}
+	md := string(out)
+	if !strings.HasPrefix(md, "# Named\n") {
+		t.Errorf("export does not use custom title:\n%s", md)
+	}
+	if !strings.Contains(md, "Look at <project>/a.go and ~/notes") {
+		t.Errorf("paths not redacted:\n%s", md)
+	}
+
+	if _, err := sl.ExportSession("/home/me/proj", "missing", ExportOptions{}); err == nil {
+		t.Error("ExportSession(missing) error = nil, want error")
+	}
+}
+
+func TestExportExtension(t *testing.T) {
+	for format, want := range map[string]string{ExportMarkdown: ".md", "": ".md", ExportHTML: ".html", ExportJSON: ".json"} {
+		if got := ExportExtension(format); got != want {
+			t.Errorf("ExportExtension(%q) = %q, want %q", format, got, want)
+		}
+	}
+}
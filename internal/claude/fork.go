@@ -0,0 +1,113 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Marcel-Bich/dogma/internal/atomicfile"
+)
+
+// ForkSession copies the conversation leading up to the user prompt
+// messageUUID into a new session of the same project and returns the new
+// session ID. The prompt itself is left out, so resuming the copy with an
+// edited prompt re-runs the conversation from that point. Only the branch
+// the prompt belongs to is copied; the original session is not modified.
+//
+// If the prompt opens the conversation there is nothing to copy, and ""
+// is returned: the edited prompt then starts a new session.
+func (sl *SessionLister) ForkSession(projectPath string, sessionID string, messageUUID string) (string, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return "", err
+	}
+	g, err := readConversationGraph(path)
+	if err != nil {
+		return "", err
+	}
+	n, ok := g.nodes[messageUUID]
+	if !ok {
+		return "", fmt.Errorf("message %q not found in session %s", messageUUID, sessionID)
+	}
+	if promptText(n.entry) == "" {
+		return "", fmt.Errorf("message %q is not a user prompt", messageUUID)
+	}
+
+	keep := make(map[string]bool)
+	for _, id := range g.path(n.parent()) {
+		keep[id] = true
+	}
+	if len(keep) == 0 {
+		return "", nil
+	}
+
+	newID, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+	data, err := copyBranchLines(path, keep, newID)
+	if err != nil {
+		return "", err
+	}
+	if err := atomicfile.WriteFile(filepath.Join(filepath.Dir(path), newID+".jsonl"), data, 0600); err != nil {
+		return "", fmt.Errorf("write forked session: %w", err)
+	}
+	return newID, nil
+}
+
+// forkEntry holds the fields of a session entry that decide whether it is
+// copied into a fork.
+type forkEntry struct {
+	Type     string `json:"type"`
+	UUID     string `json:"uuid,omitempty"`
+	LeafUUID string `json:"leafUuid,omitempty"` // Set on summary entries
+}
+
+// copyBranchLines returns the lines of a session file whose UUID is in
+// keep, and the summaries of leaves in keep, in file order. The sessionId
+// field is set to newID; the rest of each entry, message text included, is
+// copied as is.
+func copyBranchLines(path string, keep map[string]bool, newID string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	defer f.Close()
+
+	encodedID, err := json.Marshal(newID)
+	if err != nil {
+		return nil, fmt.Errorf("encode session id: %w", err)
+	}
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var entry forkEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		if !keep[entry.UUID] && !(entry.Type == "summary" && keep[entry.LeafUUID]) {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["sessionId"]; ok {
+			fields["sessionId"] = encodedID
+			if line, err = json.Marshal(fields); err != nil {
+				return nil, fmt.Errorf("encode entry: %w", err)
+			}
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,89 @@
+package claude
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForkSession(t *testing.T) {
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	entry := func(typ, uuid, parent, text string) map[string]interface{} {
+		return map[string]interface{}{"type": typ, "uuid": uuid, "parentUuid": parent, "sessionId": "orig", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": typ, "content": text}}
+	}
+	origPath := filepath.Join(projectDir, "orig.jsonl")
+	writeJSONLFile(t, origPath, []map[string]interface{}{
+		entry("user", "u1", "", "Turn 1"),
+		entry("assistant", "a1", "u1", "Session orig is the original"),
+		entry("user", "u2", "a1", "Turn 2"),
+		entry("assistant", "a2", "u2", "Answer 2"),
+		entry("user", "u2b", "a1", "Turn 2 retried"),
+		entry("assistant", "a2b", "u2b", "Answer 2b"),
+		entry("user", "u3", "a2b", "Turn 3"),
+		entry("assistant", "a3", "u3", "Answer 3"),
+		{"type": "summary", "summary": "Retried turn", "leafUuid": "a2b"},
+		{"type": "summary", "summary": "Whole session", "leafUuid": "a3"},
+	})
+	original, _ := os.ReadFile(origPath)
+	sl := NewSessionLister(base)
+
+	newID, err := sl.ForkSession("/home/user/project", "orig", "u3")
+	if err != nil {
+		t.Fatalf("ForkSession() error = %v", err)
+	}
+	if newID == "" || newID == "orig" {
+		t.Fatalf("ForkSession() = %q, want a new session ID", newID)
+	}
+
+	if after, _ := os.ReadFile(origPath); !bytes.Equal(after, original) {
+		t.Error("original session was modified")
+	}
+	forked, err := os.ReadFile(filepath.Join(projectDir, newID+".jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := string(forked)
+	// Only the sessionId field changes, not message text naming the session
+	for _, want := range []string{`"uuid":"u1"`, `"uuid":"a1"`, `"uuid":"u2b"`, `"uuid":"a2b"`, `"sessionId":"` + newID + `"`, "Session orig is the original", `"summary":"Retried turn"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("forked session missing %s:\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{`"uuid":"u2"`, `"uuid":"u3"`, `"uuid":"a3"`, `"sessionId":"orig"`, "Whole session"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("forked session contains %s:\n%s", unwanted, text)
+		}
+	}
+
+	tr, err := sl.LoadBranch("/home/user/project", newID, "")
+	if err != nil || len(tr.Events) != 4 || tr.Events[3].UUID != "a2b" {
+		t.Errorf("forked transcript = %+v, %v; want to end at a2b", tr.Events, err)
+	}
+}
+
+func TestForkSession_Errors(t *testing.T) {
+	sl := writeBranchedSession(t)
+
+	if _, err := sl.ForkSession("/home/user/project", "s", "a1"); err == nil || !strings.Contains(err.Error(), "not a user prompt") {
+		t.Errorf("ForkSession(assistant) error = %v, want not a user prompt", err)
+	}
+	if _, err := sl.ForkSession("/home/user/project", "s", "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("ForkSession(missing) error = %v, want not found", err)
+	}
+
+	// Editing the opening prompt needs no copy.
+	id, err := sl.ForkSession("/home/user/project", "s", "u1")
+	if err != nil || id != "" {
+		t.Errorf("ForkSession(first prompt) = %q, %v; want empty ID", id, err)
+	}
+	sessions, _ := sl.ListSessions("/home/user/project")
+	if len(sessions) != 1 {
+		t.Errorf("ListSessions() = %d sessions, want only the original", len(sessions))
+	}
+}
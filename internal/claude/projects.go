@@ -0,0 +1,130 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxCwdScanLines bounds how many lines of a session file are read when
+// looking for the cwd field. The CLI writes it on every entry, so the first
+// few lines normally suffice.
+const maxCwdScanLines = 50
+
+// ProjectInfo describes one project directory under <config>/projects.
+type ProjectInfo struct {
+	Name         string    `json:"name"`           // Encoded directory name
+	Path         string    `json:"path,omitempty"` // Real path from the sessions' cwd field, if found
+	SessionCount int       `json:"session_count"`
+	LastActivity time.Time `json:"last_activity"`
+	TotalSize    int64     `json:"total_size"`
+	Missing      bool      `json:"missing"` // Path is known but no longer exists on disk
+}
+
+// ListProjects returns every project directory with its session count,
+// last activity and total size, most recently active first.
+func (sl *SessionLister) ListProjects() ([]ProjectInfo, error) {
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Join(base, "projects")
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ProjectInfo{}, nil
+		}
+		return nil, fmt.Errorf("read projects dir: %w", err)
+	}
+
+	projects := []ProjectInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := scanProject(filepath.Join(root, entry.Name()))
+		if err != nil {
+			continue
+		}
+		projects = append(projects, info)
+	}
+
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].LastActivity.After(projects[j].LastActivity)
+	})
+	return projects, nil
+}
+
+// scanProject collects the statistics of one project directory. The real
+// path is recovered from the newest session that records a cwd, because the
+// directory name encoding cannot be reversed.
+func scanProject(dir string) (ProjectInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ProjectInfo{}, err
+	}
+
+	info := ProjectInfo{Name: filepath.Base(dir)}
+	type sessionFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []sessionFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		info.SessionCount++
+		info.TotalSize += fi.Size()
+		if fi.ModTime().After(info.LastActivity) {
+			info.LastActivity = fi.ModTime()
+		}
+		files = append(files, sessionFile{path: filepath.Join(dir, entry.Name()), modTime: fi.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, f := range files {
+		if cwd := readSessionCwd(f.path); cwd != "" {
+			info.Path = cwd
+			break
+		}
+	}
+
+	if info.Path != "" {
+		if _, err := os.Stat(info.Path); os.IsNotExist(err) {
+			info.Missing = true
+		}
+	}
+	return info, nil
+}
+
+// readSessionCwd returns the first cwd recorded in a session file, or "".
+func readSessionCwd(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
+	for n := 0; n < maxCwdScanLines && scanner.Scan(); n++ {
+		var entry struct {
+			Cwd string `json:"cwd"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil && entry.Cwd != "" {
+			return entry.Cwd
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListProjects(t *testing.T) {
+	t.Run("returns empty slice without projects dir", func(t *testing.T) {
+		sl := NewSessionLister(t.TempDir())
+		projects, err := sl.ListProjects()
+		if err != nil {
+			t.Fatalf("ListProjects() error = %v", err)
+		}
+		if projects == nil || len(projects) != 0 {
+			t.Errorf("expected empty slice, got %v", projects)
+		}
+	})
+
+	t.Run("collects stats and recovers real paths", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		existing := t.TempDir()
+
+		// Project whose directory still exists; the path contains a dot, which
+		// the naive "-" decoding could never recover.
+		a1 := writeProjectSession(t, tmpDir, "-existing", "s1", []map[string]interface{}{
+			{"type": "summary", "summary": "no cwd here"},
+			{"type": "user", "cwd": existing, "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "hi"}},
+		})
+		a2 := writeProjectSession(t, tmpDir, "-existing", "s2", []map[string]interface{}{
+			{"type": "user", "cwd": existing, "timestamp": "2026-01-21T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "again"}},
+		})
+		// Project whose checkout was deleted.
+		b1 := writeProjectSession(t, tmpDir, "-home-gone-my-project", "s3", []map[string]interface{}{
+			{"type": "user", "cwd": "/home/gone/my.project", "timestamp": "2026-01-19T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "old"}},
+		})
+		// Project without any cwd information.
+		writeProjectSession(t, tmpDir, "-unknown", "s4", []map[string]interface{}{
+			{"type": "summary", "summary": "only a summary"},
+		})
+		// Files that are not sessions are ignored.
+		os.WriteFile(filepath.Join(tmpDir, "projects", "-existing", "notes.txt"), []byte("x"), 0644)
+		os.WriteFile(filepath.Join(tmpDir, "projects", "stray-file"), []byte("x"), 0644)
+
+		now := time.Now()
+		os.Chtimes(a1, now.Add(-3*time.Hour), now.Add(-3*time.Hour))
+		os.Chtimes(a2, now.Add(-1*time.Hour), now.Add(-1*time.Hour))
+		os.Chtimes(b1, now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+		os.Chtimes(filepath.Join(tmpDir, "projects", "-unknown", "s4.jsonl"), now.Add(-4*time.Hour), now.Add(-4*time.Hour))
+
+		sl := NewSessionLister(tmpDir)
+		projects, err := sl.ListProjects()
+		if err != nil {
+			t.Fatalf("ListProjects() error = %v", err)
+		}
+		if len(projects) != 3 {
+			t.Fatalf("expected 3 projects, got %d: %+v", len(projects), projects)
+		}
+
+		p := projects[0]
+		if p.Name != "-existing" || p.Path != existing || p.Missing {
+			t.Errorf("projects[0] = %+v", p)
+		}
+		if p.SessionCount != 2 {
+			t.Errorf("SessionCount = %d, want 2", p.SessionCount)
+		}
+		sa1, _ := os.Stat(a1)
+		sa2, _ := os.Stat(a2)
+		if p.TotalSize != sa1.Size()+sa2.Size() {
+			t.Errorf("TotalSize = %d, want %d", p.TotalSize, sa1.Size()+sa2.Size())
+		}
+		if !p.LastActivity.Equal(sa2.ModTime()) {
+			t.Errorf("LastActivity = %v, want %v", p.LastActivity, sa2.ModTime())
+		}
+
+		if projects[1].Name != "-home-gone-my-project" || projects[1].Path != "/home/gone/my.project" || !projects[1].Missing {
+			t.Errorf("projects[1] = %+v", projects[1])
+		}
+		if projects[2].Name != "-unknown" || projects[2].Path != "" || projects[2].Missing {
+			t.Errorf("projects[2] = %+v", projects[2])
+		}
+	})
+}
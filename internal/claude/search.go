@@ -0,0 +1,588 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Search result bounds.
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+	snippetRadius      = 60 // runes of context on each side of the first match
+	// Deleted documents stay in the index until they make up this share
+	// of it; then the index is compacted.
+	maxDeadShare = 0.5
+	// Search refreshes the index only if the last refresh is older than
+	// this, so typing a query does not rescan every session file.
+	searchRefreshInterval = 10 * time.Second
+)
+
+// SearchFilters narrows a full-text search. Zero values do not filter.
+type SearchFilters struct {
+	Project   string    `json:"project,omitempty"`    // Encoded project directory name
+	SessionID string    `json:"session_id,omitempty"` // Restrict to one session
+	Kinds     []string  `json:"kinds,omitempty"`      // Any of "user", "assistant", "tool"
+	Since     time.Time `json:"since,omitempty"`
+	Until     time.Time `json:"until,omitempty"`
+	Limit     int       `json:"limit,omitempty"`
+}
+
+// SearchHit is a single matching message.
+type SearchHit struct {
+	SessionID string    `json:"session_id"`
+	Project   string    `json:"project"`
+	UUID      string    `json:"uuid,omitempty"`
+	Kind      string    `json:"kind"`
+	Timestamp time.Time `json:"timestamp"`
+	Snippet   string    `json:"snippet"`
+	// Highlights are [start, end) byte ranges of matched terms within Snippet.
+	Highlights [][2]int `json:"highlights"`
+}
+
+// searchDoc is one indexed piece of text. The text itself is not kept in
+// memory; it is re-read from the session file when building a snippet.
+type searchDoc struct {
+	file      string
+	offset    int64
+	length    int
+	part      int // index into searchableTexts of the entry
+	kind      string
+	uuid      string
+	timestamp time.Time
+	deleted   bool
+}
+
+// searchFile tracks how much of a session file has been indexed.
+type searchFile struct {
+	modTime  time.Time
+	fileSize int64
+	size     int64 // bytes indexed (up to the last complete line)
+	docs     []int
+}
+
+// SearchIndex is an incrementally updated inverted index over the session
+// files of all projects under the Claude config directory.
+type SearchIndex struct {
+	lister *SessionLister
+
+	refreshMu sync.Mutex           // Serializes Refresh; only Refresh changes files
+	failed    map[string]fileStamp // Files that could not be read, guarded by refreshMu
+
+	mu       sync.Mutex
+	docs     []searchDoc
+	postings map[string][]int
+	files    map[string]*searchFile
+	dead     int       // Deleted documents still in docs
+	refresh  time.Time // When the last Refresh finished
+}
+
+// indexedText is a document read from a session file with its terms,
+// ready to be added to the index.
+type indexedText struct {
+	doc   searchDoc
+	terms []string
+}
+
+// NewSearchIndex creates an empty index over the sessions found by lister.
+func NewSearchIndex(lister *SessionLister) *SearchIndex {
+	return &SearchIndex{
+		lister:   lister,
+		postings: make(map[string][]int),
+		files:    make(map[string]*searchFile),
+		failed:   make(map[string]fileStamp),
+	}
+}
+
+// Search returns messages containing every term of query, newest first.
+// It refreshes the index first if the last refresh is older than
+// searchRefreshInterval; callers that see sessions change call Refresh
+// themselves.
+func (si *SearchIndex) Search(query string, filters SearchFilters) ([]SearchHit, error) {
+	terms := uniqueTerms(query)
+	if len(terms) == 0 {
+		return []SearchHit{}, nil
+	}
+	si.mu.Lock()
+	stale := time.Since(si.refresh) >= searchRefreshInterval
+	si.mu.Unlock()
+	if stale {
+		if err := si.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	si.mu.Lock()
+	matches := si.match(terms, filters)
+	docs := make([]searchDoc, len(matches))
+	for i, id := range matches {
+		docs[i] = si.docs[id]
+	}
+	si.mu.Unlock()
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].timestamp.After(docs[j].timestamp)
+	})
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	hits := []SearchHit{}
+	for _, doc := range docs {
+		if len(hits) >= limit {
+			break
+		}
+		text, err := readDocText(doc)
+		if err != nil {
+			continue
+		}
+		snippet, highlights := buildSnippet(text, terms)
+		hits = append(hits, SearchHit{
+			SessionID:  strings.TrimSuffix(filepath.Base(doc.file), ".jsonl"),
+			Project:    filepath.Base(filepath.Dir(doc.file)),
+			UUID:       doc.uuid,
+			Kind:       doc.kind,
+			Timestamp:  doc.timestamp,
+			Snippet:    snippet,
+			Highlights: highlights,
+		})
+	}
+	return hits, nil
+}
+
+// match returns the ids of live documents containing all terms and passing filters.
+// Callers must hold si.mu.
+func (si *SearchIndex) match(terms []string, filters SearchFilters) []int {
+	// Start from the shortest posting list.
+	lists := make([][]int, len(terms))
+	for i, term := range terms {
+		lists[i] = si.postings[term]
+		if len(lists[i]) == 0 {
+			return nil
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	result := lists[0]
+	for _, list := range lists[1:] {
+		result = intersectSorted(result, list)
+	}
+
+	kinds := make(map[string]bool, len(filters.Kinds))
+	for _, k := range filters.Kinds {
+		kinds[k] = true
+	}
+	var out []int
+	for _, id := range result {
+		doc := si.docs[id]
+		switch {
+		case doc.deleted:
+		case filters.Project != "" && filepath.Base(filepath.Dir(doc.file)) != filters.Project:
+		case filters.SessionID != "" && strings.TrimSuffix(filepath.Base(doc.file), ".jsonl") != filters.SessionID:
+		case len(kinds) > 0 && !kinds[doc.kind]:
+		case !filters.Since.IsZero() && doc.timestamp.Before(filters.Since):
+		case !filters.Until.IsZero() && doc.timestamp.After(filters.Until):
+		default:
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// Refresh brings the index up to date with the session files on disk.
+// Grown files are indexed from where the last refresh stopped, rewritten or
+// truncated files are re-indexed, and deleted files are dropped. Files that
+// cannot be read are skipped until their size or modification time changes.
+// Files are read without holding the index lock, so searches are not held
+// up while a refresh reads.
+func (si *SearchIndex) Refresh() error {
+	si.refreshMu.Lock()
+	defer si.refreshMu.Unlock()
+
+	base, err := si.lister.resolveBasePath()
+	if err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(base, "projects", "*", "*.jsonl"))
+	if err != nil {
+		return fmt.Errorf("find sessions: %w", err)
+	}
+
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		seen[path] = true
+		stat, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		si.mu.Lock()
+		file := si.files[path]
+		si.mu.Unlock()
+		if file != nil && file.modTime.Equal(stat.ModTime()) && file.fileSize == stat.Size() {
+			continue
+		}
+		stamp := fileStamp{size: stat.Size(), modTime: stat.ModTime()}
+		if failed, ok := si.failed[path]; ok && failed.equal(stamp) {
+			continue
+		}
+		reset := file == nil || stat.Size() <= file.fileSize
+		var from int64
+		if !reset {
+			from = file.size
+		}
+		texts, end, err := readSearchTexts(path, from)
+		if err != nil {
+			si.failed[path] = stamp
+			continue
+		}
+		delete(si.failed, path)
+
+		si.mu.Lock()
+		if reset {
+			si.dropFile(path)
+			file = &searchFile{}
+			si.files[path] = file
+		}
+		si.addTexts(file, texts)
+		file.size = end
+		file.modTime = stat.ModTime()
+		file.fileSize = stat.Size()
+		si.mu.Unlock()
+	}
+
+	si.mu.Lock()
+	defer si.mu.Unlock()
+	for path := range si.files {
+		if !seen[path] {
+			si.dropFile(path)
+		}
+	}
+	for path := range si.failed {
+		if !seen[path] {
+			delete(si.failed, path)
+		}
+	}
+	if si.dead > 0 && float64(si.dead) >= maxDeadShare*float64(len(si.docs)) {
+		si.compact()
+	}
+	si.refresh = time.Now()
+	return nil
+}
+
+// dropFile marks all documents of path as deleted. Callers must hold si.mu.
+func (si *SearchIndex) dropFile(path string) {
+	file := si.files[path]
+	if file == nil {
+		return
+	}
+	for _, id := range file.docs {
+		si.docs[id].deleted = true
+	}
+	si.dead += len(file.docs)
+	delete(si.files, path)
+}
+
+// compact removes deleted documents from docs and the postings and
+// renumbers the live ones. Callers must hold si.mu.
+func (si *SearchIndex) compact() {
+	remap := make([]int, len(si.docs))
+	live := make([]searchDoc, 0, len(si.docs)-si.dead)
+	for id, doc := range si.docs {
+		if doc.deleted {
+			remap[id] = -1
+			continue
+		}
+		remap[id] = len(live)
+		live = append(live, doc)
+	}
+	si.docs = live
+	// Renumbering keeps the order, so posting lists stay sorted
+	for term, ids := range si.postings {
+		var kept []int
+		for _, id := range ids {
+			if n := remap[id]; n >= 0 {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) == 0 {
+			delete(si.postings, term)
+		} else {
+			si.postings[term] = kept
+		}
+	}
+	for _, file := range si.files {
+		for i, id := range file.docs {
+			file.docs[i] = remap[id]
+		}
+	}
+	si.dead = 0
+}
+
+// addTexts adds documents read from a file to the index. Callers must
+// hold si.mu.
+func (si *SearchIndex) addTexts(file *searchFile, texts []indexedText) {
+	for _, t := range texts {
+		id := len(si.docs)
+		si.docs = append(si.docs, t.doc)
+		file.docs = append(file.docs, id)
+		for _, term := range t.terms {
+			si.postings[term] = append(si.postings[term], id)
+		}
+	}
+}
+
+// readSearchTexts reads the documents in the complete lines of path after
+// offset from. It returns them with the offset indexing stopped at.
+func readSearchTexts(path string, from int64) ([]indexedText, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var texts []indexedText
+	end, err := scanCompleteLines(f, from, func(offset int64, line []byte) {
+		var entry sessionEntry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.IsMeta {
+			return
+		}
+		ts, _ := time.Parse(time.RFC3339, entry.Timestamp)
+		for part, st := range searchableTexts(entry) {
+			texts = append(texts, indexedText{
+				doc: searchDoc{
+					file:      path,
+					offset:    offset,
+					length:    len(line),
+					part:      part,
+					kind:      st.kind,
+					uuid:      entry.UUID,
+					timestamp: ts,
+				},
+				terms: uniqueTerms(st.text),
+			})
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return texts, end, nil
+}
+
+// searchText is a searchable piece of an entry with its kind.
+type searchText struct {
+	kind string
+	text string
+}
+
+// searchableTexts extracts user prompts, assistant text and tool inputs from
+// an entry. Thinking blocks and tool results are not indexed.
+func searchableTexts(entry sessionEntry) []searchText {
+	if entry.Message == nil || (entry.Type != "user" && entry.Type != "assistant") {
+		return nil
+	}
+	if s := extractStringContent(entry.Message.Content); s != "" {
+		return []searchText{{kind: entry.Type, text: s}}
+	}
+	var blocks []ContentBlock
+	if err := json.Unmarshal(entry.Message.Content, &blocks); err != nil {
+		return nil
+	}
+	var out []searchText
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			if b.Text != "" {
+				out = append(out, searchText{kind: entry.Type, text: b.Text})
+			}
+		case "tool_use":
+			if text := jsonStrings(b.Input); text != "" {
+				out = append(out, searchText{kind: "tool", text: b.Name + " " + text})
+			}
+		}
+	}
+	return out
+}
+
+// jsonStrings joins all string values found in a JSON document.
+func jsonStrings(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return ""
+	}
+	var parts []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			parts = append(parts, t)
+		case []interface{}:
+			for _, e := range t {
+				walk(e)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(t))
+			for k := range t {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(t[k])
+			}
+		}
+	}
+	walk(v)
+	return strings.Join(parts, "\n")
+}
+
+// readDocText re-reads the text of a document from its session file.
+func readDocText(doc searchDoc) (string, error) {
+	f, err := os.Open(doc.file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, doc.length)
+	if _, err := f.ReadAt(buf, doc.offset); err != nil {
+		return "", err
+	}
+	var entry sessionEntry
+	if err := json.Unmarshal(buf, &entry); err != nil {
+		return "", err
+	}
+	texts := searchableTexts(entry)
+	if doc.part >= len(texts) {
+		return "", fmt.Errorf("document part %d missing", doc.part)
+	}
+	return texts[doc.part].text, nil
+}
+
+// termSpan is a token with its byte position in the source text.
+type termSpan struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lowercase terms of letters, digits and underscores.
+func tokenize(text string) []termSpan {
+	var spans []termSpan
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			spans = append(spans, termSpan{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, termSpan{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return spans
+}
+
+// uniqueTerms returns the distinct terms of text in order of first appearance.
+func uniqueTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, span := range tokenize(text) {
+		if !seen[span.term] {
+			seen[span.term] = true
+			terms = append(terms, span.term)
+		}
+	}
+	return terms
+}
+
+// buildSnippet cuts a window of text around the first matching term and
+// returns it with the byte ranges of all matched terms inside the window.
+func buildSnippet(text string, terms []string) (string, [][2]int) {
+	want := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		want[t] = true
+	}
+	var matched []termSpan
+	for _, span := range tokenize(text) {
+		if want[span.term] {
+			matched = append(matched, span)
+		}
+	}
+
+	start, end := 0, len(text)
+	if len(matched) > 0 {
+		start = moveRunes(text, matched[0].start, -snippetRadius)
+		end = moveRunes(text, matched[0].end, snippetRadius)
+	} else {
+		end = moveRunes(text, 0, 2*snippetRadius)
+	}
+
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "..."
+	}
+	if end < len(text) {
+		suffix = "..."
+	}
+	snippet := prefix + text[start:end] + suffix
+
+	highlights := [][2]int{}
+	for _, span := range matched {
+		if span.start >= start && span.end <= end {
+			highlights = append(highlights, [2]int{span.start - start + len(prefix), span.end - start + len(prefix)})
+		}
+	}
+	// Flatten line breaks and tabs; each is a single byte, so offsets stay valid.
+	snippet = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, snippet)
+	return snippet, highlights
+}
+
+// moveRunes moves the byte position pos by n runes (negative moves back),
+// clamped to the bounds of text.
+func moveRunes(text string, pos int, n int) int {
+	for ; n < 0 && pos > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:pos])
+		pos -= size
+	}
+	for ; n > 0 && pos < len(text); n-- {
+		_, size := utf8.DecodeRuneInString(text[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// intersectSorted returns the common elements of two ascending slices.
+func intersectSorted(a, b []int) []int {
+	var out []int
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
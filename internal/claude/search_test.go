@@ -0,0 +1,289 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeProjectSession writes a session file under base/projects/project.
+func writeProjectSession(t *testing.T, base, project, sessionID string, lines []map[string]interface{}) string {
+	t.Helper()
+	dir := filepath.Join(base, "projects", project)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, sessionID+".jsonl")
+	writeJSONLFile(t, path, lines)
+	return path
+}
+
+func TestSearchIndex_Search(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeProjectSession(t, tmpDir, "-home-user-alpha", "s1", []map[string]interface{}{
+		{"type": "user", "uuid": "u1", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Please fix the migration bug in the users table"}},
+		{"type": "assistant", "uuid": "a1", "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{"role": "assistant", "content": []interface{}{
+			map[string]interface{}{"type": "thinking", "thinking": "secret migration thoughts"},
+			map[string]interface{}{"type": "text", "text": "I will look at the Migration files."},
+			map[string]interface{}{"type": "tool_use", "id": "t1", "name": "Grep", "input": map[string]interface{}{"pattern": "CreateUsersTable"}},
+		}}},
+	})
+	writeProjectSession(t, tmpDir, "-home-user-beta", "s2", []map[string]interface{}{
+		{"type": "user", "uuid": "u2", "timestamp": "2026-01-22T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Another migration question"}},
+	})
+
+	si := NewSearchIndex(NewSessionLister(tmpDir))
+
+	t.Run("matches across projects newest first", func(t *testing.T) {
+		hits, err := si.Search("migration", SearchFilters{})
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		if len(hits) != 3 {
+			t.Fatalf("expected 3 hits, got %d: %+v", len(hits), hits)
+		}
+		if hits[0].SessionID != "s2" || hits[0].Project != "-home-user-beta" {
+			t.Errorf("hits[0] = %+v, want s2 in beta", hits[0])
+		}
+		want, _ := time.Parse(time.RFC3339, "2026-01-22T10:00:00Z")
+		if !hits[0].Timestamp.Equal(want) {
+			t.Errorf("hits[0].Timestamp = %v", hits[0].Timestamp)
+		}
+	})
+
+	t.Run("all terms must match", func(t *testing.T) {
+		hits, err := si.Search("migration users", SearchFilters{})
+		if err != nil {
+			t.Fatalf("Search() error = %v", err)
+		}
+		if len(hits) != 1 || hits[0].UUID != "u1" || hits[0].Kind != "user" {
+			t.Errorf("unexpected hits: %+v", hits)
+		}
+	})
+
+	t.Run("indexes tool inputs but not thinking", func(t *testing.T) {
+		hits, _ := si.Search("createuserstable", SearchFilters{})
+		if len(hits) != 1 || hits[0].Kind != "tool" {
+			t.Errorf("unexpected tool hits: %+v", hits)
+		}
+		hits, _ = si.Search("secret", SearchFilters{})
+		if len(hits) != 0 {
+			t.Errorf("thinking should not be indexed: %+v", hits)
+		}
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		hits, _ := si.Search("migration", SearchFilters{Project: "-home-user-alpha"})
+		if len(hits) != 2 {
+			t.Errorf("project filter: got %d hits", len(hits))
+		}
+		hits, _ = si.Search("migration", SearchFilters{Kinds: []string{"assistant"}})
+		if len(hits) != 1 || hits[0].UUID != "a1" {
+			t.Errorf("kind filter: %+v", hits)
+		}
+		since, _ := time.Parse(time.RFC3339, "2026-01-21T00:00:00Z")
+		hits, _ = si.Search("migration", SearchFilters{Since: since})
+		if len(hits) != 1 || hits[0].SessionID != "s2" {
+			t.Errorf("since filter: %+v", hits)
+		}
+		hits, _ = si.Search("migration", SearchFilters{SessionID: "s1", Limit: 1})
+		if len(hits) != 1 || hits[0].SessionID != "s1" {
+			t.Errorf("session filter with limit: %+v", hits)
+		}
+	})
+
+	t.Run("highlights matched terms", func(t *testing.T) {
+		hits, _ := si.Search("MIGRATION", SearchFilters{SessionID: "s1", Kinds: []string{"assistant"}})
+		if len(hits) != 1 {
+			t.Fatalf("expected 1 hit, got %d", len(hits))
+		}
+		h := hits[0]
+		if len(h.Highlights) != 1 {
+			t.Fatalf("expected 1 highlight, got %v", h.Highlights)
+		}
+		if got := h.Snippet[h.Highlights[0][0]:h.Highlights[0][1]]; got != "Migration" {
+			t.Errorf("highlighted %q, want %q", got, "Migration")
+		}
+	})
+
+	t.Run("empty query returns no hits", func(t *testing.T) {
+		hits, err := si.Search("  !! ", SearchFilters{})
+		if err != nil || len(hits) != 0 {
+			t.Errorf("got %v, %v", hits, err)
+		}
+	})
+}
+
+func TestSearchIndex_IncrementalRefresh(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := writeProjectSession(t, tmpDir, "-p", "s1", []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "first words"}},
+	})
+	si := NewSearchIndex(NewSessionLister(tmpDir))
+	if hits, _ := si.Search("first", SearchFilters{}); len(hits) != 1 {
+		t.Fatalf("expected initial hit, got %d", len(hits))
+	}
+	refresh := func(t *testing.T) {
+		t.Helper()
+		if err := si.Refresh(); err != nil {
+			t.Fatalf("Refresh() error = %v", err)
+		}
+	}
+
+	t.Run("appended lines become searchable", func(t *testing.T) {
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		f.WriteString(`{"type":"user","timestamp":"2026-01-20T10:01:00Z","message":{"role":"user","content":"second words"}}` + "\n")
+		f.Close()
+		future := time.Now().Add(time.Second)
+		os.Chtimes(path, future, future)
+		refresh(t)
+
+		hits, _ := si.Search("words", SearchFilters{})
+		if len(hits) != 2 {
+			t.Errorf("expected 2 hits after append, got %d", len(hits))
+		}
+	})
+
+	t.Run("rewritten files are re-indexed", func(t *testing.T) {
+		writeJSONLFile(t, path, []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "replaced"}},
+		})
+		later := time.Now().Add(2 * time.Second)
+		os.Chtimes(path, later, later)
+		refresh(t)
+
+		if hits, _ := si.Search("words", SearchFilters{}); len(hits) != 0 {
+			t.Errorf("expected stale hits to be gone, got %d", len(hits))
+		}
+		if hits, _ := si.Search("replaced", SearchFilters{}); len(hits) != 1 {
+			t.Errorf("expected new content to be found, got %d", len(hits))
+		}
+	})
+
+	t.Run("deleted files are dropped", func(t *testing.T) {
+		os.Remove(path)
+		refresh(t)
+		if hits, _ := si.Search("replaced", SearchFilters{}); len(hits) != 0 {
+			t.Errorf("expected no hits after delete, got %d", len(hits))
+		}
+	})
+}
+
+func TestSearchIndex_SearchRefreshesOnlyWhenStale(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeProjectSession(t, tmpDir, "-p", "s1", []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "first words"}},
+	})
+	si := NewSearchIndex(NewSessionLister(tmpDir))
+	if hits, _ := si.Search("words", SearchFilters{}); len(hits) != 1 {
+		t.Fatalf("expected the first search to refresh, got %d hits", len(hits))
+	}
+
+	writeProjectSession(t, tmpDir, "-p", "s2", []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:01:00Z", "message": map[string]interface{}{"role": "user", "content": "second words"}},
+	})
+	if hits, _ := si.Search("words", SearchFilters{}); len(hits) != 1 {
+		t.Errorf("expected no refresh within the interval, got %d hits", len(hits))
+	}
+
+	si.mu.Lock()
+	si.refresh = time.Now().Add(-searchRefreshInterval)
+	si.mu.Unlock()
+	if hits, _ := si.Search("words", SearchFilters{}); len(hits) != 2 {
+		t.Errorf("expected a stale index to be refreshed, got %d hits", len(hits))
+	}
+}
+
+func TestSearchIndex_RemembersUnreadableFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	// A directory matches the session glob but cannot be read as a file
+	bad := filepath.Join(tmpDir, "projects", "-p", "bad.jsonl")
+	if err := os.MkdirAll(bad, 0755); err != nil {
+		t.Fatal(err)
+	}
+	si := NewSearchIndex(NewSessionLister(tmpDir))
+	if err := si.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := si.failed[bad]; !ok || !got.equal(fileStamp{size: stat.Size(), modTime: stat.ModTime()}) {
+		t.Fatalf("failed[%s] = %+v, %v; want the file's stamp", bad, got, ok)
+	}
+
+	os.Remove(bad)
+	if err := si.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := si.failed[bad]; ok {
+		t.Error("expected removed files to be forgotten")
+	}
+}
+
+func TestSearchIndex_CompactsDroppedDocuments(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(i int) {
+		path := writeProjectSession(t, tmpDir, "-p", "s1", []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": fmt.Sprintf("version%02d shared", i)}},
+			{"type": "assistant", "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{"role": "assistant", "content": "shared answer"}},
+		})
+		stamp := time.Now().Add(time.Duration(i) * time.Second)
+		os.Chtimes(path, stamp, stamp)
+	}
+	si := NewSearchIndex(NewSessionLister(tmpDir))
+
+	// Every rewrite re-indexes the file; the old copies must not pile up
+	for i := 0; i < 20; i++ {
+		write(i)
+		if err := si.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	si.mu.Lock()
+	docs, dead, versions := len(si.docs), si.dead, len(si.postings["version00"])
+	si.mu.Unlock()
+	if docs > 4 || dead >= docs {
+		t.Errorf("index holds %d documents, %d deleted; want the dropped ones compacted", docs, dead)
+	}
+	if versions != 0 {
+		t.Errorf("postings of dropped documents kept: %d", versions)
+	}
+	if hits, _ := si.Search("shared", SearchFilters{}); len(hits) != 2 {
+		t.Errorf("Search(shared) = %d hits after compaction, want 2", len(hits))
+	}
+	if hits, _ := si.Search("version19", SearchFilters{}); len(hits) != 1 {
+		t.Errorf("Search(version19) = %d hits after compaction, want 1", len(hits))
+	}
+}
+
+func TestBuildSnippet(t *testing.T) {
+	text := strings.Repeat("lorem ", 30) + "needle\nhere " + strings.Repeat("ipsum ", 30)
+	snippet, highlights := buildSnippet(text, []string{"needle"})
+
+	if !strings.HasPrefix(snippet, "...") || !strings.HasSuffix(snippet, "...") {
+		t.Errorf("expected ellipses on both sides: %q", snippet)
+	}
+	if strings.Contains(snippet, "\n") {
+		t.Errorf("expected newlines to be flattened: %q", snippet)
+	}
+	if len(highlights) != 1 || snippet[highlights[0][0]:highlights[0][1]] != "needle" {
+		t.Errorf("unexpected highlights %v in %q", highlights, snippet)
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	spans := tokenize("Fix user_id in Größe-Tabelle!")
+	var terms []string
+	for _, s := range spans {
+		terms = append(terms, s.term)
+	}
+	want := []string{"fix", "user_id", "in", "größe", "tabelle"}
+	if strings.Join(terms, ",") != strings.Join(want, ",") {
+		t.Errorf("tokenize() = %v, want %v", terms, want)
+	}
+}
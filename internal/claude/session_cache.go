@@ -0,0 +1,502 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/atomicfile"
+)
+
+// metaCacheVersion is bumped whenever SessionInfo or SessionStats
+// extraction changes, so metadata cached by an older build is discarded
+// instead of served.
+const metaCacheVersion = 5
+
+// Head/tail parsing bounds. Files up to headTailThreshold bytes are parsed
+// completely; larger files are parsed from the head until the first-entry
+// fields are known and from the last tailSize bytes for the latest state.
+const (
+	headTailThreshold = 256 * 1024
+	tailSize          = 64 * 1024
+)
+
+// metaCacheEntry is the cached metadata of one session file.
+type metaCacheEntry struct {
+	Size    int64         `json:"size"`
+	ModTime time.Time     `json:"mod_time"`
+	Invalid bool          `json:"invalid,omitempty"` // File had no valid timestamp
+	Info    SessionInfo   `json:"info"`
+	Stats   *SessionStats `json:"stats,omitempty"` // Computed on first request
+}
+
+// metaCacheFile is the on-disk format of the metadata cache.
+type metaCacheFile struct {
+	Version int                       `json:"version"`
+	Entries map[string]metaCacheEntry `json:"entries"`
+}
+
+// metaCache maps session file paths to their parsed metadata, validated by
+// size and modification time. It is persisted as JSON when path is set.
+type metaCache struct {
+	path string
+
+	mu      sync.Mutex
+	loaded  bool
+	dirty   bool
+	entries map[string]metaCacheEntry
+}
+
+// lookup returns the cached entry for path if size and mtime still match.
+func (c *metaCache) lookup(path string, size int64, modTime time.Time) (metaCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	e, ok := c.entries[path]
+	if !ok || e.Size != size || !e.ModTime.Equal(modTime) {
+		return metaCacheEntry{}, false
+	}
+	return e, true
+}
+
+// store records the metadata of path.
+func (c *metaCache) store(path string, e metaCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	c.entries[path] = e
+	c.dirty = true
+}
+
+// prune removes entries for files in dir that are not in present.
+func (c *metaCache) prune(dir string, present map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	for path := range c.entries {
+		if filepath.Dir(path) == dir && !present[path] {
+			delete(c.entries, path)
+			c.dirty = true
+		}
+	}
+}
+
+// load reads the cache file once. A missing, unreadable or outdated file
+// starts an empty cache. Callers must hold c.mu.
+func (c *metaCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = make(map[string]metaCacheEntry)
+	if c.path == "" {
+		return
+	}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return
+	}
+	var file metaCacheFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != metaCacheVersion {
+		return
+	}
+	for k, v := range file.Entries {
+		c.entries[k] = v
+	}
+}
+
+// save writes the cache atomically if it changed since the last save.
+func (c *metaCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(metaCacheFile{Version: metaCacheVersion, Entries: c.entries})
+	if err != nil {
+		return fmt.Errorf("encode session cache: %w", err)
+	}
+	if err := atomicfile.WriteFile(c.path, data, 0600); err != nil {
+		return fmt.Errorf("write session cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
+
+// sessionFile is a session JSONL file found in a project directory.
+type sessionFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// sessionInfo returns the metadata of a single session file, serving it from
+// the cache while the file is unchanged and caching freshly parsed results.
+func (sl *SessionLister) sessionInfo(file sessionFile) (SessionInfo, error) {
+	cache := sl.metaCache()
+	if cached, ok := cache.lookup(file.path, file.size, file.modTime); ok {
+		if cached.Invalid {
+			return SessionInfo{}, fmt.Errorf("no valid timestamp found in %s", file.path)
+		}
+		return cached.Info, nil
+	}
+	info, err := parseSessionFileHeadTail(file.path, file.size)
+	if err != nil && os.IsNotExist(err) {
+		return SessionInfo{}, err
+	}
+	cache.store(file.path, metaCacheEntry{
+		Size:    file.size,
+		ModTime: file.modTime,
+		Invalid: err != nil,
+		Info:    info,
+	})
+	return info, err
+}
+
+// parseResult is the outcome of parsing one session file.
+type parseResult struct {
+	file sessionFile
+	info SessionInfo
+	err  error
+}
+
+// parseSessionFiles parses files with a bounded pool of workers.
+// Results are returned in the order of files.
+func parseSessionFiles(files []sessionFile, workers int) []parseResult {
+	results := make([]parseResult, len(files))
+	parallel(len(files), workers, func(i int) {
+		info, err := parseSessionFileHeadTail(files[i].path, files[i].size)
+		results[i] = parseResult{file: files[i], info: info, err: err}
+	})
+	return results
+}
+
+// parallel calls fn for each index in [0, n) on up to workers goroutines,
+// or runtime.NumCPU if workers is not positive, and waits for all calls.
+func parallel(n int, workers int, fn func(i int)) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > n {
+		workers = n
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// parseSessionFileHeadTail extracts the same metadata as parseSessionFile
+// without decoding every line of large files. The head is decoded until the
+// first timestamp, user message and model are known, and the last tailSize
+// bytes are decoded for the latest state. The middle is still read in full
+// to count messages, but its lines are classified by scanTopLevel; only
+// summaries and the last timestamped line are decoded. On message-heavy
+// files this takes about a quarter of the time of parseSessionFile
+// (BenchmarkParseSessionFile_*). Read errors such as oversized lines fall
+// back to parseSessionFile so results stay identical.
+func parseSessionFileHeadTail(path string, size int64) (SessionInfo, error) {
+	if size <= headTailThreshold {
+		return parseSessionFile(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+	defer f.Close()
+
+	b := newSessionInfoBuilder(path)
+
+	// Head: decode until the first-entry fields are complete.
+	headEnd, err := forEachLine(f, 0, size, func(line []byte) bool {
+		b.addLine(line)
+		return !b.headComplete()
+	})
+	if err != nil {
+		return parseSessionFile(path)
+	}
+	if headEnd >= size {
+		return b.finish()
+	}
+
+	// Tail: start at the first line boundary within the last tailSize bytes.
+	tailStart := size - tailSize
+	if tailStart < headEnd {
+		tailStart = headEnd
+	} else if tailStart, err = nextLineStart(f, tailStart, size); err != nil {
+		return parseSessionFile(path)
+	}
+
+	var lastTimestamped []byte
+	_, err = forEachLine(f, headEnd, tailStart, func(line []byte) bool {
+		fields, ok := entryFields(line)
+		if !ok {
+			return true
+		}
+		if fields.typ == "summary" {
+			var entry sessionEntry
+			if json.Unmarshal(line, &entry) == nil {
+				b.lastSummary = entry.Summary
+			}
+		}
+		if fields.isMessage() {
+			b.info.MessageCount++
+		}
+		if fields.timestamped {
+			lastTimestamped = line
+		}
+		return true
+	})
+	if err != nil {
+		return parseSessionFile(path)
+	}
+	var last sessionEntry
+	if lastTimestamped != nil && json.Unmarshal(lastTimestamped, &last) == nil {
+		b.observe(last)
+	}
+
+	if _, err := forEachLine(f, tailStart, size, func(line []byte) bool {
+		b.addLine(line)
+		return true
+	}); err != nil {
+		return parseSessionFile(path)
+	}
+	return b.finish()
+}
+
+// topLevelFields are the fields of a session entry that the middle of a
+// large file is classified by.
+type topLevelFields struct {
+	typ         string
+	isMeta      bool
+	isSidechain bool
+	timestamped bool
+}
+
+// isMessage reports whether the entry is counted in MessageCount by
+// sessionInfoBuilder.add.
+func (f topLevelFields) isMessage() bool {
+	return (f.typ == "user" || f.typ == "assistant") && !f.isMeta && !f.isSidechain
+}
+
+// entryFields returns the top-level fields of a line, decoding it only if
+// scanTopLevel cannot read them. ok is false for invalid lines.
+func entryFields(line []byte) (topLevelFields, bool) {
+	if fields, ok := scanTopLevel(line); ok {
+		return fields, true
+	}
+	var entry sessionEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return topLevelFields{}, false
+	}
+	return topLevelFields{
+		typ:         entry.Type,
+		isMeta:      entry.IsMeta,
+		isSidechain: entry.IsSidechain,
+		timestamped: entry.Timestamp != "",
+	}, true
+}
+
+// scanTopLevel reads the top-level fields of a JSON object without decoding
+// it. Nested objects and arrays are skipped, so messages nested in progress
+// and tool result entries are not mistaken for the entry itself, and
+// strings are skipped by searching for their closing quote. ok is false if
+// the line is not a single object or escapes the type value; callers then
+// decode the line instead. Scalars are not validated, so a line that is
+// not quite valid JSON may still be classified.
+func scanTopLevel(line []byte) (fields topLevelFields, ok bool) {
+	i := skipSpace(line, 0)
+	if i >= len(line) || line[i] != '{' {
+		return fields, false
+	}
+	i = skipSpace(line, i+1)
+	if i < len(line) && line[i] == '}' {
+		return fields, skipSpace(line, i+1) == len(line)
+	}
+	for {
+		keyStart := i + 1
+		if i = skipString(line, i); i < 0 {
+			return fields, false
+		}
+		key := line[keyStart : i-1]
+		if i = skipSpace(line, i); i >= len(line) || line[i] != ':' {
+			return fields, false
+		}
+		valueStart := skipSpace(line, i+1)
+		if i = skipValue(line, valueStart); i < 0 {
+			return fields, false
+		}
+		value := line[valueStart:i]
+		switch string(key) {
+		case "type":
+			if len(value) < 2 || value[0] != '"' || bytes.IndexByte(value, '\\') >= 0 {
+				return fields, false
+			}
+			fields.typ = string(value[1 : len(value)-1])
+		case "isMeta":
+			fields.isMeta = string(value) == "true"
+		case "isSidechain":
+			fields.isSidechain = string(value) == "true"
+		case "timestamp":
+			fields.timestamped = len(value) > 2 && value[0] == '"'
+		}
+		i = skipSpace(line, i)
+		if i >= len(line) {
+			return fields, false
+		}
+		switch line[i] {
+		case ',':
+			i = skipSpace(line, i+1)
+		case '}':
+			return fields, skipSpace(line, i+1) == len(line)
+		default:
+			return fields, false
+		}
+	}
+}
+
+// skipSpace returns the index of the first non-whitespace byte at or after i.
+func skipSpace(b []byte, i int) int {
+	for i < len(b) && (b[i] == ' ' || b[i] == '\t' || b[i] == '\n' || b[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// skipString returns the index just past the JSON string starting at i,
+// or -1 if there is none.
+func skipString(b []byte, i int) int {
+	if i >= len(b) || b[i] != '"' {
+		return -1
+	}
+	for i++; ; i++ {
+		j := bytes.IndexByte(b[i:], '"')
+		if j < 0 {
+			return -1
+		}
+		i += j
+		// The quote is escaped if an odd number of backslashes precede it.
+		n := 0
+		for k := i - 1; b[k] == '\\'; k-- {
+			n++
+		}
+		if n%2 == 0 {
+			return i + 1
+		}
+	}
+}
+
+// skipValue returns the index just past the JSON value starting at i, or -1
+// if it is unterminated. Scalars other than strings end at the next
+// delimiter and are not validated.
+func skipValue(b []byte, i int) int {
+	if i >= len(b) {
+		return -1
+	}
+	switch b[i] {
+	case '"':
+		return skipString(b, i)
+	case '{', '[':
+		depth := 0
+		for i < len(b) {
+			switch b[i] {
+			case '"':
+				if i = skipString(b, i); i < 0 {
+					return -1
+				}
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			}
+			i++
+		}
+		return -1
+	}
+	start := i
+	for i < len(b) && b[i] != ',' && b[i] != '}' && b[i] != ']' && b[i] != ' ' && b[i] != '\t' && b[i] != '\n' && b[i] != '\r' {
+		i++
+	}
+	if i == start {
+		return -1
+	}
+	return i
+}
+
+// forEachLine calls fn for each line in [start, end) of f until fn returns
+// false. It returns the offset just past the last line passed to fn.
+func forEachLine(f io.ReaderAt, start, end int64, fn func(line []byte) bool) (int64, error) {
+	pos := start
+	reader := bufio.NewReaderSize(io.NewSectionReader(f, start, end-start), 64*1024)
+	for {
+		line, err := readLine(reader)
+		pos += int64(len(line))
+		if len(line) > 0 {
+			if !fn(bytes.TrimRight(line, "\r\n")) {
+				return pos, nil
+			}
+		}
+		if err == io.EOF {
+			return pos, nil
+		}
+		if err != nil {
+			return pos, err
+		}
+	}
+}
+
+// readLine reads a full line including its newline, up to maxSessionLineSize.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+		if len(line) > maxSessionLineSize {
+			return line, bufio.ErrTooLong
+		}
+	}
+}
+
+// nextLineStart returns the offset of the first line starting at or after
+// pos, assuming pos may point into the middle of a line.
+func nextLineStart(f io.ReaderAt, pos, end int64) (int64, error) {
+	if pos == 0 {
+		return 0, nil
+	}
+	// Start reading one byte early: if that byte is the newline ending the
+	// previous line, pos already starts a line.
+	reader := bufio.NewReader(io.NewSectionReader(f, pos-1, end-pos+1))
+	partial, err := readLine(reader)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	return pos - 1 + int64(len(partial)), nil
+}
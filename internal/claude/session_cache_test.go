@@ -0,0 +1,555 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLargeSession writes a session with n filler turns, each about 1 KiB,
+// and summary entries after the turns listed in summaryAfter.
+func writeLargeSession(tb testing.TB, path string, n int, summaryAfter ...int) {
+	tb.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	write := func(v map[string]interface{}) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		f.Write(data)
+		f.Write([]byte("\n"))
+	}
+	summaries := make(map[int]bool)
+	for _, i := range summaryAfter {
+		summaries[i] = true
+	}
+	filler := strings.Repeat("x", 1000)
+	start := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		branch := "main"
+		if i >= n/2 {
+			branch = "feature"
+		}
+		entry := func(typ string, message map[string]interface{}, offset time.Duration) map[string]interface{} {
+			return map[string]interface{}{
+				"type":      typ,
+				"timestamp": ts.Add(offset).Format(time.RFC3339),
+				"cwd":       "/home/user/project",
+				"gitBranch": branch,
+				"version":   "2.0.1",
+				"message":   message,
+			}
+		}
+		write(entry("user", map[string]interface{}{"role": "user", "content": fmt.Sprintf("turn %d mentions \"summary\" %s", i, filler)}, 0))
+		if i%50 == 25 {
+			meta := entry("user", map[string]interface{}{"role": "user", "content": "caveat"}, 0)
+			meta["isMeta"] = true
+			write(meta)
+			side := entry("assistant", map[string]interface{}{"role": "assistant", "content": []interface{}{}, "model": "claude-haiku-4-5"}, 0)
+			side["isSidechain"] = true
+			write(side)
+		}
+		write(entry("assistant", map[string]interface{}{"role": "assistant", "content": []interface{}{map[string]interface{}{"type": "text", "text": filler}}, "model": "claude-sonnet-4-20250514"}, time.Second))
+		if summaries[i] {
+			write(map[string]interface{}{"type": "summary", "summary": fmt.Sprintf("Summary after %d", i)})
+		}
+	}
+}
+
+func TestParseSessionFileHeadTail_MatchesFullParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		summaryAfter []int
+		wantSummary  string
+	}{
+		{name: "no summary", wantSummary: ""},
+		{name: "summary in tail", summaryAfter: []int{5, 499}, wantSummary: "Summary after 499"},
+		{name: "summary in middle", summaryAfter: []int{100, 250}, wantSummary: "Summary after 250"},
+		{name: "summary in head only", summaryAfter: []int{0}, wantSummary: "Summary after 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "large.jsonl")
+			writeLargeSession(t, path, 500, tt.summaryAfter...)
+			stat, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat.Size() <= headTailThreshold {
+				t.Fatalf("test file is %d bytes, want more than %d", stat.Size(), headTailThreshold)
+			}
+
+			want, err := parseSessionFile(path)
+			if err != nil {
+				t.Fatalf("parseSessionFile() error = %v", err)
+			}
+			got, err := parseSessionFileHeadTail(path, stat.Size())
+			if err != nil {
+				t.Fatalf("parseSessionFileHeadTail() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("parseSessionFileHeadTail() = %+v, want %+v", got, want)
+			}
+			if got.Summary != tt.wantSummary {
+				t.Errorf("Summary = %q, want %q", got.Summary, tt.wantSummary)
+			}
+			wantLast := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC).Add(499*time.Minute + time.Second)
+			if got.MessageCount != 1000 || !got.LastActivity.Equal(wantLast) || got.GitBranch != "feature" || got.CLIVersion != "2.0.1" {
+				t.Errorf("MessageCount = %d, LastActivity = %v, GitBranch = %q, CLIVersion = %q", got.MessageCount, got.LastActivity, got.GitBranch, got.CLIVersion)
+			}
+		})
+	}
+}
+
+func TestParseSessionFileHeadTail_NestedMessages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested.jsonl")
+	writeLargeSession(t, path, 300)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Entries as the CLI writes them for subagents and tool calls: the
+	// nested objects carry the type, meta and sidechain markers of the
+	// messages they wrap.
+	nested := []string{
+		`{"type":"progress","timestamp":"2026-01-20T12:00:00Z","data":{"message":{"type":"assistant","message":{"role":"assistant","content":[{"type":"text","text":"sub"}]}}}}`,
+		`{"type":"progress","timestamp":"2026-01-20T12:00:01Z","data":{"message":{"type":"user","isSidechain":true,"message":{"role":"user","content":"sub task"}}}}`,
+		`{"type":"progress","timestamp":"2026-01-20T12:00:01Z","data":{"message":{"type":"user","message":{"role":"user","content":"sub result"}}}}`,
+		`{"type":"user","timestamp":"2026-01-20T12:00:02Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]},"toolUseResult":{"agent":{"isSidechain":true,"isMeta":true}}}`,
+		`{"type":"assistant","timestamp":"2026-01-20T12:00:03Z","message":{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"Task","input":{"prompt":"{\"type\":\"user\"}"}}]}}`,
+	}
+	for i := 0; i < 40; i++ {
+		for _, line := range nested {
+			f.WriteString(line + "\n")
+		}
+	}
+	// Keep the nested entries out of the tail
+	filler := strings.Repeat("y", 1000)
+	for i := 0; i < 80; i++ {
+		fmt.Fprintf(f, `{"type":"system","timestamp":"2026-01-20T13:00:00Z","cwd":"/home/user/project","gitBranch":"feature","version":"2.0.1","content":"%s"}`+"\n", filler)
+	}
+	f.Close()
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := parseSessionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseSessionFileHeadTail(path, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSessionFileHeadTail() = %+v, want %+v", got, want)
+	}
+	if want.MessageCount != 600+2*40 {
+		t.Errorf("MessageCount = %d, want %d", want.MessageCount, 600+2*40)
+	}
+}
+
+func TestParseSessionFileHeadTail_LatestStateInMiddle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large.jsonl")
+	writeLargeSession(t, path, 300)
+	// Summaries without timestamps fill the tail, so the last entry with a
+	// timestamp lies in the middle.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `{"type":"summary","summary":"` + strings.Repeat("y", 1000) + `"}` + "\n"
+	for i := 0; i < 2*tailSize/len(line); i++ {
+		f.WriteString(line)
+	}
+	f.Close()
+
+	want, err := parseSessionFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseSessionFileHeadTail(path, statSize(t, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSessionFileHeadTail() = %+v, want %+v", got, want)
+	}
+	if wantLast := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC).Add(299*time.Minute + time.Second); !got.LastActivity.Equal(wantLast) {
+		t.Errorf("LastActivity = %v, want %v", got.LastActivity, wantLast)
+	}
+}
+
+func TestParseSessionFileHeadTail_NoTimestamp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "large.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := `{"type":"summary","summary":"` + strings.Repeat("y", 1000) + `"}` + "\n"
+	for i := 0; i < 300; i++ {
+		f.WriteString(line)
+	}
+	f.Close()
+
+	if _, err := parseSessionFileHeadTail(path, 300*int64(len(line))); err == nil {
+		t.Error("parseSessionFileHeadTail() error = nil, want error for file without timestamp")
+	}
+}
+
+func TestNextLineStart(t *testing.T) {
+	r := strings.NewReader("ab\ncd\nef")
+	tests := []struct {
+		pos  int64
+		want int64
+	}{
+		{0, 0},
+		{1, 3},
+		{3, 3},
+		{4, 6},
+		{7, 8},
+	}
+	for _, tt := range tests {
+		got, err := nextLineStart(r, tt.pos, 8)
+		if err != nil {
+			t.Fatalf("nextLineStart(%d) error = %v", tt.pos, err)
+		}
+		if got != tt.want {
+			t.Errorf("nextLineStart(%d) = %d, want %d", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestEntryFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   topLevelFields
+		wantOK bool
+	}{
+		{
+			name:   "user message",
+			line:   `{"parentUuid":null,"isSidechain":false,"type":"user","message":{"role":"user","content":"hi"},"timestamp":"2026-01-20T10:00:00Z"}`,
+			want:   topLevelFields{typ: "user", timestamped: true},
+			wantOK: true,
+		},
+		{
+			name:   "nested message in progress entry",
+			line:   `{"type":"progress","data":{"message":{"type":"assistant","isMeta":false}},"toolUseResult":["{\"type\":\"user\"}"]}`,
+			want:   topLevelFields{typ: "progress"},
+			wantOK: true,
+		},
+		{
+			name:   "meta and sidechain",
+			line:   `{ "type" : "user", "isMeta" : true, "isSidechain": true, "timestamp": "" }`,
+			want:   topLevelFields{typ: "user", isMeta: true, isSidechain: true},
+			wantOK: true,
+		},
+		{
+			name:   "escaped quotes in strings",
+			line:   `{"message":{"content":"say \"}\" and \\"},"type":"assistant"}`,
+			want:   topLevelFields{typ: "assistant"},
+			wantOK: true,
+		},
+		{
+			name:   "escaped type falls back to decoding",
+			line:   `{"type":"us\u0065r"}`,
+			want:   topLevelFields{typ: "user"},
+			wantOK: true,
+		},
+		{
+			name:   "truncated line followed by another entry",
+			line:   `{"type":"assistant","message":{"content":"cut{"type":"user"}`,
+			wantOK: false,
+		},
+		{
+			name:   "not an object",
+			line:   `["type","user"]`,
+			wantOK: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := entryFields([]byte(tt.line))
+			if ok != tt.wantOK {
+				t.Fatalf("entryFields() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got != tt.want {
+				t.Errorf("entryFields() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListSessions_Cache(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		tmpDir := t.TempDir()
+		projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeJSONLFile(t, filepath.Join(projectDir, "s1.jsonl"), []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "First"}},
+		})
+		return tmpDir, projectDir
+	}
+
+	t.Run("serves unchanged files from cache", func(t *testing.T) {
+		tmpDir, projectDir := setup(t)
+		sl := NewSessionLister(tmpDir)
+		if _, err := sl.ListSessions("/home/user/project"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Poison the cached entry: a hit must return it unchanged.
+		path := filepath.Join(projectDir, "s1.jsonl")
+		entry, ok := sl.cache.lookup(path, statSize(t, path), statModTime(t, path))
+		if !ok {
+			t.Fatal("file not cached after listing")
+		}
+		entry.Info.FirstMessage = "from cache"
+		sl.cache.store(path, entry)
+
+		sessions, err := sl.ListSessions("/home/user/project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].FirstMessage != "from cache" {
+			t.Errorf("ListSessions() = %+v, want cached entry", sessions)
+		}
+	})
+
+	t.Run("reparses modified files", func(t *testing.T) {
+		tmpDir, projectDir := setup(t)
+		sl := NewSessionLister(tmpDir)
+		if _, err := sl.ListSessions("/home/user/project"); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(projectDir, "s1.jsonl")
+		writeJSONLFile(t, path, []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Rewritten"}},
+			{"type": "summary", "summary": "Now summarized"},
+		})
+		future := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, future, future); err != nil {
+			t.Fatal(err)
+		}
+
+		sessions, err := sl.ListSessions("/home/user/project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].Summary != "Now summarized" {
+			t.Errorf("ListSessions() = %+v, want reparsed entry", sessions)
+		}
+	})
+
+	t.Run("prunes deleted files", func(t *testing.T) {
+		tmpDir, projectDir := setup(t)
+		sl := NewSessionLister(tmpDir)
+		if _, err := sl.ListSessions("/home/user/project"); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(filepath.Join(projectDir, "s1.jsonl"))
+
+		sessions, err := sl.ListSessions("/home/user/project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 0 {
+			t.Errorf("ListSessions() returned %d sessions, want 0", len(sessions))
+		}
+		if n := len(sl.cache.entries); n != 0 {
+			t.Errorf("cache has %d entries after delete, want 0", n)
+		}
+	})
+
+	t.Run("remembers invalid files", func(t *testing.T) {
+		tmpDir, projectDir := setup(t)
+		path := filepath.Join(projectDir, "bad.jsonl")
+		os.WriteFile(path, []byte("not json\n"), 0644)
+		sl := NewSessionLister(tmpDir)
+
+		sessions, err := sl.ListSessions("/home/user/project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 {
+			t.Errorf("ListSessions() returned %d sessions, want 1", len(sessions))
+		}
+		entry, ok := sl.cache.lookup(path, statSize(t, path), statModTime(t, path))
+		if !ok || !entry.Invalid {
+			t.Errorf("cache entry = %+v, %v; want invalid entry", entry, ok)
+		}
+	})
+
+	t.Run("persists to CachePath", func(t *testing.T) {
+		tmpDir, _ := setup(t)
+		cachePath := filepath.Join(t.TempDir(), "cache", "session-meta.json")
+
+		sl := NewSessionLister(tmpDir)
+		sl.CachePath = cachePath
+		want, err := sl.ListSessions("/home/user/project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(cachePath); err != nil {
+			t.Fatalf("cache file not written: %v", err)
+		}
+
+		fresh := NewSessionLister(tmpDir)
+		fresh.CachePath = cachePath
+		got, err := fresh.ListSessions("/home/user/project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || !reflect.DeepEqual(got[0], want[0]) {
+			t.Errorf("ListSessions() from persisted cache = %+v, want %+v", got, want)
+		}
+		if fresh.cache.dirty {
+			t.Error("cache dirty after pure cache hit")
+		}
+	})
+
+	t.Run("ignores cache of another version", func(t *testing.T) {
+		tmpDir, projectDir := setup(t)
+		cachePath := filepath.Join(t.TempDir(), "session-meta.json")
+		path := filepath.Join(projectDir, "s1.jsonl")
+		stale := metaCacheFile{
+			Version: metaCacheVersion + 1,
+			Entries: map[string]metaCacheEntry{
+				path: {Size: statSize(t, path), ModTime: statModTime(t, path), Info: SessionInfo{ID: "s1", FirstMessage: "stale"}},
+			},
+		}
+		data, _ := json.Marshal(stale)
+		os.WriteFile(cachePath, data, 0644)
+
+		sl := NewSessionLister(tmpDir)
+		sl.CachePath = cachePath
+		sessions, err := sl.ListSessions("/home/user/project")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sessions) != 1 || sessions[0].FirstMessage != "First" {
+			t.Errorf("ListSessions() = %+v, want freshly parsed entry", sessions)
+		}
+	})
+}
+
+func TestParseSessionFiles_KeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	var files []sessionFile
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("s%02d.jsonl", i))
+		writeJSONLFile(t, path, []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": fmt.Sprintf("msg %d", i)}},
+		})
+		files = append(files, sessionFile{path: path, size: statSize(t, path)})
+	}
+
+	results := parseSessionFiles(files, 4)
+	for i, res := range results {
+		if res.err != nil {
+			t.Fatalf("results[%d].err = %v", i, res.err)
+		}
+		if want := fmt.Sprintf("msg %d", i); res.info.FirstMessage != want {
+			t.Errorf("results[%d].FirstMessage = %q, want %q", i, res.info.FirstMessage, want)
+		}
+	}
+}
+
+func statSize(t *testing.T, path string) int64 {
+	t.Helper()
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stat.Size()
+}
+
+func statModTime(t *testing.T, path string) time.Time {
+	t.Helper()
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stat.ModTime()
+}
+
+// setupBenchProject writes n large sessions into a fresh base directory.
+func setupBenchProject(b *testing.B, n int) string {
+	b.Helper()
+	base := b.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		writeLargeSession(b, filepath.Join(projectDir, fmt.Sprintf("s%03d.jsonl", i)), 300, 150)
+	}
+	return base
+}
+
+func BenchmarkListSessions_Cold(b *testing.B) {
+	base := setupBenchProject(b, 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sl := NewSessionLister(base)
+		if _, err := sl.ListSessions("/home/user/project"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkListSessions_Warm(b *testing.B) {
+	base := setupBenchProject(b, 20)
+	sl := NewSessionLister(base)
+	if _, err := sl.ListSessions("/home/user/project"); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sl.ListSessions("/home/user/project"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseSessionFile_Full(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "large.jsonl")
+	writeLargeSession(b, path, 1000, 900)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseSessionFile(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseSessionFile_HeadTail(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "large.jsonl")
+	writeLargeSession(b, path, 1000, 900)
+	stat, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseSessionFileHeadTail(path, stat.Size()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/atomicfile"
+)
+
+// SessionMeta is user-managed metadata for a session. It lives in a sidecar
+// file owned by the app, so Claude's JSONL files are never modified.
+type SessionMeta struct {
+	Title     string    `json:"title,omitempty"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Archived  bool      `json:"archived,omitempty"`
+	TrashedAt time.Time `json:"trashed_at,omitzero"`
+}
+
+// isZero reports whether m carries no metadata and can be dropped.
+func (m SessionMeta) isZero() bool {
+	return m.Title == "" && !m.Pinned && len(m.Tags) == 0 && !m.Archived && m.TrashedAt.IsZero()
+}
+
+// hasTag reports whether m is tagged with tag, ignoring case.
+func (m SessionMeta) hasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetaStore persists SessionMeta keyed by session ID as a JSON file.
+// Session IDs are UUIDs, so one store serves every project. The zero value
+// keeps metadata in memory only.
+type MetaStore struct {
+	path string
+
+	mu     sync.Mutex
+	loaded bool
+	data   map[string]SessionMeta
+}
+
+// NewMetaStore creates a store backed by the JSON file at path.
+// The file is created on the first change.
+func NewMetaStore(path string) *MetaStore {
+	return &MetaStore{path: path}
+}
+
+// Get returns the metadata of a session.
+func (s *MetaStore) Get(sessionID string) (SessionMeta, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return SessionMeta{}, err
+	}
+	return cloneMeta(s.data[sessionID]), nil
+}
+
+// Rename sets the custom title of a session. An empty title restores the
+// summary-based title.
+func (s *MetaStore) Rename(sessionID string, title string) error {
+	return s.update(sessionID, func(m *SessionMeta) { m.Title = strings.TrimSpace(title) })
+}
+
+// SetPinned pins or unpins a session.
+func (s *MetaStore) SetPinned(sessionID string, pinned bool) error {
+	return s.update(sessionID, func(m *SessionMeta) { m.Pinned = pinned })
+}
+
+// SetArchived archives or unarchives a session.
+func (s *MetaStore) SetArchived(sessionID string, archived bool) error {
+	return s.update(sessionID, func(m *SessionMeta) { m.Archived = archived })
+}
+
+// SetTags replaces the tags of a session. Tags are trimmed, empty tags are
+// dropped and duplicates are removed ignoring case; the result is sorted.
+func (s *MetaStore) SetTags(sessionID string, tags []string) error {
+	return s.update(sessionID, func(m *SessionMeta) { m.Tags = normalizeTags(tags) })
+}
+
+// setTrashed records when a session was moved to the trash, or clears it.
+func (s *MetaStore) setTrashed(sessionID string, at time.Time) error {
+	return s.update(sessionID, func(m *SessionMeta) { m.TrashedAt = at })
+}
+
+// update applies fn to the metadata of a session and saves the store.
+func (s *MetaStore) update(sessionID string, fn func(*SessionMeta)) error {
+	if sessionID == "" {
+		return fmt.Errorf("invalid session id %q", sessionID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	m := cloneMeta(s.data[sessionID])
+	fn(&m)
+	if m.isZero() {
+		delete(s.data, sessionID)
+	} else {
+		s.data[sessionID] = m
+	}
+	return s.save()
+}
+
+// load reads the store file once. A missing file is an empty store; a
+// corrupt one is an error, so it is not overwritten by the next change.
+// Callers must hold s.mu.
+func (s *MetaStore) load() error {
+	if s.loaded {
+		return nil
+	}
+	s.data = make(map[string]SessionMeta)
+	if s.path != "" {
+		data, err := os.ReadFile(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("read session metadata: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &s.data); err != nil {
+				return fmt.Errorf("parse session metadata: %w", err)
+			}
+		}
+	}
+	s.loaded = true
+	return nil
+}
+
+// save writes the store atomically. Callers must hold s.mu.
+func (s *MetaStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode session metadata: %w", err)
+	}
+	if err := atomicfile.WriteFile(s.path, data, 0600); err != nil {
+		return fmt.Errorf("write session metadata: %w", err)
+	}
+	return nil
+}
+
+func cloneMeta(m SessionMeta) SessionMeta {
+	m.Tags = append([]string(nil), m.Tags...)
+	return m
+}
+
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		key := strings.ToLower(t)
+		if t == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return strings.ToLower(out[i]) < strings.ToLower(out[j]) })
+	return out
+}
+
+// SessionFilter selects sessions by their user-managed metadata.
+// The zero value lists every session that is not archived.
+type SessionFilter struct {
+	Tags            []string `json:"tags,omitempty"` // Sessions must carry all of these tags
+	PinnedOnly      bool     `json:"pinned_only,omitempty"`
+	IncludeArchived bool     `json:"include_archived,omitempty"`
+	ArchivedOnly    bool     `json:"archived_only,omitempty"`
+	WithStats       bool     `json:"with_stats,omitempty"` // Fill SessionInfo.Stats; reads whole files on a cache miss
+}
+
+// matches reports whether a session with metadata m passes the filter.
+func (f SessionFilter) matches(m SessionMeta) bool {
+	switch {
+	case f.ArchivedOnly && !m.Archived:
+		return false
+	case m.Archived && !f.IncludeArchived && !f.ArchivedOnly:
+		return false
+	case f.PinnedOnly && !m.Pinned:
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !m.hasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// applyMeta copies user-managed metadata onto a session.
+func applyMeta(info *SessionInfo, m SessionMeta) {
+	info.Title = m.Title
+	info.Pinned = m.Pinned
+	info.Tags = m.Tags
+	info.Archived = m.Archived
+}
+
+// RenameSession sets the custom title of a session.
+func (sl *SessionLister) RenameSession(sessionID string, title string) error {
+	return sl.metaStore().Rename(sessionID, title)
+}
+
+// SetSessionPinned pins or unpins a session.
+func (sl *SessionLister) SetSessionPinned(sessionID string, pinned bool) error {
+	return sl.metaStore().SetPinned(sessionID, pinned)
+}
+
+// SetSessionTags replaces the tags of a session.
+func (sl *SessionLister) SetSessionTags(sessionID string, tags []string) error {
+	return sl.metaStore().SetTags(sessionID, tags)
+}
+
+// SetSessionArchived archives or unarchives a session.
+func (sl *SessionLister) SetSessionArchived(sessionID string, archived bool) error {
+	return sl.metaStore().SetArchived(sessionID, archived)
+}
@@ -0,0 +1,156 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMetaStore(t *testing.T) {
+	t.Run("persists changes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dogma", "sessions.json")
+		store := NewMetaStore(path)
+		if err := store.Rename("s1", "  Fix login  "); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetPinned("s1", true); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetTags("s1", []string{"bug", " ", "Auth", "BUG"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SetArchived("s2", true); err != nil {
+			t.Fatal(err)
+		}
+
+		reloaded := NewMetaStore(path)
+		got, err := reloaded.Get("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := SessionMeta{Title: "Fix login", Pinned: true, Tags: []string{"Auth", "bug"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Get(s1) = %+v, want %+v", got, want)
+		}
+		if got, _ := reloaded.Get("s2"); !got.Archived {
+			t.Errorf("Get(s2).Archived = false, want true")
+		}
+	})
+
+	t.Run("drops empty entries", func(t *testing.T) {
+		store := NewMetaStore("")
+		store.SetPinned("s1", true)
+		store.SetPinned("s1", false)
+		if _, ok := store.data["s1"]; ok {
+			t.Error("entry kept after clearing all metadata")
+		}
+	})
+
+	t.Run("rejects empty session id", func(t *testing.T) {
+		if err := NewMetaStore("").Rename("", "x"); err == nil {
+			t.Error("Rename() error = nil, want error")
+		}
+	})
+
+	t.Run("does not overwrite corrupt file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.json")
+		os.WriteFile(path, []byte("{corrupt"), 0644)
+		store := NewMetaStore(path)
+		if err := store.SetPinned("s1", true); err == nil {
+			t.Error("SetPinned() error = nil, want parse error")
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != "{corrupt" {
+			t.Errorf("file overwritten: %q", data)
+		}
+	})
+
+	t.Run("returns copies", func(t *testing.T) {
+		store := NewMetaStore("")
+		store.SetTags("s1", []string{"a"})
+		got, _ := store.Get("s1")
+		got.Tags[0] = "changed"
+		if again, _ := store.Get("s1"); again.Tags[0] != "a" {
+			t.Errorf("store modified through returned tags: %v", again.Tags)
+		}
+	})
+}
+
+func TestSessionFilter_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter SessionFilter
+		meta   SessionMeta
+		want   bool
+	}{
+		{"zero filter keeps plain session", SessionFilter{}, SessionMeta{}, true},
+		{"zero filter hides archived", SessionFilter{}, SessionMeta{Archived: true}, false},
+		{"include archived", SessionFilter{IncludeArchived: true}, SessionMeta{Archived: true}, true},
+		{"archived only drops active", SessionFilter{ArchivedOnly: true}, SessionMeta{}, false},
+		{"archived only keeps archived", SessionFilter{ArchivedOnly: true}, SessionMeta{Archived: true}, true},
+		{"pinned only", SessionFilter{PinnedOnly: true}, SessionMeta{}, false},
+		{"tags all required", SessionFilter{Tags: []string{"a", "b"}}, SessionMeta{Tags: []string{"a"}}, false},
+		{"tags ignore case", SessionFilter{Tags: []string{"BUG"}}, SessionMeta{Tags: []string{"bug"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(tt.meta); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListSessions_AppliesMeta(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []struct{ id, ts string }{
+		{"old", "2026-01-18T10:00:00Z"},
+		{"mid", "2026-01-19T10:00:00Z"},
+		{"new", "2026-01-20T10:00:00Z"},
+	} {
+		writeJSONLFile(t, filepath.Join(projectDir, s.id+".jsonl"), []map[string]interface{}{
+			{"type": "user", "timestamp": s.ts, "message": map[string]interface{}{"role": "user", "content": s.id}},
+		})
+	}
+
+	sl := NewSessionLister(tmpDir)
+	sl.RenameSession("old", "Pinned one")
+	sl.SetSessionPinned("old", true)
+	sl.SetSessionTags("old", []string{"keep"})
+	sl.SetSessionArchived("mid", true)
+
+	sessions, err := sl.ListSessions("/home/user/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("ListSessions() returned %d sessions, want 2", len(sessions))
+	}
+	if sessions[0].ID != "old" || !sessions[0].Pinned || sessions[0].Title != "Pinned one" {
+		t.Errorf("sessions[0] = %+v, want pinned session first", sessions[0])
+	}
+	if sessions[1].ID != "new" {
+		t.Errorf("sessions[1].ID = %q, want new", sessions[1].ID)
+	}
+
+	archived, err := sl.ListSessionsFiltered("/home/user/project", SessionFilter{ArchivedOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 1 || archived[0].ID != "mid" || !archived[0].Archived {
+		t.Errorf("ListSessionsFiltered(archived) = %+v, want mid", archived)
+	}
+
+	tagged, err := sl.ListSessionsFiltered("/home/user/project", SessionFilter{Tags: []string{"keep"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tagged) != 1 || tagged[0].ID != "old" {
+		t.Errorf("ListSessionsFiltered(tag) = %+v, want old", tagged)
+	}
+}
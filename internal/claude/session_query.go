@@ -0,0 +1,197 @@
+package claude
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session sort orders.
+const (
+	SortByLastActivity = "last_activity" // Last entry timestamp; the default
+	SortByCreated      = "created"       // First entry timestamp
+)
+
+// SessionQuery selects a page of a project's sessions. Zero fields do not
+// restrict the result.
+type SessionQuery struct {
+	Filter SessionFilter `json:"filter"`
+	Since  time.Time     `json:"since,omitzero"`  // Inclusive, compared against the sort time
+	Until  time.Time     `json:"until,omitzero"`  // Exclusive, compared against the sort time
+	Model  string        `json:"model,omitempty"` // Case-insensitive substring of the model ID, e.g. "opus"
+	Text   string        `json:"text,omitempty"`  // Case-insensitive substring of title, summary or first message
+	SortBy string        `json:"sort_by,omitempty"`
+	Limit  int           `json:"limit,omitempty"`  // 0 returns all matches
+	Cursor string        `json:"cursor,omitempty"` // NextCursor of the previous page
+}
+
+// SessionPage is one page of a session query. Pinned sessions come first;
+// within each group sessions are sorted newest first.
+type SessionPage struct {
+	Sessions   []SessionInfo `json:"sessions"`
+	Total      int           `json:"total"`                 // Matches across all pages
+	NextCursor string        `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// SessionGroup holds the sessions last active on one git branch.
+type SessionGroup struct {
+	Branch   string        `json:"branch"` // Empty for sessions without a recorded branch
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+// sessionCursor is the position after the last session of a page. Keying
+// on the sort order rather than an offset keeps pages stable while new
+// sessions are added at the top.
+type sessionCursor struct {
+	Pinned bool      `json:"p,omitempty"`
+	Time   time.Time `json:"t"`
+	ID     string    `json:"id"`
+}
+
+// QuerySessions returns the sessions of a project that match q, one page
+// at a time.
+func (sl *SessionLister) QuerySessions(projectPath string, q SessionQuery) (SessionPage, error) {
+	var after *sessionCursor
+	if q.Cursor != "" {
+		c, err := decodeSessionCursor(q.Cursor)
+		if err != nil {
+			return SessionPage{}, err
+		}
+		after = &c
+	}
+	switch q.SortBy {
+	case "", SortByCreated, SortByLastActivity:
+	default:
+		return SessionPage{}, fmt.Errorf("unknown sort order %q", q.SortBy)
+	}
+
+	listed, err := sl.scanSessions(projectPath)
+	if err != nil {
+		return SessionPage{}, err
+	}
+
+	sortTime := func(s listedSession) time.Time {
+		if q.SortBy == SortByCreated {
+			return s.info.Timestamp
+		}
+		return s.info.LastActivity
+	}
+	key := func(s listedSession) sessionCursor {
+		return sessionCursor{Pinned: s.info.Pinned, Time: sortTime(s), ID: s.info.ID}
+	}
+
+	var matches []listedSession
+	for _, s := range listed {
+		if q.matches(s.info, sortTime(s)) {
+			matches = append(matches, s)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return key(matches[i]).before(key(matches[j])) })
+
+	page := SessionPage{Sessions: []SessionInfo{}, Total: len(matches)}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matches), func(i int) bool { return after.before(key(matches[i])) })
+	}
+	end := len(matches)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+		page.NextCursor = encodeSessionCursor(key(matches[end-1]))
+	}
+
+	files := make(map[string]sessionFile)
+	for _, s := range matches[start:end] {
+		page.Sessions = append(page.Sessions, s.info)
+		files[s.info.ID] = s.file
+	}
+	if q.Filter.WithStats {
+		sl.attachStats(page.Sessions, files)
+		_ = sl.metaCache().save()
+	}
+	return page, nil
+}
+
+// ListSessionsByBranch returns the sessions ListSessionsFiltered would
+// return, grouped by git branch.
+func (sl *SessionLister) ListSessionsByBranch(projectPath string, filter SessionFilter) ([]SessionGroup, error) {
+	sessions, err := sl.ListSessionsFiltered(projectPath, filter)
+	if err != nil {
+		return nil, err
+	}
+	return GroupSessionsByBranch(sessions), nil
+}
+
+// GroupSessionsByBranch groups sessions by GitBranch, keeping their order
+// within each group. Groups appear in the order of their first session, so
+// the branch with the most recent activity comes first.
+func GroupSessionsByBranch(sessions []SessionInfo) []SessionGroup {
+	groups := []SessionGroup{}
+	index := make(map[string]int)
+	for _, s := range sessions {
+		i, ok := index[s.GitBranch]
+		if !ok {
+			i = len(groups)
+			index[s.GitBranch] = i
+			groups = append(groups, SessionGroup{Branch: s.GitBranch})
+		}
+		groups[i].Sessions = append(groups[i].Sessions, s)
+	}
+	return groups
+}
+
+// matches reports whether a session passes every condition of q.
+func (q SessionQuery) matches(info SessionInfo, sortTime time.Time) bool {
+	meta := SessionMeta{Title: info.Title, Pinned: info.Pinned, Tags: info.Tags, Archived: info.Archived}
+	if !q.Filter.matches(meta) {
+		return false
+	}
+	if !q.Since.IsZero() && sortTime.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !sortTime.Before(q.Until) {
+		return false
+	}
+	if q.Model != "" && !containsFold(info.Model, q.Model) {
+		return false
+	}
+	if q.Text != "" && !containsFold(info.Title, q.Text) && !containsFold(info.Summary, q.Text) && !containsFold(info.FirstMessage, q.Text) {
+		return false
+	}
+	return true
+}
+
+// before reports whether the session at c sorts before the one at o:
+// pinned first, then newest first, then by ID for a total order.
+func (c sessionCursor) before(o sessionCursor) bool {
+	if c.Pinned != o.Pinned {
+		return c.Pinned
+	}
+	if !c.Time.Equal(o.Time) {
+		return c.Time.After(o.Time)
+	}
+	return c.ID < o.ID
+}
+
+func encodeSessionCursor(c sessionCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSessionCursor(s string) (sessionCursor, error) {
+	var c sessionCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		return sessionCursor{}, fmt.Errorf("invalid cursor %q", s)
+	}
+	return c, nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
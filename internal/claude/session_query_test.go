@@ -0,0 +1,150 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeQuerySessions creates three sessions in /home/user/project. Session
+// a was continued after b, so their creation and activity orders differ.
+func writeQuerySessions(t *testing.T) (*SessionLister, string) {
+	t.Helper()
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sessions := []struct {
+		id, created, lastActivity, model, prompt string
+	}{
+		{"a", "2026-01-10T10:00:00Z", "2026-01-30T12:00:00Z", "claude-opus-4-5", "Fix the login bug"},
+		{"b", "2026-01-20T10:00:00Z", "2026-01-25T10:00:00Z", "claude-sonnet-4-5", "Add dark mode"},
+		{"c", "2026-01-30T10:00:00Z", "2026-01-31T10:00:00Z", "claude-sonnet-4-5", "Write release notes"},
+	}
+	for _, s := range sessions {
+		writeJSONLFile(t, filepath.Join(projectDir, s.id+".jsonl"), []map[string]interface{}{
+			{"type": "user", "timestamp": s.created, "message": map[string]interface{}{"role": "user", "content": s.prompt}},
+			{"type": "assistant", "timestamp": s.created, "message": map[string]interface{}{"role": "assistant", "model": s.model, "content": []interface{}{}}},
+			{"type": "user", "timestamp": s.lastActivity, "message": map[string]interface{}{"role": "user", "content": "Continue"}},
+		})
+	}
+	return NewSessionLister(base), projectDir
+}
+
+func sessionIDs(sessions []SessionInfo) string {
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestQuerySessions_Filters(t *testing.T) {
+	sl, _ := writeQuerySessions(t)
+	sl.RenameSession("b", "Theme work")
+
+	tests := []struct {
+		name string
+		q    SessionQuery
+		want string
+	}{
+		{"all by last activity", SessionQuery{}, "c,a,b"},
+		{"created order", SessionQuery{SortBy: SortByCreated}, "c,b,a"},
+		{"since", SessionQuery{Since: time.Date(2026, 1, 28, 0, 0, 0, 0, time.UTC)}, "c,a"},
+		{"created until exclusive", SessionQuery{SortBy: SortByCreated, Until: time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)}, "a"},
+		{"model", SessionQuery{Model: "SONNET"}, "c,b"},
+		{"first message", SessionQuery{Text: "login"}, "a"},
+		{"title", SessionQuery{Text: "theme"}, "b"},
+		{"no match", SessionQuery{Text: "nothing"}, ""},
+		{"created range", SessionQuery{SortBy: SortByCreated, Since: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)}, "c,b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := sl.QuerySessions("/home/user/project", tt.q)
+			if err != nil {
+				t.Fatalf("QuerySessions() error = %v", err)
+			}
+			if got := sessionIDs(page.Sessions); got != tt.want {
+				t.Errorf("sessions = %q, want %q", got, tt.want)
+			}
+			if page.Total != len(page.Sessions) || page.NextCursor != "" {
+				t.Errorf("Total = %d, NextCursor = %q; want single page", page.Total, page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestQuerySessions_Paging(t *testing.T) {
+	sl, projectDir := writeQuerySessions(t)
+	sl.SetSessionPinned("a", true)
+
+	first, err := sl.QuerySessions("/home/user/project", SessionQuery{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(first.Sessions); got != "a,c" || first.Total != 3 || first.NextCursor == "" {
+		t.Fatalf("first page = %q (total %d, cursor %q), want a,c with cursor", got, first.Total, first.NextCursor)
+	}
+
+	// A session added between pages lands above the cursor and does not
+	// shift the next page.
+	writeJSONLFile(t, filepath.Join(projectDir, "d.jsonl"), []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-02-01T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "New"}},
+	})
+	second, err := sl.QuerySessions("/home/user/project", SessionQuery{Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionIDs(second.Sessions); got != "b" || second.Total != 4 || second.NextCursor != "" {
+		t.Errorf("second page = %q (total %d, cursor %q), want b as last page", got, second.Total, second.NextCursor)
+	}
+}
+
+func TestQuerySessions_Errors(t *testing.T) {
+	sl, _ := writeQuerySessions(t)
+
+	if _, err := sl.QuerySessions("/home/user/project", SessionQuery{Cursor: "%%%"}); err == nil || !strings.Contains(err.Error(), "invalid cursor") {
+		t.Errorf("QuerySessions(bad cursor) error = %v, want invalid cursor", err)
+	}
+	if _, err := sl.QuerySessions("/home/user/project", SessionQuery{SortBy: "size"}); err == nil || !strings.Contains(err.Error(), "unknown sort order") {
+		t.Errorf("QuerySessions(bad sort) error = %v, want unknown sort order", err)
+	}
+}
+
+func TestQuerySessions_StatsForPageOnly(t *testing.T) {
+	sl, projectDir := writeQuerySessions(t)
+
+	page, err := sl.QuerySessions("/home/user/project", SessionQuery{Limit: 1, Filter: SessionFilter{WithStats: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Sessions) != 1 || page.Sessions[0].Stats == nil {
+		t.Fatalf("page = %+v, want one session with stats", page.Sessions)
+	}
+	path := filepath.Join(projectDir, "a.jsonl")
+	if entry, ok := sl.cache.lookup(path, statSize(t, path), statModTime(t, path)); !ok || entry.Stats != nil {
+		t.Error("stats computed for a session outside the page")
+	}
+}
+
+func TestGroupSessionsByBranch(t *testing.T) {
+	groups := GroupSessionsByBranch([]SessionInfo{
+		{ID: "a", GitBranch: "feature"},
+		{ID: "b", GitBranch: "main"},
+		{ID: "c"},
+		{ID: "d", GitBranch: "feature"},
+	})
+	var got []string
+	for _, g := range groups {
+		got = append(got, g.Branch+"="+sessionIDs(g.Sessions))
+	}
+	if want := "feature=a,d main=b =c"; strings.Join(got, " ") != want {
+		t.Errorf("groups = %q, want %q", strings.Join(got, " "), want)
+	}
+	if groups := GroupSessionsByBranch(nil); groups == nil || len(groups) != 0 {
+		t.Errorf("GroupSessionsByBranch(nil) = %#v, want empty slice", groups)
+	}
+}
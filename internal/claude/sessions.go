@@ -6,24 +6,66 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"sort"
+	"runtime"
 	"strings"
+	"sync"
 	"time"
 )
 
+// maxSessionLineSize is the largest JSONL line read from a session file.
+// Tool results embed whole files, so lines can far exceed bufio's 64 KiB default.
+const maxSessionLineSize = 16 * 1024 * 1024
+
 // SessionInfo holds metadata extracted from a session JSONL file.
 type SessionInfo struct {
-	ID           string    `json:"id"`
-	Summary      string    `json:"summary"`
-	FirstMessage string    `json:"first_message"`
-	Timestamp    time.Time `json:"timestamp"`
-	Model        string    `json:"model"`
+	ID           string        `json:"id"`
+	Summary      string        `json:"summary"`
+	FirstMessage string        `json:"first_message"`
+	Timestamp    time.Time     `json:"timestamp"`     // First entry
+	LastActivity time.Time     `json:"last_activity"` // Last entry; later than Timestamp for continued sessions
+	Model        string        `json:"model"`
+	Cwd          string        `json:"cwd,omitempty"`        // Working directory of the last entry
+	GitBranch    string        `json:"git_branch,omitempty"` // Branch checked out at the last entry
+	Worktree     string        `json:"worktree,omitempty"`   // Worktree the session runs in; empty for the project itself
+	CLIVersion   string        `json:"cli_version,omitempty"`
+	MessageCount int           `json:"message_count"` // User and assistant entries outside sidechains
+	Title        string        `json:"title,omitempty"`
+	Pinned       bool          `json:"pinned,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+	Archived     bool          `json:"archived,omitempty"`
+	Stats        *SessionStats `json:"stats,omitempty"` // Only set when requested, see SessionFilter.WithStats
 }
 
+// WorktreeFunc returns the other checkouts of a project, e.g. its git
+// worktrees. Their sessions are listed and loaded with the project's own.
+type WorktreeFunc func(projectPath string) []string
+
 // SessionLister discovers and parses Claude session files.
 type SessionLister struct {
 	BasePath    string          // Base path for .claude directory (defaults to ~/.claude via os.UserHomeDir)
+	CachePath   string                 // File persisting session metadata between runs; empty keeps it in memory only
+	Meta        *MetaStore             // User-managed titles, pins, tags and archive state; nil keeps them in memory only
+	TrashDir    string                 // Where deleted sessions are moved; defaults to <BasePath>/dogma-trash
+	Worktrees   WorktreeFunc           // Lists checkouts whose sessions belong to the project; nil for none
 	homeDirFunc func() (string, error) // For testing; defaults to os.UserHomeDir
+	workers     int                    // Parallel parses of uncached files; defaults to runtime.NumCPU
+
+	mu           sync.Mutex
+	cache        *metaCache
+	memMeta      *MetaStore                      // Used when Meta is nil
+	indexes      map[string]*sessionIndex        // Transcript indexes keyed by session file path
+	indexTick    uint64                          // Counts index uses to find the least recently used
+	projectDirs  map[string]projectDirLookup     // Results of cwd lookups, keyed by project path
+	worktreeDirs map[string][]worktreeSessionDir // Worktree session dirs as of the last scan, keyed by project path
+}
+
+// projectDirLookup is the outcome of searching the project directories for
+// a project's sessions. A miss has an empty dir and stays valid while the
+// projects root is unchanged, since a new project directory changes its
+// modification time.
+type projectDirLookup struct {
+	dir     string
+	rootMod time.Time
 }
 
 // NewSessionLister creates a SessionLister with the given base path.
@@ -33,47 +75,233 @@ func NewSessionLister(basePath string) *SessionLister {
 }
 
 // ListSessions discovers session JSONL files for the given project path
-// and returns parsed session metadata sorted by timestamp descending.
+// and returns parsed session metadata, pinned sessions first and otherwise
+// sorted by last activity descending. Archived sessions are left out.
+// Metadata of unchanged files is served from the cache; the rest is parsed
+// in parallel, reading only the head and tail of large files.
 func (sl *SessionLister) ListSessions(projectPath string) ([]SessionInfo, error) {
-	base, err := sl.resolveBasePath()
+	return sl.ListSessionsFiltered(projectPath, SessionFilter{})
+}
+
+// ListSessionsFiltered is ListSessions restricted to sessions whose
+// user-managed metadata matches filter.
+func (sl *SessionLister) ListSessionsFiltered(projectPath string, filter SessionFilter) ([]SessionInfo, error) {
+	page, err := sl.QuerySessions(projectPath, SessionQuery{Filter: filter})
 	if err != nil {
 		return nil, err
 	}
+	return page.Sessions, nil
+}
 
-	encoded := encodeProjectPath(projectPath)
-	dir := filepath.Join(base, "projects", encoded)
+// listedSession is a parsed session file with its user-managed metadata.
+type listedSession struct {
+	info SessionInfo
+	file sessionFile
+}
 
+// scanSessions returns every parseable session of a project and its
+// worktrees with user-managed metadata applied, in directory order.
+func (sl *SessionLister) scanSessions(projectPath string) ([]listedSession, error) {
+	dir, err := sl.projectSessionsDir(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := sl.scanSessionsDir(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range sl.refreshWorktreeSessionDirs(projectPath) {
+		found, err := sl.scanSessionsDir(wt.dir, wt.path)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, found...)
+	}
+
+	store := sl.metaStore()
+	for i := range sessions {
+		meta, err := store.Get(sessions[i].info.ID)
+		if err != nil {
+			return nil, err
+		}
+		applyMeta(&sessions[i].info, meta)
+	}
+	return sessions, nil
+}
+
+// scanSessionsDir returns the parseable sessions in one session directory.
+// worktree is set on each session found; it is empty for the project's own
+// directory.
+func (sl *SessionLister) scanSessionsDir(dir string, worktree string) ([]listedSession, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []SessionInfo{}, nil
+			return nil, nil
 		}
 		return nil, fmt.Errorf("read sessions dir: %w", err)
 	}
 
-	var sessions []SessionInfo
+	cache := sl.metaCache()
+	var sessions []listedSession
+	var misses []sessionFile
+	present := make(map[string]bool)
 	for _, entry := range entries {
 		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
 			continue
 		}
-		filePath := filepath.Join(dir, entry.Name())
-		info, err := parseSessionFile(filePath)
+		stat, err := entry.Info()
 		if err != nil {
+			continue
+		}
+		file := sessionFile{
+			path:    filepath.Join(dir, entry.Name()),
+			size:    stat.Size(),
+			modTime: stat.ModTime(),
+		}
+		present[file.path] = true
+		if cached, ok := cache.lookup(file.path, file.size, file.modTime); ok {
+			if !cached.Invalid {
+				sessions = append(sessions, listedSession{info: cached.Info, file: file})
+			}
+			continue
+		}
+		misses = append(misses, file)
+	}
+
+	for _, res := range parseSessionFiles(misses, sl.workers) {
+		if res.err != nil && os.IsNotExist(res.err) {
+			// Removed while listing
+			continue
+		}
+		cache.store(res.file.path, metaCacheEntry{
+			Size:    res.file.size,
+			ModTime: res.file.modTime,
+			Invalid: res.err != nil,
+			Info:    res.info,
+		})
+		if res.err != nil {
 			// Skip files that fail to parse
 			continue
 		}
-		sessions = append(sessions, info)
+		sessions = append(sessions, listedSession{info: res.info, file: res.file})
 	}
+	cache.prune(dir, present)
+	// The cache only saves work; listing succeeds even if it cannot be written.
+	_ = cache.save()
 
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].Timestamp.After(sessions[j].Timestamp)
-	})
+	for i := range sessions {
+		sessions[i].info.Worktree = worktree
+	}
+	return sessions, nil
+}
 
-	if sessions == nil {
-		sessions = []SessionInfo{}
+// worktreeSessionDir is the session directory of one of a project's
+// worktrees.
+type worktreeSessionDir struct {
+	path string // Worktree checkout
+	dir  string // Its session directory
+}
+
+// worktreeSessionDirs returns the session directories of a project's
+// worktrees as of the last scan, asking the Worktrees hook only if the
+// project has not been scanned yet.
+func (sl *SessionLister) worktreeSessionDirs(projectPath string) []worktreeSessionDir {
+	sl.mu.Lock()
+	dirs, ok := sl.worktreeDirs[projectPath]
+	sl.mu.Unlock()
+	if ok {
+		return dirs
 	}
+	return sl.refreshWorktreeSessionDirs(projectPath)
+}
 
-	return sessions, nil
+// refreshWorktreeSessionDirs asks the Worktrees hook for a project's
+// worktrees and caches their session directories. Worktrees are created
+// and run by the same CLI, so only the encoded directory name is tried.
+func (sl *SessionLister) refreshWorktreeSessionDirs(projectPath string) []worktreeSessionDir {
+	dirs := sl.listWorktreeSessionDirs(projectPath)
+	sl.mu.Lock()
+	if sl.worktreeDirs == nil {
+		sl.worktreeDirs = make(map[string][]worktreeSessionDir)
+	}
+	sl.worktreeDirs[projectPath] = dirs
+	sl.mu.Unlock()
+	return dirs
+}
+
+// listWorktreeSessionDirs returns the session directories of the worktrees
+// reported by the Worktrees hook.
+func (sl *SessionLister) listWorktreeSessionDirs(projectPath string) []worktreeSessionDir {
+	if sl.Worktrees == nil {
+		return nil
+	}
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return nil
+	}
+	var dirs []worktreeSessionDir
+	for _, wt := range sl.Worktrees(projectPath) {
+		if wt == "" || samePath(wt, projectPath) {
+			continue
+		}
+		dirs = append(dirs, worktreeSessionDir{
+			path: wt,
+			dir:  filepath.Join(base, "projects", encodeProjectPath(wt)),
+		})
+	}
+	return dirs
+}
+
+// SessionWorkDir returns the directory a session of projectPath runs in:
+// the worktree holding it, or projectPath itself. Resuming a session must
+// happen there, since the CLI looks sessions up by working directory.
+func (sl *SessionLister) SessionWorkDir(projectPath string, sessionID string) (string, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return "", err
+	}
+	for _, wt := range sl.worktreeSessionDirs(projectPath) {
+		if filepath.Dir(path) == wt.dir {
+			return wt.path, nil
+		}
+	}
+	return projectPath, nil
+}
+
+// SessionModTime returns when a session's JSONL file was last written.
+func (sl *SessionLister) SessionModTime(projectPath string, sessionID string) (time.Time, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return stat.ModTime(), nil
+}
+
+// metaCache returns the session metadata cache, creating it on first use.
+func (sl *SessionLister) metaCache() *metaCache {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.cache == nil || sl.cache.path != sl.CachePath {
+		sl.cache = &metaCache{path: sl.CachePath}
+	}
+	return sl.cache
+}
+
+// metaStore returns the user-managed metadata store.
+func (sl *SessionLister) metaStore() *MetaStore {
+	if sl.Meta != nil {
+		return sl.Meta
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if sl.memMeta == nil {
+		sl.memMeta = NewMetaStore("")
+	}
+	return sl.memMeta
 }
 
 func (sl *SessionLister) resolveBasePath() (string, error) {
@@ -91,25 +319,187 @@ func (sl *SessionLister) resolveBasePath() (string, error) {
 	return filepath.Join(home, ".claude"), nil
 }
 
+// ValidateSessionID returns an error unless sessionID can name a file: it
+// must not be empty, "." or "..", or contain a path separator.
+func ValidateSessionID(sessionID string) error {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) || sessionID == "." || sessionID == ".." {
+		return fmt.Errorf("invalid session id %q", sessionID)
+	}
+	return nil
+}
+
+// sessionFilePath returns the JSONL path of a session in the given project
+// or, if it is not there, in one of the project's worktrees.
+func (sl *SessionLister) sessionFilePath(projectPath string, sessionID string) (string, error) {
+	if err := ValidateSessionID(sessionID); err != nil {
+		return "", err
+	}
+	dir, err := sl.projectSessionsDir(projectPath)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, sessionID+".jsonl")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if found := findInWorktrees(sl.worktreeSessionDirs(projectPath), sessionID); found != "" {
+			return found, nil
+		}
+		// The session may run in a worktree created since the last scan
+		if found := findInWorktrees(sl.refreshWorktreeSessionDirs(projectPath), sessionID); found != "" {
+			return found, nil
+		}
+	}
+	return path, nil
+}
+
+// findInWorktrees returns the JSONL path of a session in one of the given
+// worktree session directories, or "" if none holds it.
+func findInWorktrees(dirs []worktreeSessionDir, sessionID string) string {
+	for _, wt := range dirs {
+		candidate := filepath.Join(wt.dir, sessionID+".jsonl")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// projectSessionsDir returns the directory holding the sessions of projectPath.
+// The CLI's encoded directory name is tried first. If it does not exist, the
+// project directories are searched for sessions whose cwd is projectPath,
+// which covers paths encoded by other CLI versions or platforms. Lookups,
+// including misses, are cached.
+func (sl *SessionLister) projectSessionsDir(projectPath string) (string, error) {
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return "", err
+	}
+	root := filepath.Join(base, "projects")
+	dir := filepath.Join(root, encodeProjectPath(projectPath))
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return dir, nil
+	}
+
+	var rootMod time.Time
+	if info, err := os.Stat(root); err == nil {
+		rootMod = info.ModTime()
+	}
+	sl.mu.Lock()
+	cached, ok := sl.projectDirs[projectPath]
+	sl.mu.Unlock()
+	if ok {
+		if cached.dir == "" && cached.rootMod.Equal(rootMod) {
+			return dir, nil
+		}
+		if cached.dir != "" {
+			if _, err := os.Stat(cached.dir); err == nil {
+				return cached.dir, nil
+			}
+		}
+	}
+
+	found := findProjectDirByCwd(root, projectPath)
+	sl.mu.Lock()
+	if sl.projectDirs == nil {
+		sl.projectDirs = make(map[string]projectDirLookup)
+	}
+	sl.projectDirs[projectPath] = projectDirLookup{dir: found, rootMod: rootMod}
+	sl.mu.Unlock()
+	if found == "" {
+		return dir, nil
+	}
+	return found, nil
+}
+
+// findProjectDirByCwd returns the project directory under root whose
+// sessions record projectPath as their cwd, or "" if there is none.
+func findProjectDirByCwd(root string, projectPath string) string {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if f.IsDir() || !strings.HasSuffix(f.Name(), ".jsonl") {
+				continue
+			}
+			if cwd := readSessionCwd(filepath.Join(dir, f.Name())); cwd != "" {
+				if samePath(cwd, projectPath) {
+					return dir
+				}
+				// One recorded cwd per project directory is enough to decide.
+				break
+			}
+		}
+	}
+	return ""
+}
+
+// samePath reports whether two paths refer to the same location, comparing
+// case-insensitively on Windows.
+func samePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
 // encodeProjectPath converts an absolute path to the directory name format
-// used by Claude CLI: replace all "/" with "-".
+// used by Claude CLI: every character other than an ASCII letter or digit
+// becomes "-". This covers "/", "\", ".", "_", spaces and the ":" of Windows
+// drive letters. The CLI works on UTF-16 code units, so characters outside
+// the Basic Multilingual Plane become two dashes.
 func encodeProjectPath(path string) string {
-	return strings.ReplaceAll(path, "/", "-")
+	var sb strings.Builder
+	sb.Grow(len(path))
+	for _, r := range path {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r > 0xFFFF:
+			sb.WriteString("--")
+		default:
+			sb.WriteByte('-')
+		}
+	}
+	return sb.String()
 }
 
 // sessionEntry represents a single line in a session JSONL file.
 type sessionEntry struct {
-	Type      string        `json:"type"`
-	Timestamp string        `json:"timestamp"`
-	Message   *entryMessage `json:"message,omitempty"`
-	Summary   string        `json:"summary,omitempty"`
+	Type              string          `json:"type"`
+	Subtype           string          `json:"subtype,omitempty"`
+	Timestamp         string          `json:"timestamp"`
+	UUID              string          `json:"uuid,omitempty"`
+	ParentUUID        string          `json:"parentUuid,omitempty"`
+	LogicalParentUUID string          `json:"logicalParentUuid,omitempty"` // Set on compaction boundaries
+	LeafUUID          string          `json:"leafUuid,omitempty"`          // Last entry a summary covers
+	IsMeta            bool            `json:"isMeta,omitempty"`
+	IsSidechain       bool            `json:"isSidechain,omitempty"`
+	Cwd               string          `json:"cwd,omitempty"`
+	GitBranch         string          `json:"gitBranch,omitempty"`
+	Version           string          `json:"version,omitempty"`
+	Message           *entryMessage   `json:"message,omitempty"`
+	Summary           string          `json:"summary,omitempty"`
+	Content           json.RawMessage `json:"content,omitempty"`
+	CostUSD           float64         `json:"costUSD,omitempty"`
 }
 
 // entryMessage holds the message content from a session entry.
 type entryMessage struct {
+	ID      string          `json:"id,omitempty"`
 	Role    string          `json:"role"`
 	Content json.RawMessage `json:"content"`
 	Model   string          `json:"model,omitempty"`
+	Usage   *Usage          `json:"usage,omitempty"`
 }
 
 // parseSessionFile reads a JSONL session file and extracts metadata.
@@ -120,64 +510,168 @@ func parseSessionFile(path string) (SessionInfo, error) {
 	}
 	defer f.Close()
 
-	info := SessionInfo{
-		ID: strings.TrimSuffix(filepath.Base(path), ".jsonl"),
-	}
-
-	var (
-		gotTimestamp    bool
-		gotFirstMsg    bool
-		gotModel       bool
-		lastSummary    string
-	)
+	b := newSessionInfoBuilder(path)
 
 	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
 	for scanner.Scan() {
-		line := scanner.Bytes()
-		if len(line) == 0 {
-			continue
-		}
+		b.addLine(scanner.Bytes())
+	}
 
-		var entry sessionEntry
-		if err := json.Unmarshal(line, &entry); err != nil {
-			continue
+	return b.finish()
+}
+
+// sessionInfoBuilder accumulates SessionInfo fields from entries in file order.
+type sessionInfoBuilder struct {
+	path         string
+	info         SessionInfo
+	gotTimestamp bool
+	gotFirstMsg  bool
+	gotModel     bool
+	lastSummary  string
+}
+
+func newSessionInfoBuilder(path string) *sessionInfoBuilder {
+	return &sessionInfoBuilder{
+		path: path,
+		info: SessionInfo{ID: strings.TrimSuffix(filepath.Base(path), ".jsonl")},
+	}
+}
+
+// addLine decodes one JSONL line and records it. Empty and invalid lines are ignored.
+func (b *sessionInfoBuilder) addLine(line []byte) {
+	if len(line) == 0 {
+		return
+	}
+	var entry sessionEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return
+	}
+	b.add(entry)
+}
+
+// add records a single entry.
+func (b *sessionInfoBuilder) add(entry sessionEntry) {
+	// First entry: extract timestamp
+	if !b.gotTimestamp && entry.Timestamp != "" {
+		if ts, err := time.Parse(time.RFC3339, entry.Timestamp); err == nil {
+			b.info.Timestamp = ts
+			b.gotTimestamp = true
 		}
+	}
+	b.observe(entry)
+
+	// Sidechains are subagent conversations recorded inline by older CLI
+	// versions; they are not part of the main conversation.
+	if entry.IsSidechain {
+		return
+	}
+	if (entry.Type == "user" || entry.Type == "assistant") && !entry.IsMeta {
+		b.info.MessageCount++
+	}
 
-		// First entry: extract timestamp
-		if !gotTimestamp && entry.Timestamp != "" {
-			if ts, err := time.Parse(time.RFC3339, entry.Timestamp); err == nil {
-				info.Timestamp = ts
-				gotTimestamp = true
+	switch entry.Type {
+	case "user":
+		if !b.gotFirstMsg {
+			if text := promptText(entry); text != "" {
+				b.info.FirstMessage = truncateRunes(text, maxPreviewLen)
+				b.gotFirstMsg = true
 			}
 		}
+	case "assistant":
+		if !b.gotModel && entry.Message != nil && entry.Message.Model != "" {
+			b.info.Model = entry.Message.Model
+			b.gotModel = true
+		}
+	case "summary":
+		b.lastSummary = entry.Summary
+	}
+}
 
-		switch entry.Type {
-		case "user":
-			if !gotFirstMsg && entry.Message != nil {
-				content := extractStringContent(entry.Message.Content)
-				if len(content) > 100 {
-					content = content[:100]
-				}
-				info.FirstMessage = content
-				gotFirstMsg = true
-			}
-		case "assistant":
-			if !gotModel && entry.Message != nil && entry.Message.Model != "" {
-				info.Model = entry.Message.Model
-				gotModel = true
-			}
-		case "summary":
-			lastSummary = entry.Summary
+// observe records the fields that track the latest state of the session.
+// The CLI writes them on every entry, so the last entry wins.
+func (b *sessionInfoBuilder) observe(entry sessionEntry) {
+	if entry.Timestamp != "" {
+		if ts, err := time.Parse(time.RFC3339, entry.Timestamp); err == nil {
+			b.info.LastActivity = ts
 		}
 	}
+	if entry.Cwd != "" {
+		b.info.Cwd = entry.Cwd
+	}
+	if entry.GitBranch != "" {
+		b.info.GitBranch = entry.GitBranch
+	}
+	if entry.Version != "" {
+		b.info.CLIVersion = entry.Version
+	}
+}
+
+// headComplete reports whether all fields taken from the first matching
+// entries are known, so only later summaries can still change the result.
+func (b *sessionInfoBuilder) headComplete() bool {
+	return b.gotTimestamp && b.gotFirstMsg && b.gotModel
+}
+
+// finish returns the collected metadata or an error if no timestamp was seen.
+func (b *sessionInfoBuilder) finish() (SessionInfo, error) {
+	b.info.Summary = b.lastSummary
+
+	if !b.gotTimestamp {
+		return SessionInfo{}, fmt.Errorf("no valid timestamp found in %s", b.path)
+	}
 
-	info.Summary = lastSummary
+	return b.info, nil
+}
+
+// maxPreviewLen is the maximum length of FirstMessage in runes.
+const maxPreviewLen = 100
+
+// previewText returns the text of user message content, which is either a
+// plain string or an array of blocks. Text blocks are joined by newlines;
+// tool results and images are left out.
+func previewText(raw json.RawMessage) string {
+	if s := extractStringContent(raw); s != "" {
+		return s
+	}
+	var parts []string
+	for _, block := range contentBlocks(raw) {
+		if block.Type == "text" && block.Text != "" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
 
-	if !gotTimestamp {
-		return SessionInfo{}, fmt.Errorf("no valid timestamp found in %s", path)
+// promptText returns the prompt a user typed in a user entry. Meta entries,
+// tool-result-only turns and slash command markup yield "".
+func promptText(entry sessionEntry) string {
+	if entry.Type != "user" || entry.IsMeta || entry.Message == nil {
+		return ""
+	}
+	text := strings.TrimSpace(previewText(entry.Message.Content))
+	if isCommandWrapper(text) {
+		return ""
 	}
+	return text
+}
+
+// isCommandWrapper reports whether a user message is markup the CLI writes
+// for slash commands and their local output rather than a typed prompt.
+func isCommandWrapper(text string) bool {
+	return strings.HasPrefix(text, "<command-") || strings.HasPrefix(text, "<local-command-")
+}
 
-	return info, nil
+// truncateRunes returns s cut to at most n runes, never splitting a
+// multi-byte character.
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
 }
 
 // extractStringContent attempts to parse content as a JSON string.
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestEncodeProjectPath(t *testing.T) {
@@ -30,6 +32,46 @@ func TestEncodeProjectPath(t *testing.T) {
 			path: "/home/user/projects/my-app",
 			want: "-home-user-projects-my-app",
 		},
+		{
+			name: "dot in directory name",
+			path: "/home/me/my.project",
+			want: "-home-me-my-project",
+		},
+		{
+			name: "hidden directory",
+			path: "/home/user/.config/nvim",
+			want: "-home-user--config-nvim",
+		},
+		{
+			name: "spaces and underscores",
+			path: "/Users/jane/My Projects/app_v2",
+			want: "-Users-jane-My-Projects-app-v2",
+		},
+		{
+			name: "windows drive and backslashes",
+			path: `C:\Users\me\src\dogma`,
+			want: "C--Users-me-src-dogma",
+		},
+		{
+			name: "windows path with dots",
+			path: `D:\work\api.v2`,
+			want: "D--work-api-v2",
+		},
+		{
+			name: "non-ASCII letters",
+			path: "/home/jürgen/projekte",
+			want: "-home-j-rgen-projekte",
+		},
+		{
+			name: "characters outside the BMP",
+			path: "/tmp/🚀app",
+			want: "-tmp---app",
+		},
+		{
+			name: "trailing slash",
+			path: "/srv/app/",
+			want: "-srv-app-",
+		},
 	}
 
 	for _, tt := range tests {
@@ -42,6 +84,188 @@ func TestEncodeProjectPath(t *testing.T) {
 	}
 }
 
+func TestProjectSessionsDir_FallsBackToCwdLookup(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Directory written by an older CLI that only replaced "/".
+	legacy := filepath.Join(tmpDir, "projects", "-home-me-my.project")
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(legacy, "old-session.jsonl"), []map[string]interface{}{
+		{"type": "user", "cwd": "/home/me/my.project", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "legacy"}},
+	})
+	// An unrelated project is not matched.
+	other := filepath.Join(tmpDir, "projects", "-home-me-other")
+	if err := os.MkdirAll(other, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(other, "x.jsonl"), []map[string]interface{}{
+		{"type": "user", "cwd": "/home/me/other", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "other"}},
+	})
+
+	sl := NewSessionLister(tmpDir)
+	sessions, err := sl.ListSessions("/home/me/my.project")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "old-session" {
+		t.Fatalf("expected the legacy session, got %+v", sessions)
+	}
+
+	// The lookup result is cached and used for session file paths too.
+	path, err := sl.sessionFilePath("/home/me/my.project", "old-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(legacy, "old-session.jsonl") {
+		t.Errorf("sessionFilePath() = %q", path)
+	}
+
+	// Unknown projects still resolve to the encoded (missing) directory.
+	sessions, err = sl.ListSessions("/home/me/unknown")
+	if err != nil || len(sessions) != 0 {
+		t.Errorf("expected no sessions for unknown project, got %v, %v", sessions, err)
+	}
+}
+
+func TestListSessions_IncludesWorktrees(t *testing.T) {
+	tmpDir := t.TempDir()
+	session := func(dir, id, text, ts string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(tmpDir, "projects", dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeJSONLFile(t, filepath.Join(tmpDir, "projects", dir, id+".jsonl"), []map[string]interface{}{
+			{"type": "user", "uuid": id + "-u", "timestamp": ts, "message": map[string]interface{}{"role": "user", "content": text}},
+		})
+	}
+	session("-home-me-project", "main-session", "In the checkout", "2026-01-20T10:00:00Z")
+	session("-home-me-wt-fix", "wt-session", "In the worktree", "2026-01-20T11:00:00Z")
+	session("-home-me-other", "other-session", "Unrelated", "2026-01-20T12:00:00Z")
+
+	sl := NewSessionLister(tmpDir)
+	sl.Worktrees = func(projectPath string) []string {
+		if projectPath != "/home/me/project" {
+			return nil
+		}
+		return []string{"/home/me/project", "/home/me/wt-fix"}
+	}
+
+	sessions, err := sl.ListSessions("/home/me/project")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "wt-session" || sessions[1].ID != "main-session" {
+		t.Fatalf("ListSessions() = %+v, want worktree and checkout sessions", sessions)
+	}
+	if sessions[0].Worktree != "/home/me/wt-fix" || sessions[1].Worktree != "" {
+		t.Errorf("Worktree = %q, %q", sessions[0].Worktree, sessions[1].Worktree)
+	}
+
+	// Worktree sessions load through the parent project.
+	tr, err := sl.LoadSession("/home/me/project", "wt-session")
+	if err != nil || len(tr.Events) != 1 {
+		t.Errorf("LoadSession(worktree session) = %+v, %v", tr, err)
+	}
+	for id, want := range map[string]string{"wt-session": "/home/me/wt-fix", "main-session": "/home/me/project", "missing": "/home/me/project"} {
+		if dir, err := sl.SessionWorkDir("/home/me/project", id); err != nil || dir != want {
+			t.Errorf("SessionWorkDir(%s) = %q, %v; want %q", id, dir, err, want)
+		}
+	}
+
+	stamp := time.Date(2026, 1, 20, 11, 30, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(tmpDir, "projects", "-home-me-wt-fix", "wt-session.jsonl"), stamp, stamp)
+	if got, err := sl.SessionModTime("/home/me/project", "wt-session"); err != nil || !got.Equal(stamp) {
+		t.Errorf("SessionModTime(wt-session) = %v, %v; want %v", got, err, stamp)
+	}
+	if _, err := sl.SessionModTime("/home/me/project", "missing"); err == nil {
+		t.Error("SessionModTime(missing) error = nil, want error")
+	}
+}
+
+func TestListSessions_CachesWorktreeList(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeSession := func(dir, id string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Join(tmpDir, "projects", dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeJSONLFile(t, filepath.Join(tmpDir, "projects", dir, id+".jsonl"), []map[string]interface{}{
+			{"type": "user", "uuid": id + "-u", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": id}},
+		})
+	}
+	writeSession("-home-me-project", "main-session")
+	writeSession("-home-me-wt-fix", "wt-session")
+
+	calls := 0
+	worktrees := []string{"/home/me/wt-fix"}
+	sl := NewSessionLister(tmpDir)
+	sl.Worktrees = func(string) []string {
+		calls++
+		return worktrees
+	}
+
+	if _, err := sl.ListSessions("/home/me/project"); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"wt-session", "main-session", "missing"} {
+		if _, err := sl.SessionWorkDir("/home/me/project", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only the unknown session asks again, in case its worktree is new.
+	if calls != 2 {
+		t.Errorf("Worktrees called %d times, want 2", calls)
+	}
+
+	// A worktree created after the scan is picked up on lookup.
+	writeSession("-home-me-wt-new", "new-session")
+	worktrees = []string{"/home/me/wt-fix", "/home/me/wt-new"}
+	if dir, err := sl.SessionWorkDir("/home/me/project", "new-session"); err != nil || dir != "/home/me/wt-new" {
+		t.Errorf("SessionWorkDir(new-session) = %q, %v", dir, err)
+	}
+	if dir, err := sl.SessionWorkDir("/home/me/project", "new-session"); err != nil || dir != "/home/me/wt-new" {
+		t.Errorf("SessionWorkDir(new-session) again = %q, %v", dir, err)
+	}
+	if calls != 3 {
+		t.Errorf("Worktrees called %d times, want 3", calls)
+	}
+}
+
+func TestProjectSessionsDir_CachesMisses(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "projects")
+	// Directory written by an older CLI, still without sessions.
+	legacy := filepath.Join(root, "-home-me-my.project")
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sl := NewSessionLister(tmpDir)
+	encoded := filepath.Join(root, encodeProjectPath("/home/me/my.project"))
+	if dir, err := sl.projectSessionsDir("/home/me/my.project"); err != nil || dir != encoded {
+		t.Fatalf("projectSessionsDir() = %q, %v", dir, err)
+	}
+
+	// Adding a session to an existing directory leaves the root unchanged,
+	// so the miss is served from the cache.
+	writeJSONLFile(t, filepath.Join(legacy, "old-session.jsonl"), []map[string]interface{}{
+		{"type": "user", "cwd": "/home/me/my.project", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "legacy"}},
+	})
+	if dir, _ := sl.projectSessionsDir("/home/me/my.project"); dir != encoded {
+		t.Errorf("projectSessionsDir() = %q, want cached miss %q", dir, encoded)
+	}
+
+	// A changed root triggers a rescan.
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(root, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if dir, err := sl.projectSessionsDir("/home/me/my.project"); err != nil || dir != legacy {
+		t.Errorf("projectSessionsDir() after root change = %q, %v; want %q", dir, err, legacy)
+	}
+}
+
 func TestParseSessionFile(t *testing.T) {
 	t.Run("extracts all metadata fields", func(t *testing.T) {
 		tmpDir := t.TempDir()
@@ -90,6 +314,37 @@ func TestParseSessionFile(t *testing.T) {
 		}
 	})
 
+	t.Run("tracks latest state and counts main-chain messages", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "s1.jsonl")
+		writeJSONLFile(t, filePath, []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-10T10:00:00Z", "cwd": "/p", "gitBranch": "main", "version": "1.0.90", "message": map[string]interface{}{"role": "user", "content": "Start"}},
+			{"type": "user", "isMeta": true, "timestamp": "2026-01-10T10:00:00Z", "cwd": "/p", "gitBranch": "main", "version": "1.0.90", "message": map[string]interface{}{"role": "user", "content": "caveat"}},
+			{"type": "user", "isSidechain": true, "timestamp": "2026-01-10T10:00:01Z", "message": map[string]interface{}{"role": "user", "content": "Subagent task"}},
+			{"type": "assistant", "isSidechain": true, "timestamp": "2026-01-10T10:00:02Z", "message": map[string]interface{}{"role": "assistant", "content": []interface{}{}, "model": "claude-haiku-4-5"}},
+			{"type": "assistant", "timestamp": "2026-01-10T10:00:03Z", "message": map[string]interface{}{"role": "assistant", "content": []interface{}{}, "model": "claude-sonnet-4-5"}},
+			{"type": "system", "timestamp": "2026-01-17T08:00:00Z", "cwd": "/p/sub", "gitBranch": "feature", "version": "2.0.1", "content": "Resumed"},
+			{"type": "user", "timestamp": "2026-01-17T08:00:01Z", "cwd": "/p/sub", "gitBranch": "feature", "version": "2.0.1", "message": map[string]interface{}{"role": "user", "content": "Continue"}},
+			{"type": "summary", "summary": "Work"},
+		})
+
+		info, err := parseSessionFile(filePath)
+		if err != nil {
+			t.Fatalf("parseSessionFile() error = %v", err)
+		}
+		if want := time.Date(2026, 1, 17, 8, 0, 1, 0, time.UTC); !info.LastActivity.Equal(want) {
+			t.Errorf("LastActivity = %v, want %v", info.LastActivity, want)
+		}
+		if info.Cwd != "/p/sub" || info.GitBranch != "feature" || info.CLIVersion != "2.0.1" {
+			t.Errorf("Cwd = %q, GitBranch = %q, CLIVersion = %q; want values of the last entry", info.Cwd, info.GitBranch, info.CLIVersion)
+		}
+		if info.MessageCount != 3 {
+			t.Errorf("MessageCount = %d, want 3 (meta and sidechain entries excluded)", info.MessageCount)
+		}
+		if info.FirstMessage != "Start" || info.Model != "claude-sonnet-4-5" {
+			t.Errorf("FirstMessage = %q, Model = %q; want main-chain values", info.FirstMessage, info.Model)
+		}
+	})
+
 	t.Run("handles missing summary", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		filePath := filepath.Join(tmpDir, "session1.jsonl")
@@ -170,7 +425,7 @@ func TestListSessions(t *testing.T) {
 		}
 	})
 
-	t.Run("sorts by timestamp descending", func(t *testing.T) {
+	t.Run("sorts by last activity descending", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
 		if err := os.MkdirAll(projectDir, 0755); err != nil {
@@ -511,26 +766,62 @@ func TestParseSessionFile_EdgeCases(t *testing.T) {
 		}
 	})
 
-	t.Run("handles user message with non-string content", func(t *testing.T) {
-		tmpDir := t.TempDir()
-		filePath := filepath.Join(tmpDir, "array-content.jsonl")
+	t.Run("reads text blocks of array content", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "array-content.jsonl")
+		writeJSONLFile(t, filePath, []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "data": "AAAA"}},
+				map[string]interface{}{"type": "text", "text": "What is in"},
+				map[string]interface{}{"type": "text", "text": "this screenshot?"},
+			}}},
+		})
 
-		// User message with array content (unusual but possible)
-		f, err := os.Create(filePath)
+		info, err := parseSessionFile(filePath)
 		if err != nil {
-			t.Fatal(err)
+			t.Fatalf("parseSessionFile() error = %v", err)
 		}
-		// Write with array content that won't unmarshal as string
-		f.Write([]byte(`{"type":"user","timestamp":"2026-01-20T10:00:00Z","message":{"role":"user","content":[{"type":"text","text":"hi"}]}}` + "\n"))
-		f.Close()
+		if want := "What is in\nthis screenshot?"; info.FirstMessage != want {
+			t.Errorf("FirstMessage = %q, want %q", info.FirstMessage, want)
+		}
+	})
+
+	t.Run("skips turns that are not typed prompts", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "wrapped.jsonl")
+		writeJSONLFile(t, filePath, []map[string]interface{}{
+			{"type": "user", "isMeta": true, "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Caveat: The messages below were generated by the user while running local commands."}},
+			{"type": "user", "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{"role": "user", "content": "<command-name>/clear</command-name>\n<command-message>clear</command-message>"}},
+			{"type": "user", "timestamp": "2026-01-20T10:00:02Z", "message": map[string]interface{}{"role": "user", "content": "<local-command-stdout></local-command-stdout>"}},
+			{"type": "user", "timestamp": "2026-01-20T10:00:03Z", "message": map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "tool_result", "tool_use_id": "t1", "content": "file contents"},
+			}}},
+			{"type": "user", "timestamp": "2026-01-20T10:00:04Z", "message": map[string]interface{}{"role": "user", "content": "  Refactor the parser  "}},
+		})
 
 		info, err := parseSessionFile(filePath)
 		if err != nil {
 			t.Fatalf("parseSessionFile() error = %v", err)
 		}
-		// extractStringContent returns empty string for non-string content
-		if info.FirstMessage != "" {
-			t.Errorf("FirstMessage = %q, want empty (non-string content)", info.FirstMessage)
+		if info.FirstMessage != "Refactor the parser" {
+			t.Errorf("FirstMessage = %q, want %q", info.FirstMessage, "Refactor the parser")
+		}
+	})
+
+	t.Run("truncates by runes", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "unicode.jsonl")
+		prompt := strings.Repeat("ä", 99) + "日本語"
+		writeJSONLFile(t, filePath, []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": prompt}},
+		})
+
+		info, err := parseSessionFile(filePath)
+		if err != nil {
+			t.Fatalf("parseSessionFile() error = %v", err)
+		}
+		if want := strings.Repeat("ä", 99) + "日"; info.FirstMessage != want {
+			t.Errorf("FirstMessage = %q, want %q", info.FirstMessage, want)
+		}
+		if !utf8.ValidString(info.FirstMessage) {
+			t.Error("FirstMessage is not valid UTF-8")
 		}
 	})
 }
@@ -553,3 +844,14 @@ func writeJSONLFile(t *testing.T, path string, lines []map[string]interface{}) {
 		f.Write([]byte("\n"))
 	}
 }
+
+func TestValidateSessionID(t *testing.T) {
+	for _, id := range []string{"", ".", "..", "../x", `a\b`, "a/b"} {
+		if err := ValidateSessionID(id); err == nil {
+			t.Errorf("ValidateSessionID(%q) error = nil, want error", id)
+		}
+	}
+	if err := ValidateSessionID("6f1c9a52-3d4e-4b8a-9c2d-1e5f7a8b9c0d"); err != nil {
+		t.Errorf("ValidateSessionID(uuid) error = %v", err)
+	}
+}
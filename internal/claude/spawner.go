@@ -88,13 +88,46 @@ func NewSpawner(config SpawnerConfig) *Spawner {
 // the handler for each NDJSON event line received on stdout.
 func (s *Spawner) SendPrompt(ctx context.Context, prompt string, handler EventHandler) error {
 	args := buildArgs(prompt, "")
-	return s.run(ctx, args, handler)
+	return s.run(ctx, args, "", handler)
 }
 
 // SendPromptWithSession is like SendPrompt but resumes an existing session.
 func (s *Spawner) SendPromptWithSession(ctx context.Context, prompt string, sessionID string, handler EventHandler) error {
 	args := buildArgs(prompt, sessionID)
-	return s.run(ctx, args, handler)
+	return s.run(ctx, args, "", handler)
+}
+
+// SendPromptInDir is like SendPromptWithSession but runs the CLI in dir
+// instead of the configured working directory, e.g. a session's worktree.
+// An empty sessionID starts a new session.
+func (s *Spawner) SendPromptInDir(ctx context.Context, prompt string, sessionID string, dir string, handler EventHandler) error {
+	args := buildArgs(prompt, sessionID)
+	return s.run(ctx, args, dir, handler)
+}
+
+// Version returns the version of the claude CLI, e.g. "2.0.14".
+func (s *Spawner) Version(ctx context.Context) (string, error) {
+	cmd := s.cmdFactory(ctx, s.config.ClaudePath, "--version")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("start claude: %w", err)
+	}
+	out, readErr := io.ReadAll(io.LimitReader(stdout, 4096))
+	if err := cmd.Wait(); err != nil {
+		return "", fmt.Errorf("claude --version: %w", err)
+	}
+	if readErr != nil {
+		return "", fmt.Errorf("claude --version: %w", readErr)
+	}
+	// The output reads like "2.0.14 (Claude Code)"
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", errors.New("claude --version printed nothing")
+	}
+	return fields[0], nil
 }
 
 // buildArgs constructs the CLI arguments for claude.
@@ -126,7 +159,7 @@ func (s *Spawner) Cancel() {
 	}
 }
 
-func (s *Spawner) run(ctx context.Context, args []string, handler EventHandler) error {
+func (s *Spawner) run(ctx context.Context, args []string, dir string, handler EventHandler) error {
 	s.mu.Lock()
 	if s.cmd != nil {
 		s.mu.Unlock()
@@ -137,12 +170,15 @@ func (s *Spawner) run(ctx context.Context, args []string, handler EventHandler)
 
 	// Debug: Log the full command being executed
 	log.Printf("[SPAWNER] Executing: %s %s", s.config.ClaudePath, strings.Join(args, " "))
-	log.Printf("[SPAWNER] WorkingDir: %s", s.config.WorkingDir)
+	if dir == "" {
+		dir = s.config.WorkingDir
+	}
+	log.Printf("[SPAWNER] WorkingDir: %s", dir)
 	log.Printf("[SPAWNER] DefaultModel: %q, DangerouslySkipPermissions: %v", DefaultModel, DangerouslySkipPermissions)
 
 	cmd := s.cmdFactory(ctx, s.config.ClaudePath, args...)
-	if s.config.WorkingDir != "" {
-		cmd.SetDir(s.config.WorkingDir)
+	if dir != "" {
+		cmd.SetDir(dir)
 	}
 	if s.config.ConfigDir != "" {
 		// Inherit current environment and add CLAUDE_CONFIG_DIR
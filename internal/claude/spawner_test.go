@@ -139,6 +139,52 @@ func TestSendPrompt_WithWorkingDir(t *testing.T) {
 	}
 }
 
+func TestVersion(t *testing.T) {
+	var gotArgs []string
+	mock := &mockCmd{stdout: io.NopCloser(bytes.NewBufferString("2.0.14 (Claude Code)\n"))}
+	s := &Spawner{
+		config: SpawnerConfig{ClaudePath: "claude"},
+		cmdFactory: func(ctx context.Context, name string, args ...string) Cmd {
+			gotArgs = args
+			return mock
+		},
+	}
+
+	version, err := s.Version(context.Background())
+	if err != nil || version != "2.0.14" {
+		t.Errorf("Version() = %q, %v; want 2.0.14", version, err)
+	}
+	if strings.Join(gotArgs, " ") != "--version" {
+		t.Errorf("args = %v", gotArgs)
+	}
+
+	mock = &mockCmd{stdout: io.NopCloser(bytes.NewBufferString("")), waitErr: errors.New("exit status 1")}
+	s.cmdFactory = newMockFactory(mock)
+	if _, err := s.Version(context.Background()); err == nil {
+		t.Error("Version() error = nil, want error")
+	}
+}
+
+func TestSendPromptInDir(t *testing.T) {
+	mock := &mockCmd{
+		stdout: io.NopCloser(bytes.NewBufferString("")),
+	}
+
+	s := &Spawner{
+		config:     SpawnerConfig{ClaudePath: "claude", WorkingDir: "/tmp/work"},
+		cmdFactory: newMockFactory(mock),
+	}
+
+	err := s.SendPromptInDir(context.Background(), "hello", "sess-1", "/tmp/worktree", func(ev StreamEvent) {})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mock.dir != "/tmp/worktree" {
+		t.Errorf("expected dir=/tmp/worktree, got %q", mock.dir)
+	}
+}
+
 func TestSendPrompt_NoWorkingDir(t *testing.T) {
 	mock := &mockCmd{
 		stdout: io.NopCloser(bytes.NewBufferString("")),
@@ -0,0 +1,350 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SessionStats summarizes the work done in a session. Messages leaves out
+// sidechains, like SessionInfo.MessageCount, but counts each response once
+// however many entries its content blocks are split across, and counts tool
+// results apart from prompts. Tool calls, files, usage and cost include the
+// work of sidechains.
+type SessionStats struct {
+	Messages      map[string]int `json:"messages"`   // By role: user, assistant, system and tool (results)
+	ToolCalls     map[string]int `json:"tool_calls"` // By tool name
+	FilesModified []string       `json:"files_modified"`
+	FilesRead     []string       `json:"files_read"`
+	Usage         Usage          `json:"usage"`
+	CostUSD       float64        `json:"cost_usd"`
+	CostSource    string         `json:"cost_source,omitempty"` // CostRecorded, CostEstimated or CostMixed; empty without cost
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	DurationMs    int64          `json:"duration_ms"` // Wall-clock span from the first to the last entry
+	Models        []ModelChange  `json:"models"`      // Each switch of the assistant model, in order
+}
+
+// Sources of SessionStats.CostUSD.
+const (
+	CostRecorded  = "recorded"  // The costUSD the CLI wrote for every response
+	CostEstimated = "estimated" // List prices applied to the token usage
+	CostMixed     = "mixed"     // Recorded where the CLI wrote a cost, estimated elsewhere
+)
+
+// ModelChange records the model used from a point in a session on.
+type ModelChange struct {
+	Model     string    `json:"model"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// modelPrice is the list price of a model in USD per million tokens.
+type modelPrice struct {
+	input, output, cacheWrite, cacheRead float64
+}
+
+// modelPrices maps model ID prefixes to prices, most specific first.
+var modelPrices = []struct {
+	prefix string
+	price  modelPrice
+}{
+	{"claude-opus-4-5", modelPrice{5, 25, 6.25, 0.5}},
+	{"claude-opus-4", modelPrice{15, 75, 18.75, 1.5}},
+	{"claude-3-opus", modelPrice{15, 75, 18.75, 1.5}},
+	{"claude-sonnet-4", modelPrice{3, 15, 3.75, 0.3}},
+	{"claude-3-7-sonnet", modelPrice{3, 15, 3.75, 0.3}},
+	{"claude-3-5-sonnet", modelPrice{3, 15, 3.75, 0.3}},
+	{"claude-haiku-4-5", modelPrice{1, 5, 1.25, 0.1}},
+	{"claude-3-5-haiku", modelPrice{0.8, 4, 1, 0.08}},
+}
+
+// syntheticModel marks assistant messages the CLI generates itself, e.g.
+// for interrupted turns. They are not model switches.
+const syntheticModel = "<synthetic>"
+
+// estimateCost prices usage at the list price of model. Unknown models cost 0.
+func estimateCost(model string, u Usage) float64 {
+	for _, p := range modelPrices {
+		if strings.HasPrefix(model, p.prefix) {
+			return (float64(u.InputTokens)*p.price.input +
+				float64(u.OutputTokens)*p.price.output +
+				float64(u.CacheCreationInputTokens)*p.price.cacheWrite +
+				float64(u.CacheReadInputTokens)*p.price.cacheRead) / 1e6
+		}
+	}
+	return 0
+}
+
+// editTools are the tools whose Files() are modified rather than read.
+var editTools = map[string]bool{"Write": true, "Edit": true, "MultiEdit": true, "NotebookEdit": true}
+
+// apiMessage is the usage of one model response. The CLI writes a separate
+// entry per content block of a response, each repeating its usage.
+type apiMessage struct {
+	model string
+	usage Usage
+	cost  float64
+}
+
+// statsBuilder accumulates SessionStats from entries in file order.
+type statsBuilder struct {
+	stats     SessionStats
+	seenMsgs  map[string]bool
+	order     []string
+	responses map[string]apiMessage
+	modified  map[string]bool
+	read      map[string]bool
+	lastModel string
+}
+
+func newStatsBuilder() *statsBuilder {
+	return &statsBuilder{
+		stats:     SessionStats{Messages: map[string]int{}, ToolCalls: map[string]int{}},
+		seenMsgs:  make(map[string]bool),
+		responses: make(map[string]apiMessage),
+		modified:  make(map[string]bool),
+		read:      make(map[string]bool),
+	}
+}
+
+// add records a single entry.
+func (b *statsBuilder) add(entry sessionEntry) {
+	if entry.IsMeta {
+		return
+	}
+	ts, tsErr := time.Parse(time.RFC3339, entry.Timestamp)
+	if tsErr == nil {
+		if b.stats.Start.IsZero() || ts.Before(b.stats.Start) {
+			b.stats.Start = ts
+		}
+		if ts.After(b.stats.End) {
+			b.stats.End = ts
+		}
+	}
+
+	switch entry.Type {
+	case "system":
+		if !entry.IsSidechain {
+			b.stats.Messages["system"]++
+		}
+	case "user":
+		if entry.Message == nil || entry.IsSidechain {
+			return
+		}
+		blocks := contentBlocks(entry.Message.Content)
+		results := 0
+		for _, block := range blocks {
+			if block.Type == "tool_result" {
+				results++
+			}
+		}
+		b.stats.Messages["tool"] += results
+		if results == 0 || results < len(blocks) {
+			b.stats.Messages["user"]++
+		}
+	case "assistant":
+		if entry.Message == nil {
+			return
+		}
+		b.addResponse(entry, ts)
+		for _, block := range contentBlocks(entry.Message.Content) {
+			if block.Type == "tool_use" {
+				b.addToolUse(block)
+			}
+		}
+	}
+}
+
+// addResponse counts an assistant response once and keeps its latest usage.
+func (b *statsBuilder) addResponse(entry sessionEntry, ts time.Time) {
+	msg := entry.Message
+	key := msg.ID
+	if key == "" {
+		key = fmt.Sprintf("entry-%d", len(b.order))
+	}
+	if !b.seenMsgs[key] {
+		b.seenMsgs[key] = true
+		b.order = append(b.order, key)
+		if !entry.IsSidechain {
+			b.stats.Messages["assistant"]++
+		}
+	}
+	resp := b.responses[key]
+	resp.model = msg.Model
+	if msg.Usage != nil {
+		resp.usage = *msg.Usage
+	}
+	if entry.CostUSD > 0 {
+		resp.cost = entry.CostUSD
+	}
+	b.responses[key] = resp
+
+	if msg.Model != "" && msg.Model != syntheticModel && msg.Model != b.lastModel {
+		b.stats.Models = append(b.stats.Models, ModelChange{Model: msg.Model, Timestamp: ts})
+		b.lastModel = msg.Model
+	}
+}
+
+func (b *statsBuilder) addToolUse(block ContentBlock) {
+	b.stats.ToolCalls[block.Name]++
+	input, err := DecodeToolInput(block.Name, block.Input)
+	if err != nil || input == nil {
+		return
+	}
+	target := b.read
+	if editTools[block.Name] {
+		target = b.modified
+	} else if block.Name != "Read" {
+		return
+	}
+	for _, f := range input.Files() {
+		target[f] = true
+	}
+}
+
+// finish totals usage and cost. The cost the CLI recorded for a response
+// is used where present; other responses are estimated from list prices.
+func (b *statsBuilder) finish() SessionStats {
+	s := b.stats
+	var recorded, estimated bool
+	for _, key := range b.order {
+		resp := b.responses[key]
+		s.Usage.InputTokens += resp.usage.InputTokens
+		s.Usage.OutputTokens += resp.usage.OutputTokens
+		s.Usage.CacheReadInputTokens += resp.usage.CacheReadInputTokens
+		s.Usage.CacheCreationInputTokens += resp.usage.CacheCreationInputTokens
+		if resp.cost > 0 {
+			s.CostUSD += resp.cost
+			recorded = true
+		} else if cost := estimateCost(resp.model, resp.usage); cost > 0 {
+			s.CostUSD += cost
+			estimated = true
+		}
+	}
+	switch {
+	case recorded && estimated:
+		s.CostSource = CostMixed
+	case recorded:
+		s.CostSource = CostRecorded
+	case estimated:
+		s.CostSource = CostEstimated
+	}
+	if !s.Start.IsZero() {
+		s.DurationMs = s.End.Sub(s.Start).Milliseconds()
+	}
+	s.FilesModified = sortedKeys(b.modified)
+	s.FilesRead = sortedKeys(b.read)
+	if s.Models == nil {
+		s.Models = []ModelChange{}
+	}
+	return s
+}
+
+// contentBlocks decodes message content given as a block array.
+// String content yields no blocks.
+func contentBlocks(raw json.RawMessage) []ContentBlock {
+	if len(raw) == 0 || raw[0] != '[' {
+		return nil
+	}
+	var blocks []ContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return nil
+	}
+	return blocks
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// SessionStats returns the statistics of a session in the given project.
+// Results are cached with the session metadata until the file changes.
+func (sl *SessionLister) SessionStats(projectPath string, sessionID string) (SessionStats, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return SessionStats{}, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return SessionStats{}, fmt.Errorf("stat session: %w", err)
+	}
+	_, stats, err := sl.sessionInfoWithStats(sessionFile{path: path, size: stat.Size(), modTime: stat.ModTime()})
+	return stats, err
+}
+
+// sessionInfoWithStats returns the metadata and statistics of a session
+// file, serving both from the cache while the file is unchanged.
+func (sl *SessionLister) sessionInfoWithStats(file sessionFile) (SessionInfo, SessionStats, error) {
+	cache := sl.metaCache()
+	if cached, ok := cache.lookup(file.path, file.size, file.modTime); ok && cached.Stats != nil {
+		if cached.Invalid {
+			return SessionInfo{}, SessionStats{}, fmt.Errorf("no valid timestamp found in %s", file.path)
+		}
+		return cached.Info, *cached.Stats, nil
+	}
+
+	info, stats, err := parseSessionFileWithStats(file.path)
+	if err != nil && os.IsNotExist(err) {
+		return SessionInfo{}, SessionStats{}, err
+	}
+	cache.store(file.path, metaCacheEntry{
+		Size:    file.size,
+		ModTime: file.modTime,
+		Invalid: err != nil,
+		Info:    info,
+		Stats:   &stats,
+	})
+	return info, stats, err
+}
+
+// parseSessionFileWithStats reads a whole session file once, extracting
+// both the listing metadata and the statistics.
+func parseSessionFileWithStats(path string) (SessionInfo, SessionStats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SessionInfo{}, SessionStats{}, err
+	}
+	defer f.Close()
+
+	info := newSessionInfoBuilder(path)
+	stats := newStatsBuilder()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var entry sessionEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		info.add(entry)
+		stats.add(entry)
+	}
+
+	si, err := info.finish()
+	return si, stats.finish(), err
+}
+
+// attachStats sets Stats on each session, computing missing statistics in parallel.
+func (sl *SessionLister) attachStats(sessions []SessionInfo, files map[string]sessionFile) {
+	parallel(len(sessions), sl.workers, func(i int) {
+		file, ok := files[sessions[i].ID]
+		if !ok {
+			return
+		}
+		if _, stats, err := sl.sessionInfoWithStats(file); err == nil {
+			sessions[i].Stats = &stats
+		}
+	})
+}
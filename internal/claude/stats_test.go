@@ -0,0 +1,199 @@
+package claude
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeStatsSession writes a session with two responses, a model switch,
+// tool calls and results, and split content blocks sharing a message ID.
+func writeStatsSession(t *testing.T, path string, withCost bool) {
+	t.Helper()
+	usage := map[string]interface{}{"input_tokens": 1000, "output_tokens": 100, "cache_read_input_tokens": 0, "cache_creation_input_tokens": 0}
+	first := map[string]interface{}{"type": "assistant", "timestamp": "2026-01-20T10:00:05Z", "message": map[string]interface{}{
+		"id": "msg_1", "role": "assistant", "model": "claude-sonnet-4-5-20250929", "usage": usage,
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": "Looking"}},
+	}}
+	firstTool := map[string]interface{}{"type": "assistant", "timestamp": "2026-01-20T10:00:06Z", "message": map[string]interface{}{
+		"id": "msg_1", "role": "assistant", "model": "claude-sonnet-4-5-20250929", "usage": usage,
+		"content": []interface{}{
+			map[string]interface{}{"type": "tool_use", "id": "t1", "name": "Read", "input": map[string]interface{}{"file_path": "/p/a.go"}},
+			map[string]interface{}{"type": "tool_use", "id": "t2", "name": "Edit", "input": map[string]interface{}{"file_path": "/p/b.go", "old_string": "x", "new_string": "y"}},
+		},
+	}}
+	second := map[string]interface{}{"type": "assistant", "timestamp": "2026-01-20T10:01:00Z", "message": map[string]interface{}{
+		"id": "msg_2", "role": "assistant", "model": "claude-opus-4-1-20250805", "usage": usage,
+		"content": []interface{}{map[string]interface{}{"type": "tool_use", "id": "t3", "name": "Edit", "input": map[string]interface{}{"file_path": "/p/b.go", "old_string": "y", "new_string": "z"}}},
+	}}
+	if withCost {
+		first["costUSD"] = 0.5
+		firstTool["costUSD"] = 0.5
+		second["costUSD"] = 0.25
+	}
+	writeJSONLFile(t, path, []map[string]interface{}{
+		{"type": "user", "isMeta": true, "timestamp": "2026-01-20T09:00:00Z", "message": map[string]interface{}{"role": "user", "content": "caveat"}},
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Fix it"}},
+		first,
+		firstTool,
+		{"type": "user", "timestamp": "2026-01-20T10:00:07Z", "message": map[string]interface{}{"role": "user", "content": []interface{}{
+			map[string]interface{}{"type": "tool_result", "tool_use_id": "t1", "content": "ok"},
+			map[string]interface{}{"type": "tool_result", "tool_use_id": "t2", "content": "ok"},
+		}}},
+		{"type": "system", "timestamp": "2026-01-20T10:00:30Z", "content": "Model switched"},
+		second,
+		{"type": "assistant", "timestamp": "2026-01-20T10:01:30Z", "message": map[string]interface{}{
+			"id": "msg_3", "role": "assistant", "model": "<synthetic>", "content": []interface{}{map[string]interface{}{"type": "text", "text": "Interrupted"}},
+		}},
+		{"type": "summary", "summary": "Fixed it"},
+	})
+}
+
+func TestParseSessionFileWithStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	writeStatsSession(t, path, false)
+
+	info, stats, err := parseSessionFileWithStats(path)
+	if err != nil {
+		t.Fatalf("parseSessionFileWithStats() error = %v", err)
+	}
+	want, _ := parseSessionFile(path)
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+
+	if want := map[string]int{"user": 1, "assistant": 3, "tool": 2, "system": 1}; !reflect.DeepEqual(stats.Messages, want) {
+		t.Errorf("Messages = %v, want %v", stats.Messages, want)
+	}
+	if want := map[string]int{"Read": 1, "Edit": 2}; !reflect.DeepEqual(stats.ToolCalls, want) {
+		t.Errorf("ToolCalls = %v, want %v", stats.ToolCalls, want)
+	}
+	if !reflect.DeepEqual(stats.FilesModified, []string{"/p/b.go"}) || !reflect.DeepEqual(stats.FilesRead, []string{"/p/a.go"}) {
+		t.Errorf("files = modified %v, read %v", stats.FilesModified, stats.FilesRead)
+	}
+	// msg_1 repeats its usage on two entries and counts once.
+	if stats.Usage.InputTokens != 2000 || stats.Usage.OutputTokens != 200 {
+		t.Errorf("Usage = %+v, want 2000 in / 200 out", stats.Usage)
+	}
+	// Sonnet: 1000*3 + 100*15; Opus 4.1: 1000*15 + 100*75 (per million tokens).
+	if wantCost := (4500.0 + 22500.0) / 1e6; math.Abs(stats.CostUSD-wantCost) > 1e-9 || stats.CostSource != CostEstimated {
+		t.Errorf("CostUSD = %v (%s), want %v estimated", stats.CostUSD, stats.CostSource, wantCost)
+	}
+	if stats.DurationMs != 90_000 {
+		t.Errorf("DurationMs = %d, want 90000 (meta entries excluded)", stats.DurationMs)
+	}
+	if len(stats.Models) != 2 || stats.Models[0].Model != "claude-sonnet-4-5-20250929" || stats.Models[1].Model != "claude-opus-4-1-20250805" {
+		t.Errorf("Models = %+v, want sonnet then opus without synthetic", stats.Models)
+	}
+	if !stats.Models[1].Timestamp.Equal(time.Date(2026, 1, 20, 10, 1, 0, 0, time.UTC)) {
+		t.Errorf("model switch at %v", stats.Models[1].Timestamp)
+	}
+}
+
+func TestParseSessionFileWithStats_RecordedCost(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	writeStatsSession(t, path, true)
+
+	_, stats, err := parseSessionFileWithStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CostUSD != 0.75 || stats.CostSource != CostRecorded {
+		t.Errorf("CostUSD = %v (%s), want recorded 0.75", stats.CostUSD, stats.CostSource)
+	}
+}
+
+func TestParseSessionFileWithStats_MixedCostAndSidechains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s1.jsonl")
+	usage := map[string]interface{}{"input_tokens": 1000, "output_tokens": 100}
+	writeJSONLFile(t, path, []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Fix it"}},
+		{"type": "assistant", "timestamp": "2026-01-20T10:00:01Z", "costUSD": 0.5, "message": map[string]interface{}{
+			"id": "msg_1", "role": "assistant", "model": "claude-sonnet-4-5", "usage": usage, "content": "Delegating",
+		}},
+		{"type": "user", "isSidechain": true, "timestamp": "2026-01-20T10:00:02Z", "message": map[string]interface{}{"role": "user", "content": "Subagent task"}},
+		{"type": "assistant", "isSidechain": true, "timestamp": "2026-01-20T10:00:03Z", "message": map[string]interface{}{
+			"id": "msg_2", "role": "assistant", "model": "claude-sonnet-4-5", "usage": usage, "content": "Subagent answer",
+		}},
+	})
+
+	info, stats, err := parseSessionFileWithStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sonnet estimate for msg_2: 1000*3 + 100*15 per million tokens
+	if want := 0.5 + 4500.0/1e6; math.Abs(stats.CostUSD-want) > 1e-9 || stats.CostSource != CostMixed {
+		t.Errorf("CostUSD = %v (%s), want %v mixed", stats.CostUSD, stats.CostSource, want)
+	}
+	if stats.Messages["user"]+stats.Messages["assistant"] != info.MessageCount {
+		t.Errorf("Messages = %v, want %d like MessageCount", stats.Messages, info.MessageCount)
+	}
+	if stats.Usage.InputTokens != 2000 {
+		t.Errorf("Usage = %+v, want sidechain usage included", stats.Usage)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	u := Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000, CacheReadInputTokens: 1_000_000, CacheCreationInputTokens: 1_000_000}
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"claude-opus-4-5-20251101", 5 + 25 + 6.25 + 0.5},
+		{"claude-opus-4-1-20250805", 15 + 75 + 18.75 + 1.5},
+		{"claude-sonnet-4-5-20250929", 3 + 15 + 3.75 + 0.3},
+		{"claude-haiku-4-5-20251001", 1 + 5 + 1.25 + 0.1},
+		{"unknown-model", 0},
+	}
+	for _, tt := range tests {
+		if got := estimateCost(tt.model, u); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("estimateCost(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestSessionStats_CachedAndListed(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(projectDir, "s1.jsonl")
+	writeStatsSession(t, path, false)
+	sl := NewSessionLister(tmpDir)
+
+	stats, err := sl.SessionStats("/home/user/project", "s1")
+	if err != nil {
+		t.Fatalf("SessionStats() error = %v", err)
+	}
+	if stats.ToolCalls["Edit"] != 2 {
+		t.Errorf("ToolCalls = %v", stats.ToolCalls)
+	}
+	entry, ok := sl.cache.lookup(path, statSize(t, path), statModTime(t, path))
+	if !ok || entry.Stats == nil {
+		t.Fatal("stats not cached")
+	}
+
+	plain, err := sl.ListSessions("/home/user/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != 1 || plain[0].Stats != nil {
+		t.Errorf("ListSessions() = %+v, want no stats by default", plain)
+	}
+
+	withStats, err := sl.ListSessionsFiltered("/home/user/project", SessionFilter{WithStats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(withStats) != 1 || withStats[0].Stats == nil || withStats[0].Stats.Messages["assistant"] != 3 {
+		t.Errorf("ListSessionsFiltered(WithStats) = %+v, want stats", withStats)
+	}
+
+	if _, err := sl.SessionStats("/home/user/project", "missing"); err == nil {
+		t.Error("SessionStats(missing) error = nil, want error")
+	}
+}
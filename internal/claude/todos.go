@@ -0,0 +1,130 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+)
+
+// TodoUpdate is emitted to the frontend whenever a session's todo list changes.
+type TodoUpdate struct {
+	SessionID string     `json:"session_id"`
+	RequestID string     `json:"request_id,omitempty"`
+	Todos     []TodoItem `json:"todos"`
+}
+
+// TodoTracker keeps the most recent TodoWrite state per session.
+// The zero value is ready to use and safe for concurrent access.
+type TodoTracker struct {
+	mu    sync.Mutex
+	todos map[string][]TodoItem
+}
+
+// Get returns a copy of the todo list for sessionID and whether one is known.
+func (t *TodoTracker) Get(sessionID string) ([]TodoItem, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	todos, ok := t.todos[sessionID]
+	return slices.Clone(todos), ok
+}
+
+// Set stores the todo list for sessionID and reports whether it differs
+// from the previously stored list.
+func (t *TodoTracker) Set(sessionID string, todos []TodoItem) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.todos == nil {
+		t.todos = make(map[string][]TodoItem)
+	}
+	prev, ok := t.todos[sessionID]
+	if ok && slices.Equal(prev, todos) {
+		return false
+	}
+	if todos == nil {
+		todos = []TodoItem{}
+	}
+	t.todos[sessionID] = slices.Clone(todos)
+	return true
+}
+
+// Delete forgets the todo list for sessionID.
+func (t *TodoTracker) Delete(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.todos, sessionID)
+}
+
+// TodosFromEvent extracts the todo list from the last TodoWrite tool_use block
+// of an assistant event. The boolean is false if the event has no such block.
+func TodosFromEvent(ev ParsedEvent) ([]TodoItem, bool) {
+	if ev.Assistant == nil {
+		return nil, false
+	}
+	return todosFromBlocks(ev.Assistant.Message.Content)
+}
+
+// todosFromBlocks returns the todos of the last decodable TodoWrite block.
+func todosFromBlocks(blocks []ContentBlock) ([]TodoItem, bool) {
+	var (
+		todos []TodoItem
+		found bool
+	)
+	for _, block := range blocks {
+		if block.Type != "tool_use" || block.Name != "TodoWrite" {
+			continue
+		}
+		input, err := DecodeToolInput(block.Name, block.Input)
+		if err != nil || input == nil {
+			continue
+		}
+		todos = input.(*TodoWriteInput).Todos
+		found = true
+	}
+	return todos, found
+}
+
+// LoadTodos returns the final todo list recorded in a session's JSONL file.
+// A session that never called TodoWrite yields an empty list.
+func (sl *SessionLister) LoadTodos(projectPath string, sessionID string) ([]TodoItem, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return readSessionTodos(path)
+}
+
+// readSessionTodos scans a session file for TodoWrite calls; the last one wins.
+func readSessionTodos(path string) ([]TodoItem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	defer f.Close()
+
+	todos := []TodoItem{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
+	for scanner.Scan() {
+		var entry sessionEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Type != "assistant" || entry.Message == nil {
+			continue
+		}
+		var blocks []ContentBlock
+		if err := json.Unmarshal(entry.Message.Content, &blocks); err != nil {
+			continue
+		}
+		if found, ok := todosFromBlocks(blocks); ok {
+			todos = found
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+	return todos, nil
+}
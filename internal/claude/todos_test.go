@@ -0,0 +1,173 @@
+package claude
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTodoTracker(t *testing.T) {
+	t.Run("zero value reports unknown session", func(t *testing.T) {
+		var tr TodoTracker
+		if _, ok := tr.Get("s1"); ok {
+			t.Error("expected unknown session")
+		}
+	})
+
+	t.Run("Set reports changes only", func(t *testing.T) {
+		var tr TodoTracker
+		todos := []TodoItem{{Content: "a", Status: "pending"}}
+
+		if !tr.Set("s1", todos) {
+			t.Error("first Set should report a change")
+		}
+		if tr.Set("s1", []TodoItem{{Content: "a", Status: "pending"}}) {
+			t.Error("identical Set should not report a change")
+		}
+		if !tr.Set("s1", []TodoItem{{Content: "a", Status: "completed"}}) {
+			t.Error("status change should report a change")
+		}
+
+		got, ok := tr.Get("s1")
+		if !ok || len(got) != 1 || got[0].Status != "completed" {
+			t.Errorf("Get() = %v, %v", got, ok)
+		}
+	})
+
+	t.Run("Get returns a copy", func(t *testing.T) {
+		var tr TodoTracker
+		tr.Set("s1", []TodoItem{{Content: "a"}})
+		got, _ := tr.Get("s1")
+		got[0].Content = "mutated"
+		again, _ := tr.Get("s1")
+		if again[0].Content != "a" {
+			t.Errorf("tracker state was mutated through Get result")
+		}
+	})
+
+	t.Run("Delete forgets the session", func(t *testing.T) {
+		var tr TodoTracker
+		tr.Delete("s1")
+		tr.Set("s1", []TodoItem{{Content: "a"}})
+		tr.Delete("s1")
+		if _, ok := tr.Get("s1"); ok {
+			t.Error("expected unknown session after Delete")
+		}
+	})
+
+	t.Run("nil list is stored as empty", func(t *testing.T) {
+		var tr TodoTracker
+		tr.Set("s1", nil)
+		got, ok := tr.Get("s1")
+		if !ok || got == nil {
+			t.Errorf("expected known, non-nil empty list, got %v, %v", got, ok)
+		}
+	})
+}
+
+func TestTodosFromEvent(t *testing.T) {
+	t.Run("extracts last TodoWrite block", func(t *testing.T) {
+		ev := ParsedEvent{
+			Type: "assistant",
+			Assistant: &AssistantEvent{Message: AssistantMessage{Content: []ContentBlock{
+				{Type: "tool_use", Name: "TodoWrite", Input: json.RawMessage(`{"todos":[{"content":"old","status":"pending"}]}`)},
+				{Type: "text", Text: "working"},
+				{Type: "tool_use", Name: "TodoWrite", Input: json.RawMessage(`{"todos":[{"content":"new","status":"in_progress","activeForm":"Doing new"}]}`)},
+			}}},
+		}
+
+		todos, ok := TodosFromEvent(ev)
+		if !ok {
+			t.Fatal("expected todos to be found")
+		}
+		if len(todos) != 1 || todos[0].Content != "new" || todos[0].ActiveForm != "Doing new" {
+			t.Errorf("unexpected todos: %+v", todos)
+		}
+	})
+
+	t.Run("ignores other tools", func(t *testing.T) {
+		ev := ParsedEvent{
+			Type: "assistant",
+			Assistant: &AssistantEvent{Message: AssistantMessage{Content: []ContentBlock{
+				{Type: "tool_use", Name: "Bash", Input: json.RawMessage(`{"command":"ls"}`)},
+			}}},
+		}
+		if _, ok := TodosFromEvent(ev); ok {
+			t.Error("expected no todos")
+		}
+	})
+
+	t.Run("ignores non-assistant events", func(t *testing.T) {
+		if _, ok := TodosFromEvent(ParsedEvent{Type: "system", System: &SystemEvent{}}); ok {
+			t.Error("expected no todos")
+		}
+	})
+}
+
+func TestLoadTodos(t *testing.T) {
+	t.Run("returns last TodoWrite state from session file", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeJSONLFile(t, filepath.Join(projectDir, "sess.jsonl"), []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "plan"}},
+			{"type": "assistant", "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "tool_use", "name": "TodoWrite", "input": map[string]interface{}{"todos": []interface{}{
+					map[string]interface{}{"content": "step 1", "status": "pending"},
+				}}},
+			}}},
+			{"type": "assistant", "timestamp": "2026-01-20T10:00:02Z", "message": map[string]interface{}{"role": "assistant", "content": []interface{}{
+				map[string]interface{}{"type": "tool_use", "name": "TodoWrite", "input": map[string]interface{}{"todos": []interface{}{
+					map[string]interface{}{"content": "step 1", "status": "completed"},
+					map[string]interface{}{"content": "step 2", "status": "in_progress"},
+				}}},
+			}}},
+		})
+
+		sl := NewSessionLister(tmpDir)
+		todos, err := sl.LoadTodos("/home/user/project", "sess")
+		if err != nil {
+			t.Fatalf("LoadTodos() error = %v", err)
+		}
+		if len(todos) != 2 || todos[0].Status != "completed" || todos[1].Content != "step 2" {
+			t.Errorf("unexpected todos: %+v", todos)
+		}
+	})
+
+	t.Run("returns empty list when no TodoWrite was called", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeJSONLFile(t, filepath.Join(projectDir, "sess.jsonl"), []map[string]interface{}{
+			{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "hi"}},
+		})
+
+		sl := NewSessionLister(tmpDir)
+		todos, err := sl.LoadTodos("/home/user/project", "sess")
+		if err != nil {
+			t.Fatalf("LoadTodos() error = %v", err)
+		}
+		if todos == nil || len(todos) != 0 {
+			t.Errorf("expected empty non-nil list, got %v", todos)
+		}
+	})
+
+	t.Run("returns error for missing session", func(t *testing.T) {
+		sl := NewSessionLister(t.TempDir())
+		if _, err := sl.LoadTodos("/home/user/project", "missing"); err == nil {
+			t.Error("expected error for missing session file")
+		}
+	})
+
+	t.Run("rejects session ids with path separators", func(t *testing.T) {
+		sl := NewSessionLister(t.TempDir())
+		if _, err := sl.LoadTodos("/home/user/project", "../escape"); err == nil {
+			t.Error("expected error for invalid session id")
+		}
+	})
+}
@@ -0,0 +1,343 @@
+package claude
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// maxSummaryLen bounds the length of tool summaries in runes.
+const maxSummaryLen = 120
+
+// ToolInput is implemented by the typed input structs of the built-in tools.
+type ToolInput interface {
+	// Summary returns a one-line, human-readable description of the call.
+	Summary() string
+	// Files returns the file paths the call reads or modifies.
+	Files() []string
+}
+
+// ToolDiffer is implemented by tool inputs whose effect can be shown as a unified diff.
+type ToolDiffer interface {
+	Diff() string
+}
+
+// ReadInput is the input of the Read tool.
+type ReadInput struct {
+	FilePath string `json:"file_path"`
+	Offset   int    `json:"offset,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// WriteInput is the input of the Write tool.
+type WriteInput struct {
+	FilePath string `json:"file_path"`
+	Content  string `json:"content"`
+}
+
+// EditInput is the input of the Edit tool.
+type EditInput struct {
+	FilePath   string `json:"file_path"`
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+// MultiEditInput is the input of the MultiEdit tool.
+type MultiEditInput struct {
+	FilePath string          `json:"file_path"`
+	Edits    []EditOperation `json:"edits"`
+}
+
+// EditOperation is a single replacement inside a MultiEdit call.
+type EditOperation struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all,omitempty"`
+}
+
+// BashInput is the input of the Bash tool.
+type BashInput struct {
+	Command         string `json:"command"`
+	Description     string `json:"description,omitempty"`
+	Timeout         int    `json:"timeout,omitempty"`
+	RunInBackground bool   `json:"run_in_background,omitempty"`
+}
+
+// GrepInput is the input of the Grep tool.
+type GrepInput struct {
+	Pattern    string `json:"pattern"`
+	Path       string `json:"path,omitempty"`
+	Glob       string `json:"glob,omitempty"`
+	Type       string `json:"type,omitempty"`
+	OutputMode string `json:"output_mode,omitempty"`
+}
+
+// GlobInput is the input of the Glob tool.
+type GlobInput struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path,omitempty"`
+}
+
+// WebFetchInput is the input of the WebFetch tool.
+type WebFetchInput struct {
+	URL    string `json:"url"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// WebSearchInput is the input of the WebSearch tool.
+type WebSearchInput struct {
+	Query          string   `json:"query"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	BlockedDomains []string `json:"blocked_domains,omitempty"`
+}
+
+// TaskInput is the input of the Task tool (sub-agent launch).
+type TaskInput struct {
+	Description  string `json:"description"`
+	Prompt       string `json:"prompt"`
+	SubagentType string `json:"subagent_type,omitempty"`
+}
+
+// TodoWriteInput is the input of the TodoWrite tool.
+type TodoWriteInput struct {
+	Todos []TodoItem `json:"todos"`
+}
+
+// TodoItem is a single entry of the agent's todo list.
+type TodoItem struct {
+	ID         string `json:"id,omitempty"`
+	Content    string `json:"content"`
+	Status     string `json:"status"`
+	ActiveForm string `json:"activeForm,omitempty"`
+}
+
+// NotebookEditInput is the input of the NotebookEdit tool.
+type NotebookEditInput struct {
+	NotebookPath string `json:"notebook_path"`
+	CellID       string `json:"cell_id,omitempty"`
+	NewSource    string `json:"new_source"`
+	CellType     string `json:"cell_type,omitempty"`
+	EditMode     string `json:"edit_mode,omitempty"`
+}
+
+// toolInputFactories maps built-in tool names to constructors of their input structs.
+var toolInputFactories = map[string]func() ToolInput{
+	"Read":         func() ToolInput { return &ReadInput{} },
+	"Write":        func() ToolInput { return &WriteInput{} },
+	"Edit":         func() ToolInput { return &EditInput{} },
+	"MultiEdit":    func() ToolInput { return &MultiEditInput{} },
+	"Bash":         func() ToolInput { return &BashInput{} },
+	"Grep":         func() ToolInput { return &GrepInput{} },
+	"Glob":         func() ToolInput { return &GlobInput{} },
+	"WebFetch":     func() ToolInput { return &WebFetchInput{} },
+	"WebSearch":    func() ToolInput { return &WebSearchInput{} },
+	"Task":         func() ToolInput { return &TaskInput{} },
+	"TodoWrite":    func() ToolInput { return &TodoWriteInput{} },
+	"NotebookEdit": func() ToolInput { return &NotebookEditInput{} },
+}
+
+// DecodeToolInput decodes the raw input of a tool_use block into the typed
+// struct for the named tool. Unknown tools and empty input return nil with
+// no error; invalid JSON for a known tool returns an error.
+func DecodeToolInput(name string, raw json.RawMessage) (ToolInput, error) {
+	factory, ok := toolInputFactories[name]
+	if !ok || len(raw) == 0 {
+		return nil, nil
+	}
+	input := factory()
+	if err := json.Unmarshal(raw, input); err != nil {
+		return nil, fmt.Errorf("decode %s input: %w", name, err)
+	}
+	return input, nil
+}
+
+// Summary implements ToolInput.
+func (in *ReadInput) Summary() string {
+	s := "Read " + in.FilePath
+	switch {
+	case in.Offset > 0 && in.Limit > 0:
+		s += fmt.Sprintf(" (lines %d-%d)", in.Offset, in.Offset+in.Limit-1)
+	case in.Offset > 0:
+		s += fmt.Sprintf(" (from line %d)", in.Offset)
+	case in.Limit > 0:
+		s += fmt.Sprintf(" (first %d lines)", in.Limit)
+	}
+	return s
+}
+
+// Files implements ToolInput.
+func (in *ReadInput) Files() []string { return nonEmpty(in.FilePath) }
+
+// Summary implements ToolInput.
+func (in *WriteInput) Summary() string {
+	return fmt.Sprintf("Write %s (%s)", in.FilePath, plural(len(splitLines(in.Content)), "line"))
+}
+
+// Files implements ToolInput.
+func (in *WriteInput) Files() []string { return nonEmpty(in.FilePath) }
+
+// Diff renders the written content as an addition. The previous file
+// contents are not part of the tool input, so the diff is against /dev/null.
+func (in *WriteInput) Diff() string {
+	return UnifiedDiff("", in.FilePath, "", in.Content)
+}
+
+// Summary implements ToolInput.
+func (in *EditInput) Summary() string {
+	s := "Edit " + in.FilePath
+	if in.ReplaceAll {
+		s += " (replace all)"
+	}
+	return s
+}
+
+// Files implements ToolInput.
+func (in *EditInput) Files() []string { return nonEmpty(in.FilePath) }
+
+// Diff renders the replacement as a unified diff. Line numbers are relative
+// to the replaced text because the tool input does not carry the whole file.
+func (in *EditInput) Diff() string {
+	return UnifiedDiff(in.FilePath, in.FilePath, in.OldString, in.NewString)
+}
+
+// Summary implements ToolInput.
+func (in *MultiEditInput) Summary() string {
+	return fmt.Sprintf("Edit %s (%s)", in.FilePath, plural(len(in.Edits), "edit"))
+}
+
+// Files implements ToolInput.
+func (in *MultiEditInput) Files() []string { return nonEmpty(in.FilePath) }
+
+// Diff renders every edit as its own unified diff with its own file header.
+// Line numbers of each diff are relative to that edit's replaced text, so
+// the hunks of different edits cannot share one header.
+func (in *MultiEditInput) Diff() string {
+	var b strings.Builder
+	for _, e := range in.Edits {
+		b.WriteString(UnifiedDiff(in.FilePath, in.FilePath, e.OldString, e.NewString))
+	}
+	return b.String()
+}
+
+// Summary implements ToolInput.
+func (in *BashInput) Summary() string {
+	if in.Description != "" {
+		return truncateSummary(in.Description)
+	}
+	return truncateSummary("$ " + in.Command)
+}
+
+// Files implements ToolInput. Shell commands are opaque, so no paths are reported.
+func (in *BashInput) Files() []string { return nil }
+
+// Summary implements ToolInput.
+func (in *GrepInput) Summary() string {
+	s := fmt.Sprintf("Search %q", in.Pattern)
+	if in.Path != "" {
+		s += " in " + in.Path
+	}
+	if in.Glob != "" {
+		s += " (" + in.Glob + ")"
+	}
+	return truncateSummary(s)
+}
+
+// Files implements ToolInput.
+func (in *GrepInput) Files() []string { return nonEmpty(in.Path) }
+
+// Summary implements ToolInput.
+func (in *GlobInput) Summary() string {
+	s := "Find " + in.Pattern
+	if in.Path != "" {
+		s += " in " + in.Path
+	}
+	return truncateSummary(s)
+}
+
+// Files implements ToolInput.
+func (in *GlobInput) Files() []string { return nonEmpty(in.Path) }
+
+// Summary implements ToolInput.
+func (in *WebFetchInput) Summary() string { return truncateSummary("Fetch " + in.URL) }
+
+// Files implements ToolInput.
+func (in *WebFetchInput) Files() []string { return nil }
+
+// Summary implements ToolInput.
+func (in *WebSearchInput) Summary() string {
+	return truncateSummary(fmt.Sprintf("Search the web for %q", in.Query))
+}
+
+// Files implements ToolInput.
+func (in *WebSearchInput) Files() []string { return nil }
+
+// Summary implements ToolInput.
+func (in *TaskInput) Summary() string {
+	agent := in.SubagentType
+	if agent == "" {
+		agent = "agent"
+	}
+	return truncateSummary(fmt.Sprintf("Task (%s): %s", agent, in.Description))
+}
+
+// Files implements ToolInput.
+func (in *TaskInput) Files() []string { return nil }
+
+// Summary implements ToolInput.
+func (in *TodoWriteInput) Summary() string {
+	done := 0
+	for _, t := range in.Todos {
+		if t.Status == "completed" {
+			done++
+		}
+	}
+	return fmt.Sprintf("Update todos (%d/%d completed)", done, len(in.Todos))
+}
+
+// Files implements ToolInput.
+func (in *TodoWriteInput) Files() []string { return nil }
+
+// Summary implements ToolInput.
+func (in *NotebookEditInput) Summary() string {
+	mode := in.EditMode
+	if mode == "" {
+		mode = "replace"
+	}
+	s := fmt.Sprintf("Edit notebook %s (%s", in.NotebookPath, mode)
+	if in.CellID != "" {
+		s += " cell " + in.CellID
+	}
+	return s + ")"
+}
+
+// Files implements ToolInput.
+func (in *NotebookEditInput) Files() []string { return nonEmpty(in.NotebookPath) }
+
+// truncateSummary reduces s to its first line and at most maxSummaryLen runes.
+func truncateSummary(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " ..."
+	}
+	runes := []rune(s)
+	if len(runes) > maxSummaryLen {
+		return string(runes[:maxSummaryLen-3]) + "..."
+	}
+	return s
+}
+
+// plural formats n with the given noun, adding an "s" unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// nonEmpty returns a single-element slice for s, or nil if s is empty.
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
@@ -0,0 +1,197 @@
+package claude
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeToolInput(t *testing.T) {
+	tests := []struct {
+		name        string
+		tool        string
+		input       string
+		wantSummary string
+		wantFiles   []string
+	}{
+		{
+			name:        "Read with range",
+			tool:        "Read",
+			input:       `{"file_path":"/src/main.go","offset":10,"limit":5}`,
+			wantSummary: "Read /src/main.go (lines 10-14)",
+			wantFiles:   []string{"/src/main.go"},
+		},
+		{
+			name:        "Write",
+			tool:        "Write",
+			input:       `{"file_path":"/src/new.go","content":"package main\n"}`,
+			wantSummary: "Write /src/new.go (1 line)",
+			wantFiles:   []string{"/src/new.go"},
+		},
+		{
+			name:        "Edit replace all",
+			tool:        "Edit",
+			input:       `{"file_path":"/src/a.go","old_string":"x","new_string":"y","replace_all":true}`,
+			wantSummary: "Edit /src/a.go (replace all)",
+			wantFiles:   []string{"/src/a.go"},
+		},
+		{
+			name:        "MultiEdit",
+			tool:        "MultiEdit",
+			input:       `{"file_path":"/src/a.go","edits":[{"old_string":"a","new_string":"b"},{"old_string":"c","new_string":"d"}]}`,
+			wantSummary: "Edit /src/a.go (2 edits)",
+			wantFiles:   []string{"/src/a.go"},
+		},
+		{
+			name:        "Bash with description",
+			tool:        "Bash",
+			input:       `{"command":"go test ./...","description":"Run tests"}`,
+			wantSummary: "Run tests",
+		},
+		{
+			name:        "Bash multi-line command",
+			tool:        "Bash",
+			input:       `{"command":"echo a\necho b"}`,
+			wantSummary: "$ echo a ...",
+		},
+		{
+			name:        "Grep",
+			tool:        "Grep",
+			input:       `{"pattern":"TODO","path":"/src","glob":"*.go"}`,
+			wantSummary: `Search "TODO" in /src (*.go)`,
+			wantFiles:   []string{"/src"},
+		},
+		{
+			name:        "Glob",
+			tool:        "Glob",
+			input:       `{"pattern":"**/*.ts"}`,
+			wantSummary: "Find **/*.ts",
+		},
+		{
+			name:        "WebFetch",
+			tool:        "WebFetch",
+			input:       `{"url":"https://example.com","prompt":"summarize"}`,
+			wantSummary: "Fetch https://example.com",
+		},
+		{
+			name:        "WebSearch",
+			tool:        "WebSearch",
+			input:       `{"query":"go generics"}`,
+			wantSummary: `Search the web for "go generics"`,
+		},
+		{
+			name:        "Task",
+			tool:        "Task",
+			input:       `{"description":"Explore repo","prompt":"...","subagent_type":"Explore"}`,
+			wantSummary: "Task (Explore): Explore repo",
+		},
+		{
+			name:        "TodoWrite",
+			tool:        "TodoWrite",
+			input:       `{"todos":[{"content":"a","status":"completed"},{"content":"b","status":"pending"}]}`,
+			wantSummary: "Update todos (1/2 completed)",
+		},
+		{
+			name:        "NotebookEdit",
+			tool:        "NotebookEdit",
+			input:       `{"notebook_path":"/nb.ipynb","cell_id":"c1","new_source":"x","edit_mode":"insert"}`,
+			wantSummary: "Edit notebook /nb.ipynb (insert cell c1)",
+			wantFiles:   []string{"/nb.ipynb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := DecodeToolInput(tt.tool, json.RawMessage(tt.input))
+			if err != nil {
+				t.Fatalf("DecodeToolInput() error = %v", err)
+			}
+			if input == nil {
+				t.Fatal("DecodeToolInput() returned nil")
+			}
+			if got := input.Summary(); got != tt.wantSummary {
+				t.Errorf("Summary() = %q, want %q", got, tt.wantSummary)
+			}
+			if got := input.Files(); !reflect.DeepEqual(got, tt.wantFiles) {
+				t.Errorf("Files() = %v, want %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestDecodeToolInput_UnknownTool(t *testing.T) {
+	input, err := DecodeToolInput("mcp__custom__tool", json.RawMessage(`{"a":1}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if input != nil {
+		t.Errorf("expected nil input for unknown tool, got %T", input)
+	}
+}
+
+func TestDecodeToolInput_EmptyInput(t *testing.T) {
+	input, err := DecodeToolInput("Read", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if input != nil {
+		t.Errorf("expected nil input for empty raw input, got %T", input)
+	}
+}
+
+func TestDecodeToolInput_InvalidJSON(t *testing.T) {
+	_, err := DecodeToolInput("Edit", json.RawMessage(`{"file_path":42}`))
+	if err == nil {
+		t.Fatal("expected error for mistyped input")
+	}
+}
+
+func TestToolDiffs(t *testing.T) {
+	t.Run("Write diffs against /dev/null", func(t *testing.T) {
+		in := &WriteInput{FilePath: "/src/a.txt", Content: "hello\n"}
+		want := "--- /dev/null\n+++ b/src/a.txt\n@@ -0,0 +1 @@\n+hello\n"
+		if got := in.Diff(); got != want {
+			t.Errorf("Diff() =\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Edit diffs old against new string", func(t *testing.T) {
+		in := &EditInput{FilePath: "/src/a.go", OldString: "x := 1", NewString: "x := 2"}
+		want := "--- a/src/a.go\n+++ b/src/a.go\n@@ -1 +1 @@\n-x := 1\n+x := 2\n"
+		if got := in.Diff(); got != want {
+			t.Errorf("Diff() =\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("MultiEdit emits one diff per edit", func(t *testing.T) {
+		in := &MultiEditInput{FilePath: "/src/a.go", Edits: []EditOperation{
+			{OldString: "a", NewString: "b"},
+			{OldString: "c", NewString: "c"},
+			{OldString: "c", NewString: "d"},
+		}}
+		want := "--- a/src/a.go\n+++ b/src/a.go\n@@ -1 +1 @@\n-a\n+b\n" +
+			"--- a/src/a.go\n+++ b/src/a.go\n@@ -1 +1 @@\n-c\n+d\n"
+		if got := in.Diff(); got != want {
+			t.Errorf("Diff() =\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("MultiEdit without changes", func(t *testing.T) {
+		in := &MultiEditInput{FilePath: "/src/a.go", Edits: []EditOperation{{OldString: "a", NewString: "a"}}}
+		if got := in.Diff(); got != "" {
+			t.Errorf("expected empty diff, got:\n%s", got)
+		}
+	})
+}
+
+func TestTruncateSummary(t *testing.T) {
+	long := strings.Repeat("ä", maxSummaryLen+10)
+	got := truncateSummary(long)
+	if n := len([]rune(got)); n != maxSummaryLen {
+		t.Errorf("expected %d runes, got %d", maxSummaryLen, n)
+	}
+	if !strings.HasSuffix(got, "...") {
+		t.Errorf("expected ellipsis suffix, got %q", got)
+	}
+}
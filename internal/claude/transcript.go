@@ -0,0 +1,151 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Transcript is the full history of a session, expressed in the same
+// BridgeEvent block model the live stream uses.
+type Transcript struct {
+	SessionID string        `json:"session_id"`
+	Summary   string        `json:"summary,omitempty"`
+	Events    []BridgeEvent `json:"events"`
+}
+
+// LoadSession parses an entire session JSONL file into a Transcript.
+func (sl *SessionLister) LoadSession(projectPath string, sessionID string) (Transcript, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return Transcript{}, err
+	}
+	return readTranscript(path, sessionID)
+}
+
+// readTranscript reads every entry of a session file in order.
+// Lines that are not valid JSON are skipped.
+func readTranscript(path string, sessionID string) (Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Transcript{}, fmt.Errorf("open session: %w", err)
+	}
+	defer f.Close()
+
+	tr := Transcript{SessionID: sessionID, Events: []BridgeEvent{}}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
+	for scanner.Scan() {
+		var entry sessionEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if entry.Type == "summary" {
+			tr.Summary = entry.Summary
+		}
+		tr.Events = append(tr.Events, entryToBridgeEvents(entry, sessionID)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return Transcript{}, fmt.Errorf("read session: %w", err)
+	}
+	return tr, nil
+}
+
+// entryToBridgeEvents converts one session file entry into bridge events.
+// Assistant and user messages yield one event per content block; tool results
+// become "tool_result" events. Meta entries and unknown types yield nothing.
+func entryToBridgeEvents(entry sessionEntry, sessionID string) []BridgeEvent {
+	if entry.IsMeta {
+		return nil
+	}
+	base := BridgeEvent{
+		Type:      entry.Type,
+		SessionID: sessionID,
+		UUID:      entry.UUID,
+		Timestamp: entry.Timestamp,
+	}
+
+	switch entry.Type {
+	case "summary":
+		base.Text = entry.Summary
+		return []BridgeEvent{base}
+
+	case "system":
+		base.Subtype = entry.Subtype
+		base.Text = extractStringContent(entry.Content)
+		return []BridgeEvent{base}
+
+	case "user", "assistant":
+		if entry.Message == nil {
+			return nil
+		}
+		if entry.Type == "assistant" {
+			base.Model = entry.Message.Model
+		}
+		if text := extractStringContent(entry.Message.Content); text != "" {
+			base.Text = text
+			return []BridgeEvent{base}
+		}
+		var blocks []ContentBlock
+		if err := json.Unmarshal(entry.Message.Content, &blocks); err != nil {
+			return nil
+		}
+		var out []BridgeEvent
+		for _, block := range blocks {
+			if be, ok := blockToBridgeEvent(base, block); ok {
+				out = append(out, be)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// blockToBridgeEvent fills a copy of base from a single content block.
+// Blocks without displayable content (e.g. images) are reported as not ok.
+func blockToBridgeEvent(base BridgeEvent, block ContentBlock) (BridgeEvent, bool) {
+	be := base
+	switch block.Type {
+	case "text":
+		if block.Text == "" {
+			return be, false
+		}
+		be.Text = block.Text
+	case "thinking":
+		be.Thinking = block.Thinking
+	case "tool_use":
+		be.ToolName = block.Name
+		be.ToolUseID = block.ID
+		be.ToolInput = block.Input
+		describeToolInput(&be)
+	case "tool_result":
+		be.Type = "tool_result"
+		be.ToolUseID = block.ToolUseID
+		be.Result = toolResultText(block.Content)
+		be.IsError = block.IsError
+	default:
+		return be, false
+	}
+	return be, true
+}
+
+// toolResultText flattens tool_result content, which is either a plain
+// string or an array of content blocks, into text.
+func toolResultText(raw json.RawMessage) string {
+	if s := extractStringContent(raw); s != "" {
+		return s
+	}
+	var blocks []ContentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,339 @@
+package claude
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Page size bounds for paginated transcript access.
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// maxCachedIndexes bounds the transcript indexes kept in memory; the least
+// recently used one is dropped first.
+const maxCachedIndexes = 64
+
+// MessagePage is a window of a session transcript. Offset and Total count
+// indexed messages (JSONL entries), not events: one message may expand to
+// several events, e.g. an assistant turn with thinking and a tool call.
+type MessagePage struct {
+	SessionID string        `json:"session_id"`
+	Offset    int           `json:"offset"`
+	Count     int           `json:"count"`
+	Total     int           `json:"total"`
+	Events    []BridgeEvent `json:"events"`
+}
+
+// sessionIndex records where each displayable entry of a session file starts.
+// Only byte offsets and UUIDs are kept, so memory does not grow with content size.
+type sessionIndex struct {
+	modTime  time.Time
+	fileSize int64 // file size when the index was last updated
+	size     int64 // bytes covered by the index (up to the last complete line)
+	offsets  []int64
+	lengths  []int
+	uuids    map[string]int
+	tail     []byte // Last bytes covered, to tell an appended file from a rewritten one
+	lastUsed uint64 // Value of SessionLister.indexTick when last returned
+}
+
+// indexTailSize is how many bytes before the end of an index are compared
+// before the index is extended.
+const indexTailSize = 256
+
+// indexEntry is the subset of a session entry needed to decide whether it is displayable.
+type indexEntry struct {
+	Type   string `json:"type"`
+	UUID   string `json:"uuid,omitempty"`
+	IsMeta bool   `json:"isMeta,omitempty"`
+}
+
+// isDisplayable reports whether an entry produces transcript events.
+func (e indexEntry) isDisplayable() bool {
+	if e.IsMeta {
+		return false
+	}
+	switch e.Type {
+	case "user", "assistant", "system", "summary":
+		return true
+	}
+	return false
+}
+
+// SessionMessages returns up to limit messages of a session starting at offset.
+func (sl *SessionLister) SessionMessages(projectPath string, sessionID string, offset int, limit int) (MessagePage, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	idx, err := sl.index(path)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	return readPage(path, sessionID, idx, offset, clampPageSize(limit))
+}
+
+// OlderMessages returns up to limit messages immediately before the message
+// at index before. A negative before means the end of the transcript, so
+// OlderMessages(..., -1, n) returns the latest n messages.
+func (sl *SessionLister) OlderMessages(projectPath string, sessionID string, before int, limit int) (MessagePage, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	idx, err := sl.index(path)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	limit = clampPageSize(limit)
+	total := len(idx.offsets)
+	if before < 0 || before > total {
+		before = total
+	}
+	offset := before - limit
+	if offset < 0 {
+		offset = 0
+	}
+	return readPage(path, sessionID, idx, offset, before-offset)
+}
+
+// MessagesAround returns a page of up to limit messages with the message
+// identified by uuid near its middle.
+func (sl *SessionLister) MessagesAround(projectPath string, sessionID string, uuid string, limit int) (MessagePage, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	idx, err := sl.index(path)
+	if err != nil {
+		return MessagePage{}, err
+	}
+	pos, ok := idx.uuids[uuid]
+	if !ok {
+		return MessagePage{}, fmt.Errorf("message %q not found in session %s", uuid, sessionID)
+	}
+	limit = clampPageSize(limit)
+	offset := pos - limit/2
+	if offset < 0 {
+		offset = 0
+	}
+	return readPage(path, sessionID, idx, offset, limit)
+}
+
+// index returns the up-to-date index for a session file. Cached indexes are
+// reused while the file's mtime and size are unchanged and extended in place
+// when the file has only grown, which is how the CLI appends to sessions.
+// At most maxCachedIndexes are kept, and the index of a removed file is
+// dropped.
+func (sl *SessionLister) index(path string) (*sessionIndex, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			sl.mu.Lock()
+			delete(sl.indexes, path)
+			sl.mu.Unlock()
+		}
+		return nil, fmt.Errorf("stat session: %w", err)
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	cached := sl.indexes[path]
+	if cached != nil && cached.modTime.Equal(stat.ModTime()) && cached.fileSize == stat.Size() {
+		sl.indexTick++
+		cached.lastUsed = sl.indexTick
+		return cached, nil
+	}
+
+	idx := &sessionIndex{uuids: make(map[string]int)}
+	if cached != nil && cached.fileSize < stat.Size() {
+		// Copy so pages being read from the previous index stay consistent.
+		idx.offsets = append([]int64(nil), cached.offsets...)
+		idx.lengths = append([]int(nil), cached.lengths...)
+		for k, v := range cached.uuids {
+			idx.uuids[k] = v
+		}
+		idx.size = cached.size
+		idx.tail = cached.tail
+	}
+	if err := extendIndex(path, idx); err != nil {
+		return nil, err
+	}
+	idx.modTime = stat.ModTime()
+	idx.fileSize = stat.Size()
+
+	if sl.indexes == nil {
+		sl.indexes = make(map[string]*sessionIndex)
+	}
+	sl.indexTick++
+	idx.lastUsed = sl.indexTick
+	sl.indexes[path] = idx
+	if len(sl.indexes) > maxCachedIndexes {
+		sl.evictIndex()
+	}
+	return idx, nil
+}
+
+// evictIndex drops the least recently used transcript index. sl.mu must be
+// held.
+func (sl *SessionLister) evictIndex() {
+	var oldest string
+	var oldestUse uint64
+	for path, idx := range sl.indexes {
+		if oldest == "" || idx.lastUsed < oldestUse {
+			oldest, oldestUse = path, idx.lastUsed
+		}
+	}
+	delete(sl.indexes, oldest)
+}
+
+// extendIndex scans the file from idx.size and appends every complete,
+// displayable line. A trailing line without newline is left for the next scan.
+// If the bytes before idx.size changed, the file was rewritten rather than
+// appended to, and the index is rebuilt from the start.
+func extendIndex(path string, idx *sessionIndex) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open session: %w", err)
+	}
+	defer f.Close()
+
+	if idx.size > 0 {
+		tail := make([]byte, len(idx.tail))
+		if _, err := f.ReadAt(tail, idx.size-int64(len(tail))); err != nil || !bytes.Equal(tail, idx.tail) {
+			*idx = sessionIndex{uuids: make(map[string]int)}
+		}
+	}
+
+	end, err := scanCompleteLines(f, idx.size, func(offset int64, line []byte) {
+		var entry indexEntry
+		if err := json.Unmarshal(line, &entry); err != nil || !entry.isDisplayable() {
+			return
+		}
+		if entry.UUID != "" {
+			idx.uuids[entry.UUID] = len(idx.offsets)
+		}
+		idx.offsets = append(idx.offsets, offset)
+		idx.lengths = append(idx.lengths, len(line))
+	})
+	if err != nil {
+		return fmt.Errorf("index session: %w", err)
+	}
+	idx.size = end
+	idx.tail = make([]byte, min(indexTailSize, end))
+	if _, err := f.ReadAt(idx.tail, end-int64(len(idx.tail))); err != nil {
+		return fmt.Errorf("index session: %w", err)
+	}
+	return nil
+}
+
+// scanCompleteLines calls fn for every non-empty, newline-terminated line of
+// f after byte offset start, passing each line's offset. A trailing line
+// without newline is still being written by the CLI and is not reported.
+// Lines longer than maxSessionLineSize are skipped. It returns the offset
+// just past the last complete line.
+func scanCompleteLines(f io.ReadSeeker, start int64, fn func(offset int64, line []byte)) (int64, error) {
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return start, err
+	}
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	pos := start // Offset of the current line
+	var n int64  // Bytes of the current line read so far
+	var line []byte
+	skip := false // The current line is too long
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		n += int64(len(chunk))
+		if !skip {
+			if len(line)+len(chunk) > maxSessionLineSize+1 {
+				skip, line = true, line[:0]
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		switch err {
+		case nil:
+		case bufio.ErrBufferFull:
+			continue
+		case io.EOF:
+			// Incomplete line: left for the next scan
+			return pos, nil
+		default:
+			return start, err
+		}
+		if content := line[:max(len(line)-1, 0)]; !skip && len(bytes.TrimSpace(content)) > 0 {
+			fn(pos, content)
+		}
+		pos += n
+		n, line, skip = 0, line[:0], false
+	}
+}
+
+// readPage reads the indexed messages [offset, offset+count) from the file.
+func readPage(path string, sessionID string, idx *sessionIndex, offset int, count int) (MessagePage, error) {
+	total := len(idx.offsets)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := offset + count
+	if end > total {
+		end = total
+	}
+
+	page := MessagePage{
+		SessionID: sessionID,
+		Offset:    offset,
+		Count:     end - offset,
+		Total:     total,
+		Events:    []BridgeEvent{},
+	}
+	if page.Count == 0 {
+		return page, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return MessagePage{}, fmt.Errorf("open session: %w", err)
+	}
+	defer f.Close()
+
+	var buf []byte
+	for i := offset; i < end; i++ {
+		if cap(buf) < idx.lengths[i] {
+			buf = make([]byte, idx.lengths[i])
+		}
+		buf = buf[:idx.lengths[i]]
+		if _, err := f.ReadAt(buf, idx.offsets[i]); err != nil {
+			return MessagePage{}, fmt.Errorf("read message %d: %w", i, err)
+		}
+		var entry sessionEntry
+		if err := json.Unmarshal(buf, &entry); err != nil {
+			continue
+		}
+		page.Events = append(page.Events, entryToBridgeEvents(entry, sessionID)...)
+	}
+	return page, nil
+}
+
+// clampPageSize applies the default and maximum page sizes.
+func clampPageSize(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
+	}
+	if limit > maxPageSize {
+		return maxPageSize
+	}
+	return limit
+}
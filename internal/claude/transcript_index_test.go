@@ -0,0 +1,304 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeNumberedSession writes n user messages with uuids "u0".."u{n-1}" plus
+// a non-displayable meta entry at the start.
+func writeNumberedSession(t *testing.T, base string, n int) string {
+	t.Helper()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	lines := []map[string]interface{}{
+		{"type": "user", "isMeta": true, "uuid": "meta", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "caveat"}},
+	}
+	for i := 0; i < n; i++ {
+		lines = append(lines, map[string]interface{}{
+			"type":      "user",
+			"uuid":      fmt.Sprintf("u%d", i),
+			"timestamp": "2026-01-20T10:00:00Z",
+			"message":   map[string]interface{}{"role": "user", "content": fmt.Sprintf("message %d", i)},
+		})
+	}
+	path := filepath.Join(projectDir, "sess.jsonl")
+	writeJSONLFile(t, path, lines)
+	return path
+}
+
+func TestSessionMessages(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeNumberedSession(t, tmpDir, 10)
+	sl := NewSessionLister(tmpDir)
+
+	t.Run("returns requested window", func(t *testing.T) {
+		page, err := sl.SessionMessages("/home/user/project", "sess", 2, 3)
+		if err != nil {
+			t.Fatalf("SessionMessages() error = %v", err)
+		}
+		if page.Total != 10 || page.Offset != 2 || page.Count != 3 {
+			t.Errorf("unexpected page bounds: %+v", page)
+		}
+		if len(page.Events) != 3 || page.Events[0].Text != "message 2" || page.Events[2].Text != "message 4" {
+			t.Errorf("unexpected events: %+v", page.Events)
+		}
+		if page.Events[0].UUID != "u2" {
+			t.Errorf("UUID = %q, want u2", page.Events[0].UUID)
+		}
+	})
+
+	t.Run("clamps past the end", func(t *testing.T) {
+		page, err := sl.SessionMessages("/home/user/project", "sess", 8, 50)
+		if err != nil {
+			t.Fatalf("SessionMessages() error = %v", err)
+		}
+		if page.Count != 2 || len(page.Events) != 2 {
+			t.Errorf("unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("offset beyond total yields empty page", func(t *testing.T) {
+		page, err := sl.SessionMessages("/home/user/project", "sess", 100, 5)
+		if err != nil {
+			t.Fatalf("SessionMessages() error = %v", err)
+		}
+		if page.Count != 0 || page.Events == nil || len(page.Events) != 0 {
+			t.Errorf("unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("missing session returns error", func(t *testing.T) {
+		if _, err := sl.SessionMessages("/home/user/project", "nope", 0, 5); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestOlderMessages(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeNumberedSession(t, tmpDir, 10)
+	sl := NewSessionLister(tmpDir)
+
+	t.Run("negative before loads the latest messages", func(t *testing.T) {
+		page, err := sl.OlderMessages("/home/user/project", "sess", -1, 4)
+		if err != nil {
+			t.Fatalf("OlderMessages() error = %v", err)
+		}
+		if page.Offset != 6 || page.Count != 4 || page.Events[3].Text != "message 9" {
+			t.Errorf("unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("stops at the start", func(t *testing.T) {
+		page, err := sl.OlderMessages("/home/user/project", "sess", 2, 4)
+		if err != nil {
+			t.Fatalf("OlderMessages() error = %v", err)
+		}
+		if page.Offset != 0 || page.Count != 2 {
+			t.Errorf("unexpected page: %+v", page)
+		}
+	})
+
+	t.Run("nothing before the first message", func(t *testing.T) {
+		page, err := sl.OlderMessages("/home/user/project", "sess", 0, 4)
+		if err != nil {
+			t.Fatalf("OlderMessages() error = %v", err)
+		}
+		if page.Count != 0 {
+			t.Errorf("unexpected page: %+v", page)
+		}
+	})
+}
+
+func TestMessagesAround(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeNumberedSession(t, tmpDir, 20)
+	sl := NewSessionLister(tmpDir)
+
+	page, err := sl.MessagesAround("/home/user/project", "sess", "u10", 6)
+	if err != nil {
+		t.Fatalf("MessagesAround() error = %v", err)
+	}
+	if page.Offset != 7 || page.Count != 6 {
+		t.Errorf("unexpected page bounds: %+v", page)
+	}
+
+	if _, err := sl.MessagesAround("/home/user/project", "sess", "meta", 6); err == nil {
+		t.Error("expected error for non-displayable message")
+	}
+	if _, err := sl.MessagesAround("/home/user/project", "sess", "unknown", 6); err == nil {
+		t.Error("expected error for unknown message")
+	}
+}
+
+func TestSessionIndex_Cache(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := writeNumberedSession(t, tmpDir, 3)
+	sl := NewSessionLister(tmpDir)
+
+	first, err := sl.index(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := sl.index(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != again {
+		t.Error("expected cached index to be reused for unchanged file")
+	}
+
+	t.Run("extends index when the file grows", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// A complete line and a partial line still being written.
+		f.WriteString(`{"type":"assistant","uuid":"a1","message":{"role":"assistant","content":[{"type":"text","text":"reply"}]}}` + "\n")
+		f.WriteString(`{"type":"user","uuid":"par`)
+		f.Close()
+		future := time.Now().Add(time.Second)
+		os.Chtimes(path, future, future)
+
+		idx, err := sl.index(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(idx.offsets) != 4 {
+			t.Fatalf("expected 4 indexed messages, got %d", len(idx.offsets))
+		}
+		if len(first.offsets) != 3 {
+			t.Errorf("previous index was mutated: %d offsets", len(first.offsets))
+		}
+
+		page, err := sl.SessionMessages("/home/user/project", "sess", 3, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Events) != 1 || page.Events[0].Text != "reply" {
+			t.Errorf("unexpected events: %+v", page.Events)
+		}
+
+		// Completing the partial line makes it visible.
+		f, _ = os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		f.WriteString(`tial","message":{"role":"user","content":"done"}}` + "\n")
+		f.Close()
+		later := future.Add(time.Second)
+		os.Chtimes(path, later, later)
+
+		page, err = sl.SessionMessages("/home/user/project", "sess", 4, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 || len(page.Events) != 1 || page.Events[0].UUID != "partial" {
+			t.Errorf("unexpected page after completion: %+v", page)
+		}
+	})
+}
+
+func TestSessionIndex_RebuildsRewrittenFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := writeNumberedSession(t, tmpDir, 3)
+	sl := NewSessionLister(tmpDir)
+	if _, err := sl.index(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewritten with different, longer content: the old offsets are stale
+	var lines []map[string]interface{}
+	for i := 0; i < 8; i++ {
+		lines = append(lines, map[string]interface{}{"type": "user", "uuid": fmt.Sprintf("r%d", i), "message": map[string]interface{}{"role": "user", "content": fmt.Sprintf("rewritten message %d", i)}})
+	}
+	writeJSONLFile(t, path, lines)
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+
+	idx, err := sl.index(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, stale := idx.uuids["u0"]; stale || len(idx.offsets) != 8 || idx.uuids["r7"] != 7 {
+		t.Errorf("index after rewrite has %d messages, uuids %v", len(idx.offsets), idx.uuids)
+	}
+}
+
+func TestSessionIndex_SkipsOversizedLines(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := writeNumberedSession(t, tmpDir, 2)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type":"user","uuid":"huge","message":{"role":"user","content":"` + strings.Repeat("x", maxSessionLineSize) + `"}}` + "\n")
+	f.WriteString(`{"type":"user","uuid":"after","message":{"role":"user","content":"after"}}` + "\n")
+	f.Close()
+
+	sl := NewSessionLister(tmpDir)
+	idx, err := sl.index(path)
+	if err != nil {
+		t.Fatalf("index() error = %v", err)
+	}
+	if _, ok := idx.uuids["huge"]; ok || len(idx.offsets) != 3 || idx.uuids["after"] != 2 {
+		t.Errorf("index = %d messages, uuids %v; want the oversized line skipped", len(idx.offsets), idx.uuids)
+	}
+}
+
+func TestSessionIndex_Eviction(t *testing.T) {
+	tmpDir := t.TempDir()
+	sl := NewSessionLister(tmpDir)
+	var paths []string
+	for i := 0; i <= maxCachedIndexes; i++ {
+		path := filepath.Join(tmpDir, fmt.Sprintf("s%d.jsonl", i))
+		writeJSONLFile(t, path, []map[string]interface{}{
+			{"type": "user", "uuid": "u", "message": map[string]interface{}{"role": "user", "content": "hi"}},
+		})
+		paths = append(paths, path)
+	}
+
+	// The first file is used again after the second, so the second becomes
+	// the least recently used.
+	order := append([]string{paths[0], paths[1], paths[0]}, paths[2:]...)
+	for _, path := range order {
+		if _, err := sl.index(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sl.indexes) != maxCachedIndexes {
+		t.Fatalf("cached %d indexes, want %d", len(sl.indexes), maxCachedIndexes)
+	}
+	if sl.indexes[paths[1]] != nil || sl.indexes[paths[0]] == nil {
+		t.Error("expected the least recently used index to be evicted")
+	}
+
+	// The index of a removed file is dropped.
+	if err := os.Remove(paths[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sl.index(paths[0]); err == nil {
+		t.Fatal("expected error for removed file")
+	}
+	if sl.indexes[paths[0]] != nil {
+		t.Error("expected the removed file's index to be dropped")
+	}
+}
+
+func TestClampPageSize(t *testing.T) {
+	tests := []struct{ in, want int }{
+		{0, defaultPageSize},
+		{-5, defaultPageSize},
+		{10, 10},
+		{maxPageSize + 1, maxPageSize},
+	}
+	for _, tt := range tests {
+		if got := clampPageSize(tt.in); got != tt.want {
+			t.Errorf("clampPageSize(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(projectDir, "sess.jsonl"), []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "isMeta": true, "message": map[string]interface{}{"role": "user", "content": "<local-command-caveat>"}},
+		{"type": "user", "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{"role": "user", "content": "List the files"}},
+		{"type": "assistant", "timestamp": "2026-01-20T10:00:02Z", "message": map[string]interface{}{"role": "assistant", "model": "claude-sonnet-4-20250514", "content": []interface{}{
+			map[string]interface{}{"type": "thinking", "thinking": "Use ls"},
+			map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "Bash", "input": map[string]interface{}{"command": "ls"}},
+		}}},
+		{"type": "user", "timestamp": "2026-01-20T10:00:03Z", "message": map[string]interface{}{"role": "user", "content": []interface{}{
+			map[string]interface{}{"type": "tool_result", "tool_use_id": "toolu_1", "content": []interface{}{map[string]interface{}{"type": "text", "text": "a.go\nb.go"}}},
+		}}},
+		{"type": "assistant", "timestamp": "2026-01-20T10:00:04Z", "message": map[string]interface{}{"role": "assistant", "content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "Two files."},
+		}}},
+		{"type": "system", "subtype": "compact_boundary", "timestamp": "2026-01-20T10:00:05Z", "content": "Conversation compacted"},
+		{"type": "summary", "summary": "Listing files"},
+	})
+
+	sl := NewSessionLister(tmpDir)
+	tr, err := sl.LoadSession("/home/user/project", "sess")
+	if err != nil {
+		t.Fatalf("LoadSession() error = %v", err)
+	}
+
+	if tr.SessionID != "sess" {
+		t.Errorf("SessionID = %q, want %q", tr.SessionID, "sess")
+	}
+	if tr.Summary != "Listing files" {
+		t.Errorf("Summary = %q, want %q", tr.Summary, "Listing files")
+	}
+
+	want := []struct {
+		typ   string
+		check func(be BridgeEvent) bool
+	}{
+		{"user", func(be BridgeEvent) bool { return be.Text == "List the files" }},
+		{"assistant", func(be BridgeEvent) bool { return be.Thinking == "Use ls" && be.Model == "claude-sonnet-4-20250514" }},
+		{"assistant", func(be BridgeEvent) bool {
+			return be.ToolName == "Bash" && be.ToolUseID == "toolu_1" && be.ToolSummary == "$ ls"
+		}},
+		{"tool_result", func(be BridgeEvent) bool { return be.ToolUseID == "toolu_1" && be.Result == "a.go\nb.go" }},
+		{"assistant", func(be BridgeEvent) bool { return be.Text == "Two files." }},
+		{"system", func(be BridgeEvent) bool {
+			return be.Subtype == "compact_boundary" && be.Text == "Conversation compacted"
+		}},
+		{"summary", func(be BridgeEvent) bool { return be.Text == "Listing files" }},
+	}
+	if len(tr.Events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(tr.Events), len(want), tr.Events)
+	}
+	for i, w := range want {
+		be := tr.Events[i]
+		if be.Type != w.typ {
+			t.Errorf("event[%d].Type = %q, want %q", i, be.Type, w.typ)
+		}
+		if !w.check(be) {
+			t.Errorf("event[%d] has unexpected content: %+v", i, be)
+		}
+		if be.SessionID != "sess" {
+			t.Errorf("event[%d].SessionID = %q, want %q", i, be.SessionID, "sess")
+		}
+	}
+	if tr.Events[0].Timestamp != "2026-01-20T10:00:01Z" {
+		t.Errorf("event[0].Timestamp = %q", tr.Events[0].Timestamp)
+	}
+}
+
+func TestLoadSession_Errors(t *testing.T) {
+	t.Run("missing session file", func(t *testing.T) {
+		sl := NewSessionLister(t.TempDir())
+		if _, err := sl.LoadSession("/home/user/project", "missing"); err == nil {
+			t.Error("expected error for missing session")
+		}
+	})
+
+	t.Run("invalid session id", func(t *testing.T) {
+		sl := NewSessionLister(t.TempDir())
+		if _, err := sl.LoadSession("/home/user/project", "a/b"); err == nil {
+			t.Error("expected error for invalid session id")
+		}
+	})
+}
+
+func TestToolResultText(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"string content", `"plain output"`, "plain output"},
+		{"text blocks", `[{"type":"text","text":"a"},{"type":"image"},{"type":"text","text":"b"}]`, "a\nb"},
+		{"empty", ``, ""},
+		{"invalid", `{"x":1}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toolResultText([]byte(tt.raw)); got != tt.want {
+				t.Errorf("toolResultText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
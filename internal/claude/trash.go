@@ -0,0 +1,241 @@
+package claude
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrashedSession is a session that was deleted to the trash.
+type TrashedSession struct {
+	SessionInfo
+	Project   string    `json:"project"` // Encoded project directory name
+	TrashedAt time.Time `json:"trashed_at"`
+}
+
+// TrashSession moves a session's JSONL file, and the CLI's per-session
+// directory if there is one, into the trash. The trash mirrors the projects
+// layout, so RestoreSession can move the files back unchanged.
+func (sl *SessionLister) TrashSession(projectPath string, sessionID string) error {
+	src, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("session %s: %w", sessionID, err)
+	}
+	trash, err := sl.trashDir()
+	if err != nil {
+		return err
+	}
+	dstDir := filepath.Join(trash, filepath.Base(filepath.Dir(src)))
+	dst := filepath.Join(dstDir, sessionID+".jsonl")
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("session %s is already in the trash", sessionID)
+	}
+
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return fmt.Errorf("create trash dir: %w", err)
+	}
+	if err := moveSessionFiles(strings.TrimSuffix(src, ".jsonl"), strings.TrimSuffix(dst, ".jsonl")); err != nil {
+		return fmt.Errorf("move session to trash: %w", err)
+	}
+	return sl.metaStore().setTrashed(sessionID, time.Now().UTC())
+}
+
+// RestoreSession moves a trashed session back into its project directory.
+// It fails if a session with the same ID exists there again.
+func (sl *SessionLister) RestoreSession(sessionID string) error {
+	src, project, err := sl.findTrashed(sessionID)
+	if err != nil {
+		return err
+	}
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return err
+	}
+	dstDir := filepath.Join(base, "projects", project)
+	dst := filepath.Join(dstDir, sessionID+".jsonl")
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("session %s already exists in project %s", sessionID, project)
+	}
+
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return fmt.Errorf("create project dir: %w", err)
+	}
+	if err := moveSessionFiles(strings.TrimSuffix(src, ".jsonl"), strings.TrimSuffix(dst, ".jsonl")); err != nil {
+		return fmt.Errorf("restore session: %w", err)
+	}
+	return sl.metaStore().setTrashed(sessionID, time.Time{})
+}
+
+// ListTrash returns the trashed sessions of every project, most recently
+// trashed first.
+func (sl *SessionLister) ListTrash() ([]TrashedSession, error) {
+	trash, err := sl.trashDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(trash, "*", "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	store := sl.metaStore()
+	out := []TrashedSession{}
+	for _, path := range paths {
+		info, err := parseSessionFile(path)
+		if err != nil {
+			continue
+		}
+		meta, err := store.Get(info.ID)
+		if err != nil {
+			return nil, err
+		}
+		applyMeta(&info, meta)
+		trashedAt := meta.TrashedAt
+		if trashedAt.IsZero() {
+			if stat, err := os.Stat(path); err == nil {
+				trashedAt = stat.ModTime()
+			}
+		}
+		out = append(out, TrashedSession{
+			SessionInfo: info,
+			Project:     filepath.Base(filepath.Dir(path)),
+			TrashedAt:   trashedAt,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].TrashedAt.After(out[j].TrashedAt) })
+	return out, nil
+}
+
+// findTrashed returns the trashed JSONL path and project of a session.
+func (sl *SessionLister) findTrashed(sessionID string) (path string, project string, err error) {
+	if err := ValidateSessionID(sessionID); err != nil {
+		return "", "", err
+	}
+	trash, err := sl.trashDir()
+	if err != nil {
+		return "", "", err
+	}
+	matches, err := filepath.Glob(filepath.Join(trash, "*", sessionID+".jsonl"))
+	if err != nil {
+		return "", "", err
+	}
+	if len(matches) == 0 {
+		return "", "", fmt.Errorf("session %s not found in trash", sessionID)
+	}
+	return matches[0], filepath.Base(filepath.Dir(matches[0])), nil
+}
+
+// trashDir returns TrashDir, defaulting to dogma-trash next to the projects
+// directory for callers that have no directory of their own to put it in.
+func (sl *SessionLister) trashDir() (string, error) {
+	if sl.TrashDir != "" {
+		return sl.TrashDir, nil
+	}
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "dogma-trash"), nil
+}
+
+// moveSessionFiles moves src+".jsonl" to dst+".jsonl" and, if present, the
+// session directory src to dst.
+func moveSessionFiles(src, dst string) error {
+	if err := moveFile(src+".jsonl", dst+".jsonl"); err != nil {
+		return err
+	}
+	if stat, err := os.Stat(src); err == nil && stat.IsDir() {
+		if err := moveDir(src, dst); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveDir renames the directory src to dst, copying it across filesystems
+// when needed.
+func moveDir(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyDir(src, dst); err != nil {
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyDir copies the directory tree src to the new directory dst. dst is
+// removed again if the copy fails.
+func copyDir(src, dst string) error {
+	if err := os.Mkdir(dst, 0755); err != nil {
+		return err
+	}
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.Mkdir(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+	if err != nil {
+		os.RemoveAll(dst)
+	}
+	return err
+}
+
+// moveFile renames src to dst, copying across filesystems when needed.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to the new file dst with the same permissions and
+// modification time. dst is removed again if the copy fails.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	// ListTrash falls back to the modification time, so keep it; a copy
+	// with the current time is still a complete copy.
+	_ = os.Chtimes(dst, stat.ModTime(), stat.ModTime())
+	return nil
+}
@@ -0,0 +1,143 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrashAndRestoreSession(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(filepath.Join(projectDir, "s1", "subagents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(projectDir, "s1.jsonl"), []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Trash me"}},
+	})
+	sl := NewSessionLister(tmpDir)
+	sl.RenameSession("s1", "Custom")
+
+	if err := sl.TrashSession("/home/user/project", "s1"); err != nil {
+		t.Fatalf("TrashSession() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "s1.jsonl")); !os.IsNotExist(err) {
+		t.Error("session file still in project after trashing")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "dogma-trash", "-home-user-project", "s1", "subagents")); err != nil {
+		t.Errorf("session directory not moved to trash: %v", err)
+	}
+	sessions, _ := sl.ListSessions("/home/user/project")
+	if len(sessions) != 0 {
+		t.Errorf("ListSessions() = %+v, want no sessions", sessions)
+	}
+
+	trash, err := sl.ListTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].ID != "s1" || trash[0].Project != "-home-user-project" || trash[0].Title != "Custom" {
+		t.Fatalf("ListTrash() = %+v, want s1", trash)
+	}
+	if trash[0].TrashedAt.IsZero() {
+		t.Error("TrashedAt not set")
+	}
+
+	if err := sl.RestoreSession("s1"); err != nil {
+		t.Fatalf("RestoreSession() error = %v", err)
+	}
+	sessions, _ = sl.ListSessions("/home/user/project")
+	if len(sessions) != 1 || sessions[0].Title != "Custom" {
+		t.Errorf("ListSessions() after restore = %+v, want s1 with its title", sessions)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "s1", "subagents")); err != nil {
+		t.Errorf("session directory not restored: %v", err)
+	}
+	if meta, _ := sl.metaStore().Get("s1"); !meta.TrashedAt.IsZero() {
+		t.Error("TrashedAt not cleared on restore")
+	}
+	if trash, _ := sl.ListTrash(); len(trash) != 0 {
+		t.Errorf("ListTrash() after restore = %+v, want empty", trash)
+	}
+}
+
+func TestTrashSession_Errors(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	sl := NewSessionLister(tmpDir)
+
+	if err := sl.TrashSession("/home/user/project", "missing"); err == nil {
+		t.Error("TrashSession(missing) error = nil, want error")
+	}
+	if err := sl.TrashSession("/home/user/project", "../x"); err == nil {
+		t.Error("TrashSession(../x) error = nil, want error")
+	}
+	if err := sl.RestoreSession("missing"); err == nil {
+		t.Error("RestoreSession(missing) error = nil, want error")
+	}
+
+	// Restoring over a session that exists again must not clobber it.
+	line := []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "x"}},
+	}
+	writeJSONLFile(t, filepath.Join(projectDir, "s1.jsonl"), line)
+	if err := sl.TrashSession("/home/user/project", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(projectDir, "s1.jsonl"), line)
+	if err := sl.RestoreSession("s1"); err == nil {
+		t.Error("RestoreSession() over existing session error = nil, want error")
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a")
+	dst := filepath.Join(dir, "b")
+	os.WriteFile(src, []byte("data"), 0644)
+
+	if err := moveFile(src, dst); err != nil {
+		t.Fatalf("moveFile() error = %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "data" {
+		t.Errorf("dst = %q, want data", data)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("src still exists")
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "subagents"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(src, "subagents", "agent.jsonl"), []byte("data"), 0644)
+	stamp := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(src, "subagents", "agent.jsonl"), stamp, stamp)
+
+	dst := filepath.Join(dir, "dst")
+	if err := copyDir(src, dst); err != nil {
+		t.Fatalf("copyDir() error = %v", err)
+	}
+	stat, err := os.Stat(filepath.Join(dst, "subagents", "agent.jsonl"))
+	if err != nil {
+		t.Fatalf("copied file missing: %v", err)
+	}
+	if !stat.ModTime().Equal(stamp) {
+		t.Errorf("ModTime = %v, want %v", stat.ModTime(), stamp)
+	}
+
+	// An existing destination is never merged into or removed
+	if err := copyDir(src, dst); err == nil {
+		t.Error("copyDir() onto an existing directory error = nil, want error")
+	}
+	if _, err := os.Stat(filepath.Join(dst, "subagents", "agent.jsonl")); err != nil {
+		t.Errorf("existing destination changed: %v", err)
+	}
+}
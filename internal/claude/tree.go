@@ -0,0 +1,263 @@
+package claude
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ConversationTree describes the branches of a session. Entries link to
+// their predecessor through parentUuid, so editing a prompt, retrying a
+// response or running a sidechain starts a new path from an earlier entry.
+// Each leaf ends one branch.
+type ConversationTree struct {
+	SessionID  string               `json:"session_id"`
+	ActiveLeaf string               `json:"active_leaf"` // Leaf the CLI continues from on resume
+	Branches   []ConversationBranch `json:"branches"`    // Active branch first, then newest first
+}
+
+// ConversationBranch is the path from a root entry to one leaf.
+type ConversationBranch struct {
+	LeafUUID  string `json:"leaf_uuid"`
+	ForkUUID  string `json:"fork_uuid,omitempty"` // Last entry shared with the active branch
+	Active    bool   `json:"active,omitempty"`
+	Sidechain bool   `json:"sidechain,omitempty"`
+	Messages  int    `json:"messages"`  // User and assistant entries on the path
+	Timestamp string `json:"timestamp"` // Of the leaf
+	Preview   string `json:"preview"`   // First prompt after the fork, or of the branch
+}
+
+// treeNode is an entry with a UUID and its position in the file.
+type treeNode struct {
+	entry    sessionEntry
+	order    int
+	children []string
+}
+
+// conversationGraph holds the entries of a session file linked by UUID.
+type conversationGraph struct {
+	nodes     map[string]*treeNode
+	order     []string          // UUIDs in file order
+	summaries map[string]string // Summary text by leafUuid
+	entries   []sessionEntry    // Every entry in file order, for files without UUIDs
+}
+
+// parent returns the UUID of the entry preceding n. Compaction boundaries
+// restart the chain and refer back through logicalParentUuid.
+func (n *treeNode) parent() string {
+	if n.entry.ParentUUID != "" {
+		return n.entry.ParentUUID
+	}
+	return n.entry.LogicalParentUUID
+}
+
+// readConversationGraph reads every entry of a session file and links
+// entries with a UUID. Lines that are not valid JSON are skipped.
+func readConversationGraph(path string) (*conversationGraph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	defer f.Close()
+
+	g := &conversationGraph{nodes: make(map[string]*treeNode), summaries: make(map[string]string)}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSessionLineSize)
+	for scanner.Scan() {
+		var entry sessionEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		g.entries = append(g.entries, entry)
+		if entry.Type == "summary" && entry.LeafUUID != "" {
+			g.summaries[entry.LeafUUID] = entry.Summary
+		}
+		if entry.UUID == "" {
+			continue
+		}
+		if _, dup := g.nodes[entry.UUID]; dup {
+			continue
+		}
+		g.nodes[entry.UUID] = &treeNode{entry: entry, order: len(g.order)}
+		g.order = append(g.order, entry.UUID)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+	for _, id := range g.order {
+		if p, ok := g.nodes[g.nodes[id].parent()]; ok {
+			p.children = append(p.children, id)
+		}
+	}
+	return g, nil
+}
+
+// activeLeaf returns the leaf the CLI resumes from: the last main-chain
+// entry written, followed down to its most recently written descendant
+// outside sidechains.
+// In a corrupt file with a parent cycle the walk stops at the first entry
+// it reaches twice.
+func (g *conversationGraph) activeLeaf() string {
+	var id string
+	for i := len(g.order) - 1; i >= 0; i-- {
+		if !g.nodes[g.order[i]].entry.IsSidechain {
+			id = g.order[i]
+			break
+		}
+	}
+	if id == "" {
+		return ""
+	}
+	seen := map[string]bool{id: true}
+	for {
+		next := g.lastMainChild(id)
+		if next == "" || seen[next] {
+			return id
+		}
+		seen[next] = true
+		id = next
+	}
+}
+
+// lastMainChild returns the most recently written child of id that is not
+// on a sidechain, or "" if there is none.
+func (g *conversationGraph) lastMainChild(id string) string {
+	children := g.nodes[id].children
+	for i := len(children) - 1; i >= 0; i-- {
+		if !g.nodes[children[i]].entry.IsSidechain {
+			return children[i]
+		}
+	}
+	return ""
+}
+
+// path returns the UUIDs from the root to leaf. Links to missing entries
+// end the path, and cycles are cut.
+func (g *conversationGraph) path(leaf string) []string {
+	var rev []string
+	seen := make(map[string]bool)
+	for id := leaf; id != "" && !seen[id]; {
+		n, ok := g.nodes[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		rev = append(rev, id)
+		id = n.parent()
+	}
+	for i, j := 0, len(rev)-1; i < j; i, j = i+1, j-1 {
+		rev[i], rev[j] = rev[j], rev[i]
+	}
+	return rev
+}
+
+// tree builds the branch list of the graph.
+func (g *conversationGraph) tree(sessionID string) ConversationTree {
+	t := ConversationTree{SessionID: sessionID, ActiveLeaf: g.activeLeaf(), Branches: []ConversationBranch{}}
+	onActive := make(map[string]bool)
+	for _, id := range g.path(t.ActiveLeaf) {
+		onActive[id] = true
+	}
+
+	for _, id := range g.order {
+		n := g.nodes[id]
+		if len(n.children) > 0 {
+			continue
+		}
+		path := g.path(id)
+		b := ConversationBranch{
+			LeafUUID:  id,
+			Active:    id == t.ActiveLeaf,
+			Sidechain: n.entry.IsSidechain,
+			Timestamp: n.entry.Timestamp,
+		}
+		start := 0
+		if !b.Active {
+			for i := len(path) - 1; i >= 0; i-- {
+				if onActive[path[i]] {
+					b.ForkUUID = path[i]
+					start = i + 1
+					break
+				}
+			}
+		}
+		for i, pid := range path {
+			e := g.nodes[pid].entry
+			if (e.Type == "user" || e.Type == "assistant") && !e.IsMeta {
+				b.Messages++
+			}
+			if b.Preview == "" && i >= start {
+				b.Preview = truncateRunes(promptText(e), maxPreviewLen)
+			}
+		}
+		t.Branches = append(t.Branches, b)
+	}
+
+	sort.SliceStable(t.Branches, func(i, j int) bool {
+		a, b := t.Branches[i], t.Branches[j]
+		if a.Active != b.Active {
+			return a.Active
+		}
+		return g.nodes[a.LeafUUID].order > g.nodes[b.LeafUUID].order
+	})
+	return t
+}
+
+// transcript returns the entries from the root to leaf as a transcript.
+// Files without UUIDs have no branches and are returned in full.
+func (g *conversationGraph) transcript(sessionID string, leaf string) (Transcript, error) {
+	tr := Transcript{SessionID: sessionID, Events: []BridgeEvent{}}
+	if len(g.nodes) == 0 {
+		for _, entry := range g.entries {
+			if entry.Type == "summary" {
+				tr.Summary = entry.Summary
+			}
+			tr.Events = append(tr.Events, entryToBridgeEvents(entry, sessionID)...)
+		}
+		return tr, nil
+	}
+
+	if leaf == "" {
+		leaf = g.activeLeaf()
+	}
+	if _, ok := g.nodes[leaf]; !ok {
+		return Transcript{}, fmt.Errorf("message %q not found in session %s", leaf, sessionID)
+	}
+	for _, id := range g.path(leaf) {
+		if s, ok := g.summaries[id]; ok {
+			tr.Summary = s
+		}
+		tr.Events = append(tr.Events, entryToBridgeEvents(g.nodes[id].entry, sessionID)...)
+	}
+	return tr, nil
+}
+
+// SessionBranches returns the branches of a session's conversation tree.
+func (sl *SessionLister) SessionBranches(projectPath string, sessionID string) (ConversationTree, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return ConversationTree{}, err
+	}
+	g, err := readConversationGraph(path)
+	if err != nil {
+		return ConversationTree{}, err
+	}
+	return g.tree(sessionID), nil
+}
+
+// LoadBranch returns the path from the root of a session to the given leaf
+// as a linear transcript. An empty leafUUID loads the active branch. Any
+// entry may be given as the leaf; the transcript then ends there.
+func (sl *SessionLister) LoadBranch(projectPath string, sessionID string, leafUUID string) (Transcript, error) {
+	path, err := sl.sessionFilePath(projectPath, sessionID)
+	if err != nil {
+		return Transcript{}, err
+	}
+	g, err := readConversationGraph(path)
+	if err != nil {
+		return Transcript{}, err
+	}
+	return g.transcript(sessionID, leafUUID)
+}
@@ -0,0 +1,207 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeBranchedSession writes a session where the second prompt was edited,
+// a sidechain ran, and the conversation was compacted afterwards:
+//
+//	u1 -> a1 -> u2 -> a2            (original prompt, summarized)
+//	         \-> u3 -> a3 ~> c1 -> u4 -> a4   (edited prompt, compacted)
+//	s1 -> s2                        (sidechain)
+func writeBranchedSession(t *testing.T) *SessionLister {
+	t.Helper()
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	user := func(uuid, parent, text string) map[string]interface{} {
+		return map[string]interface{}{"type": "user", "uuid": uuid, "parentUuid": parent, "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": text}}
+	}
+	assistant := func(uuid, parent, text string) map[string]interface{} {
+		return map[string]interface{}{"type": "assistant", "uuid": uuid, "parentUuid": parent, "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{
+			"role": "assistant", "model": "claude-sonnet-4-5", "content": []interface{}{map[string]interface{}{"type": "text", "text": text}},
+		}}
+	}
+	s1 := user("s1", "", "Subagent task")
+	s1["isSidechain"] = true
+	s2 := assistant("s2", "s1", "Subagent answer")
+	s2["isSidechain"] = true
+	writeJSONLFile(t, filepath.Join(projectDir, "s.jsonl"), []map[string]interface{}{
+		user("u1", "", "Start"),
+		assistant("a1", "u1", "Ready"),
+		user("u2", "a1", "Try A"),
+		assistant("a2", "u2", "Did A"),
+		{"type": "summary", "summary": "Tried A", "leafUuid": "a2"},
+		user("u3", "a1", "Try B"),
+		assistant("a3", "u3", "Did B"),
+		s1,
+		s2,
+		{"type": "system", "subtype": "compact_boundary", "uuid": "c1", "logicalParentUuid": "a3", "timestamp": "2026-01-20T11:00:00Z", "content": "Conversation compacted"},
+		user("u4", "c1", "After compact"),
+		assistant("a4", "u4", "Continuing"),
+	})
+	return NewSessionLister(base)
+}
+
+func branchSummary(b ConversationBranch) string {
+	return strings.Join([]string{b.LeafUUID, b.ForkUUID, b.Preview}, "|")
+}
+
+func TestSessionBranches(t *testing.T) {
+	sl := writeBranchedSession(t)
+
+	tree, err := sl.SessionBranches("/home/user/project", "s")
+	if err != nil {
+		t.Fatalf("SessionBranches() error = %v", err)
+	}
+	if tree.ActiveLeaf != "a4" {
+		t.Errorf("ActiveLeaf = %q, want a4", tree.ActiveLeaf)
+	}
+	if len(tree.Branches) != 3 {
+		t.Fatalf("Branches = %+v, want 3", tree.Branches)
+	}
+
+	active, side, original := tree.Branches[0], tree.Branches[1], tree.Branches[2]
+	if !active.Active || branchSummary(active) != "a4||Start" || active.Messages != 6 {
+		t.Errorf("active branch = %+v", active)
+	}
+	if !side.Sidechain || branchSummary(side) != "s2||Subagent task" || side.Messages != 2 {
+		t.Errorf("sidechain branch = %+v", side)
+	}
+	if original.Active || branchSummary(original) != "a2|a1|Try A" || original.Messages != 4 {
+		t.Errorf("original branch = %+v", original)
+	}
+}
+
+func TestSessionBranches_ActiveLeafSkipsSidechainChildren(t *testing.T) {
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	entry := func(uuid, parent string, sidechain bool) map[string]interface{} {
+		return map[string]interface{}{"type": "user", "uuid": uuid, "parentUuid": parent, "isSidechain": sidechain, "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": uuid}}
+	}
+	// Older CLIs wrote sidechains inline, hanging off the main chain
+	writeJSONLFile(t, filepath.Join(projectDir, "s.jsonl"), []map[string]interface{}{
+		entry("u1", "", false),
+		entry("u2", "u1", false),
+		entry("s1", "u2", true),
+		entry("s2", "s1", true),
+	})
+
+	tree, err := NewSessionLister(base).SessionBranches("/home/user/project", "s")
+	if err != nil {
+		t.Fatalf("SessionBranches() error = %v", err)
+	}
+	if tree.ActiveLeaf != "u2" {
+		t.Errorf("ActiveLeaf = %q, want u2", tree.ActiveLeaf)
+	}
+}
+
+func TestLoadBranch(t *testing.T) {
+	sl := writeBranchedSession(t)
+
+	uuids := func(tr Transcript) string {
+		var ids []string
+		for _, ev := range tr.Events {
+			ids = append(ids, ev.UUID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	active, err := sl.LoadBranch("/home/user/project", "s", "")
+	if err != nil {
+		t.Fatalf("LoadBranch(active) error = %v", err)
+	}
+	if got := uuids(active); got != "u1,a1,u3,a3,c1,u4,a4" {
+		t.Errorf("active branch = %s", got)
+	}
+
+	original, err := sl.LoadBranch("/home/user/project", "s", "a2")
+	if err != nil {
+		t.Fatalf("LoadBranch(a2) error = %v", err)
+	}
+	if got := uuids(original); got != "u1,a1,u2,a2" || original.Summary != "Tried A" {
+		t.Errorf("original branch = %s (summary %q)", got, original.Summary)
+	}
+
+	// Any entry can end a branch, e.g. to show the state at a fork point.
+	prefix, err := sl.LoadBranch("/home/user/project", "s", "a1")
+	if err != nil || uuids(prefix) != "u1,a1" {
+		t.Errorf("LoadBranch(a1) = %s, %v", uuids(prefix), err)
+	}
+
+	if _, err := sl.LoadBranch("/home/user/project", "s", "missing"); err == nil {
+		t.Error("LoadBranch(missing) error = nil, want error")
+	}
+}
+
+func TestLoadBranch_WithoutUUIDs(t *testing.T) {
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeJSONLFile(t, filepath.Join(projectDir, "old.jsonl"), []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": "Hello"}},
+		{"type": "assistant", "timestamp": "2026-01-20T10:00:01Z", "message": map[string]interface{}{"role": "assistant", "content": "Hi"}},
+	})
+	sl := NewSessionLister(base)
+
+	tr, err := sl.LoadBranch("/home/user/project", "old", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.Events) != 2 {
+		t.Errorf("Events = %+v, want the whole file", tr.Events)
+	}
+	tree, err := sl.SessionBranches("/home/user/project", "old")
+	if err != nil || tree.ActiveLeaf != "" || len(tree.Branches) != 0 {
+		t.Errorf("SessionBranches() = %+v, %v; want no branches", tree, err)
+	}
+}
+
+func TestSessionBranches_CyclicParents(t *testing.T) {
+	base := t.TempDir()
+	projectDir := filepath.Join(base, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	entry := func(uuid, parent, text string) map[string]interface{} {
+		return map[string]interface{}{"type": "user", "uuid": uuid, "parentUuid": parent, "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": text}}
+	}
+	// u1 and u2 are each other's parent and u3 is its own
+	writeJSONLFile(t, filepath.Join(projectDir, "cyclic.jsonl"), []map[string]interface{}{
+		entry("u1", "u2", "One"),
+		entry("u2", "u1", "Two"),
+		entry("u3", "u3", "Three"),
+	})
+	sl := NewSessionLister(base)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := sl.SessionBranches("/home/user/project", "cyclic"); err != nil {
+			t.Errorf("SessionBranches() error = %v", err)
+		}
+		if _, err := sl.LoadBranch("/home/user/project", "cyclic", ""); err != nil {
+			t.Errorf("LoadBranch() error = %v", err)
+		}
+		if tr, err := sl.LoadBranch("/home/user/project", "cyclic", "u2"); err != nil || len(tr.Events) != 2 {
+			t.Errorf("LoadBranch(u2) = %+v, %v; want the two cycle entries", tr.Events, err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("walking a cyclic session did not terminate")
+	}
+}
@@ -0,0 +1,252 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watcher timing defaults. The CLI appends to a session file on every
+// message, so changes are only reported once the directory has been quiet
+// for the debounce period, or after maxWatchDelay during a long busy stretch.
+const (
+	defaultWatchInterval = time.Second
+	defaultWatchDebounce = 500 * time.Millisecond
+	maxWatchDelay        = 5 * time.Second
+	watchResolveEvery    = 10 // Polls between lookups of a missing session dir
+)
+
+// SessionChanges describes how a project's sessions changed since the last report.
+type SessionChanges struct {
+	Added   []SessionInfo `json:"added"`
+	Updated []SessionInfo `json:"updated"`
+	Removed []string      `json:"removed"` // Session IDs
+}
+
+// Empty reports whether there are no changes.
+func (c SessionChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0
+}
+
+// fileStamp identifies a version of a file by its size and modification time.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func (s fileStamp) equal(o fileStamp) bool {
+	return s.size == o.size && s.modTime.Equal(o.modTime)
+}
+
+// SessionWatcher polls a project's session directory, and those of its
+// worktrees, and reports sessions created, continued or deleted outside the app, e.g. by the CLI in a
+// terminal. Polling keeps it dependency-free and works on every platform
+// and filesystem, including network drives.
+type SessionWatcher struct {
+	Interval time.Duration // Poll interval; defaults to one second
+	Debounce time.Duration // Quiet period before reporting; defaults to 500ms
+
+	lister      *SessionLister
+	projectPath string
+	onChange    func(SessionChanges)
+
+	dir        string
+	dirMissing bool
+	sinceCheck int // Polls since dir was last resolved
+	primed     bool
+	worktrees  map[string]string    // Worktree checkout by session directory
+	last       map[string]fileStamp // Snapshot of the previous poll
+	reported   map[string]fileStamp // Snapshot as of the last report
+	valid      map[string]bool      // Reported files that parsed as sessions
+	pending    bool
+	firstDirty time.Time
+	lastDirty  time.Time
+}
+
+// NewSessionWatcher creates a watcher for the sessions of projectPath.
+// onChange is called from the watcher goroutine with each batch of changes.
+func NewSessionWatcher(lister *SessionLister, projectPath string, onChange func(SessionChanges)) *SessionWatcher {
+	return &SessionWatcher{
+		lister:      lister,
+		projectPath: projectPath,
+		onChange:    onChange,
+	}
+}
+
+// Run polls until ctx is cancelled. The sessions present at start are the
+// baseline and are not reported.
+func (w *SessionWatcher) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	w.poll(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if changes, ok := w.poll(now); ok {
+				w.onChange(changes)
+			}
+		}
+	}
+}
+
+// poll takes a snapshot of the session directory and returns the changes
+// to report, if the debounce period has passed.
+func (w *SessionWatcher) poll(now time.Time) (SessionChanges, bool) {
+	snap := w.snapshot()
+
+	if !w.primed {
+		w.primed = true
+		w.last = snap
+		w.reported = make(map[string]fileStamp)
+		w.valid = make(map[string]bool)
+		w.apply(snap) // Parses the baseline and warms the metadata cache
+		return SessionChanges{}, false
+	}
+
+	if !sameSnapshot(snap, w.last) {
+		w.last = snap
+		if !w.pending {
+			w.pending = true
+			w.firstDirty = now
+		}
+		w.lastDirty = now
+	}
+	if !w.pending {
+		return SessionChanges{}, false
+	}
+	debounce := w.Debounce
+	if debounce <= 0 {
+		debounce = defaultWatchDebounce
+	}
+	if now.Sub(w.lastDirty) < debounce && now.Sub(w.firstDirty) < maxWatchDelay {
+		return SessionChanges{}, false
+	}
+
+	w.pending = false
+	changes := w.apply(snap)
+	return changes, !changes.Empty()
+}
+
+// apply updates the reported state to snap and returns the differences.
+func (w *SessionWatcher) apply(snap map[string]fileStamp) SessionChanges {
+	var changes SessionChanges
+	for path, stamp := range snap {
+		old, seen := w.reported[path]
+		if seen && old.equal(stamp) {
+			continue
+		}
+		wasValid := w.valid[path]
+		info, err := w.lister.sessionInfo(sessionFile{path: path, size: stamp.size, modTime: stamp.modTime})
+		if err == nil {
+			if meta, metaErr := w.lister.metaStore().Get(info.ID); metaErr == nil {
+				applyMeta(&info, meta)
+			}
+			info.Worktree = w.worktrees[filepath.Dir(path)]
+		}
+		w.reported[path] = stamp
+		w.valid[path] = err == nil
+		switch {
+		case err != nil && wasValid:
+			changes.Removed = append(changes.Removed, sessionIDFromPath(path))
+		case err != nil:
+			// Not a session yet, e.g. the first line is still being written
+		case wasValid:
+			changes.Updated = append(changes.Updated, info)
+		default:
+			changes.Added = append(changes.Added, info)
+		}
+	}
+	for path := range w.reported {
+		if _, ok := snap[path]; ok {
+			continue
+		}
+		if w.valid[path] {
+			changes.Removed = append(changes.Removed, sessionIDFromPath(path))
+		}
+		delete(w.reported, path)
+		delete(w.valid, path)
+	}
+	// The cache only saves work; watching continues even if it cannot be written.
+	_ = w.lister.metaCache().save()
+
+	byTime := func(s []SessionInfo) {
+		sort.Slice(s, func(i, j int) bool { return s[i].LastActivity.After(s[j].LastActivity) })
+	}
+	byTime(changes.Added)
+	byTime(changes.Updated)
+	sort.Strings(changes.Removed)
+	return changes
+}
+
+// snapshot stats the session files in the project's session directory and
+// in those of its worktrees as last listed. An unreadable or missing
+// directory adds nothing. A missing project directory is looked up again
+// every watchResolveEvery polls, since finding it by cwd reads every project.
+func (w *SessionWatcher) snapshot() map[string]fileStamp {
+	snap := make(map[string]fileStamp)
+	if w.dir == "" || (w.dirMissing && w.sinceCheck >= watchResolveEvery) {
+		if dir, err := w.lister.projectSessionsDir(w.projectPath); err == nil {
+			w.dir = dir
+		}
+		w.sinceCheck = 0
+	}
+	w.sinceCheck++
+
+	err := addSessionFiles(snap, w.dir)
+	w.dirMissing = os.IsNotExist(err)
+
+	w.worktrees = make(map[string]string)
+	for _, wt := range w.lister.worktreeSessionDirs(w.projectPath) {
+		if wt.dir == w.dir {
+			continue
+		}
+		w.worktrees[wt.dir] = wt.path
+		_ = addSessionFiles(snap, wt.dir) // Worktrees may not have run a session yet
+	}
+	return snap
+}
+
+// addSessionFiles adds the session files in dir to snap.
+func addSessionFiles(snap map[string]fileStamp, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snap[filepath.Join(dir, entry.Name())] = fileStamp{size: stat.Size(), modTime: stat.ModTime()}
+	}
+	return nil
+}
+
+func sameSnapshot(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if !v.equal(b[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sessionIDFromPath(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".jsonl")
+}
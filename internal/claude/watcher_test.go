@@ -0,0 +1,209 @@
+package claude
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeWatchedSession(t *testing.T, dir, id, text string, mtime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, id+".jsonl")
+	writeJSONLFile(t, path, []map[string]interface{}{
+		{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": text}},
+	})
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionWatcher_Poll(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	writeWatchedSession(t, projectDir, "existing", "Existing", base)
+
+	w := NewSessionWatcher(NewSessionLister(tmpDir), "/home/user/project", nil)
+	w.Debounce = time.Second
+	now := base
+
+	if _, ok := w.poll(now); ok {
+		t.Fatal("first poll reported changes, want baseline only")
+	}
+
+	// A new session and an empty file that is not a session yet.
+	writeWatchedSession(t, projectDir, "new", "New", base.Add(time.Minute))
+	os.WriteFile(filepath.Join(projectDir, "partial.jsonl"), nil, 0644)
+
+	now = now.Add(200 * time.Millisecond)
+	if _, ok := w.poll(now); ok {
+		t.Fatal("poll reported changes before the debounce period")
+	}
+	now = now.Add(2 * time.Second)
+	changes, ok := w.poll(now)
+	if !ok {
+		t.Fatal("poll after debounce reported no changes")
+	}
+	if len(changes.Added) != 1 || changes.Added[0].ID != "new" || changes.Added[0].FirstMessage != "New" {
+		t.Errorf("Added = %+v, want session new", changes.Added)
+	}
+	if len(changes.Updated) != 0 || len(changes.Removed) != 0 {
+		t.Errorf("changes = %+v, want only an addition", changes)
+	}
+
+	// No changes on disk: nothing to report.
+	now = now.Add(2 * time.Second)
+	if changes, ok := w.poll(now); ok {
+		t.Errorf("idle poll reported %+v", changes)
+	}
+
+	// Continue one session and delete the other.
+	writeWatchedSession(t, projectDir, "existing", "Continued", base.Add(2*time.Minute))
+	os.Remove(filepath.Join(projectDir, "new.jsonl"))
+	now = now.Add(time.Second)
+	w.poll(now)
+	now = now.Add(2 * time.Second)
+	changes, ok = w.poll(now)
+	if !ok {
+		t.Fatal("poll after debounce reported no changes")
+	}
+	if len(changes.Updated) != 1 || changes.Updated[0].FirstMessage != "Continued" {
+		t.Errorf("Updated = %+v, want continued session", changes.Updated)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != "new" {
+		t.Errorf("Removed = %v, want [new]", changes.Removed)
+	}
+	if len(changes.Added) != 0 {
+		t.Errorf("Added = %+v, want none", changes.Added)
+	}
+}
+
+func TestSessionWatcher_ReportsDuringLongWrites(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+
+	w := NewSessionWatcher(NewSessionLister(tmpDir), "/home/user/project", nil)
+	w.Debounce = time.Second
+	now := base
+	w.poll(now)
+
+	// The file changes on every poll, so the directory is never quiet.
+	var reported bool
+	for i := 1; i <= 20 && !reported; i++ {
+		writeWatchedSession(t, projectDir, "busy", "Busy", base.Add(time.Duration(i)*time.Second))
+		now = now.Add(500 * time.Millisecond)
+		_, reported = w.poll(now)
+	}
+	if !reported {
+		t.Error("continuous writes were never reported")
+	}
+	if elapsed := now.Sub(base); elapsed > maxWatchDelay+time.Second {
+		t.Errorf("first report after %v, want at most %v", elapsed, maxWatchDelay+time.Second)
+	}
+}
+
+func TestSessionWatcher_DirectoryCreatedLater(t *testing.T) {
+	tmpDir := t.TempDir()
+	w := NewSessionWatcher(NewSessionLister(tmpDir), "/home/user/project", nil)
+	w.Debounce = time.Millisecond
+	now := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	w.poll(now)
+
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeWatchedSession(t, projectDir, "first", "First", now)
+
+	now = now.Add(time.Second)
+	w.poll(now)
+	now = now.Add(time.Second)
+	changes, ok := w.poll(now)
+	if !ok || len(changes.Added) != 1 || changes.Added[0].ID != "first" {
+		t.Errorf("poll() = %+v, %v; want session first added", changes, ok)
+	}
+}
+
+func TestSessionWatcher_WatchesWorktrees(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	worktreeDir := filepath.Join(tmpDir, "projects", "-home-user-wt-fix")
+	for _, dir := range []string{projectDir, worktreeDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lister := NewSessionLister(tmpDir)
+	lister.Worktrees = func(projectPath string) []string {
+		return []string{"/home/user/project", "/home/user/wt-fix"}
+	}
+	w := NewSessionWatcher(lister, "/home/user/project", nil)
+	w.Debounce = time.Millisecond
+	now := time.Date(2026, 1, 20, 10, 0, 0, 0, time.UTC)
+	w.poll(now)
+
+	writeWatchedSession(t, worktreeDir, "isolated", "In the worktree", now)
+	now = now.Add(time.Second)
+	w.poll(now)
+	now = now.Add(time.Second)
+	changes, ok := w.poll(now)
+	if !ok || len(changes.Added) != 1 || changes.Added[0].ID != "isolated" {
+		t.Fatalf("poll() = %+v, %v; want the worktree session added", changes, ok)
+	}
+	if changes.Added[0].Worktree != "/home/user/wt-fix" {
+		t.Errorf("Worktree = %q, want /home/user/wt-fix", changes.Added[0].Worktree)
+	}
+}
+
+func TestSessionWatcher_RunStopsOnCancel(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "-home-user-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(chan SessionChanges, 1)
+	w := NewSessionWatcher(NewSessionLister(tmpDir), "/home/user/project", func(c SessionChanges) {
+		select {
+		case got <- c:
+		default:
+		}
+	})
+	w.Interval = 10 * time.Millisecond
+	w.Debounce = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	writeWatchedSession(t, projectDir, "live", "Live", time.Now())
+
+	select {
+	case c := <-got:
+		if len(c.Added) != 1 || c.Added[0].ID != "live" {
+			t.Errorf("onChange(%+v), want session live added", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("onChange not called")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Run did not return after cancel")
+	}
+}
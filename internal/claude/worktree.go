@@ -0,0 +1,55 @@
+package claude
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// AdoptWorktreeSessions moves the sessions run in worktreePath into the
+// session directory of projectPath, so they stay listed and resumable
+// after the worktree is removed. It returns the IDs of the moved sessions,
+// sorted. A session whose ID already exists in the project is left alone.
+func (sl *SessionLister) AdoptWorktreeSessions(projectPath string, worktreePath string) ([]string, error) {
+	base, err := sl.resolveBasePath()
+	if err != nil {
+		return nil, err
+	}
+	srcDir := filepath.Join(base, "projects", encodeProjectPath(worktreePath))
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("read sessions dir: %w", err)
+	}
+	dstDir, err := sl.projectSessionsDir(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return nil, fmt.Errorf("create sessions dir: %w", err)
+	}
+
+	moved := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".jsonl")
+		dst := filepath.Join(dstDir, id)
+		if _, err := os.Stat(dst + ".jsonl"); err == nil {
+			continue
+		}
+		if err := moveSessionFiles(filepath.Join(srcDir, id), dst); err != nil {
+			return moved, fmt.Errorf("move session %s: %w", id, err)
+		}
+		moved = append(moved, id)
+	}
+	sort.Strings(moved)
+	// Leave nothing behind for the removed worktree
+	_ = os.Remove(srcDir)
+	return moved, nil
+}
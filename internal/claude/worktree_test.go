@@ -0,0 +1,49 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdoptWorktreeSessions(t *testing.T) {
+	base := t.TempDir()
+	wtDir := filepath.Join(base, "projects", "-home-me-wt-fix")
+	projectDir := filepath.Join(base, "projects", "-home-me-project")
+	for _, dir := range []string{wtDir, projectDir, filepath.Join(wtDir, "s1")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	line := func(text string) []map[string]interface{} {
+		return []map[string]interface{}{{"type": "user", "timestamp": "2026-01-20T10:00:00Z", "message": map[string]interface{}{"role": "user", "content": text}}}
+	}
+	writeJSONLFile(t, filepath.Join(wtDir, "s1.jsonl"), line("From the worktree"))
+	writeJSONLFile(t, filepath.Join(wtDir, "dup.jsonl"), line("Worktree copy"))
+	writeJSONLFile(t, filepath.Join(projectDir, "dup.jsonl"), line("Project copy"))
+
+	sl := NewSessionLister(base)
+	moved, err := sl.AdoptWorktreeSessions("/home/me/project", "/home/me/wt-fix")
+	if err != nil {
+		t.Fatalf("AdoptWorktreeSessions() error = %v", err)
+	}
+	if len(moved) != 1 || moved[0] != "s1" {
+		t.Errorf("moved = %v, want [s1]", moved)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "s1")); err != nil {
+		t.Errorf("session directory not moved: %v", err)
+	}
+	sessions, err := sl.ListSessions("/home/me/project")
+	if err != nil || len(sessions) != 2 {
+		t.Fatalf("ListSessions() = %+v, %v; want s1 and dup", sessions, err)
+	}
+	for _, s := range sessions {
+		if s.ID == "dup" && s.FirstMessage != "Project copy" {
+			t.Errorf("project session overwritten: %+v", s)
+		}
+	}
+
+	if moved, err := sl.AdoptWorktreeSessions("/home/me/project", "/home/me/never-used"); err != nil || len(moved) != 0 {
+		t.Errorf("AdoptWorktreeSessions(no sessions) = %v, %v", moved, err)
+	}
+}
@@ -0,0 +1,118 @@
+// Package events provides an in-process publish/subscribe bus with typed topics.
+//
+// The App publishes run lifecycle, stream, usage, error and update events on a
+// single Bus. Consumers such as the Wails frontend bridge, loggers or exporters
+// subscribe to the topics they care about without touching the publisher.
+package events
+
+import "sync"
+
+// Event is a single message delivered to subscribers.
+type Event struct {
+	Topic   string
+	Payload interface{}
+}
+
+// Subscriber receives events published on a Bus.
+type Subscriber interface {
+	HandleEvent(ev Event)
+}
+
+// SubscriberFunc adapts an ordinary function to the Subscriber interface.
+type SubscriberFunc func(ev Event)
+
+// HandleEvent implements Subscriber.
+func (f SubscriberFunc) HandleEvent(ev Event) { f(ev) }
+
+// Filter decides whether an event is delivered to a subscriber.
+// A nil Filter accepts every event.
+type Filter func(ev Event) bool
+
+// OnTopics returns a Filter accepting events on any of the named topics.
+func OnTopics(names ...string) Filter {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return func(ev Event) bool {
+		_, ok := set[ev.Topic]
+		return ok
+	}
+}
+
+// Topic is a named event channel whose payloads are of type T.
+type Topic[T any] struct {
+	Name string
+}
+
+// subscription pairs a subscriber with its filter.
+type subscription struct {
+	id     int
+	sub    Subscriber
+	filter Filter
+}
+
+// Bus delivers published events synchronously, in publish order, to every
+// subscriber whose filter accepts them. It is safe for concurrent use.
+type Bus struct {
+	mu   sync.Mutex
+	subs []subscription // replaced, never mutated, so Publish can iterate without the lock
+	next int
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers sub for events accepted by filter and returns a
+// function that removes the subscription. Unsubscribing twice is a no-op.
+func (b *Bus) Subscribe(sub Subscriber, filter Filter) (unsubscribe func()) {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	subs := make([]subscription, len(b.subs), len(b.subs)+1)
+	copy(subs, b.subs)
+	b.subs = append(subs, subscription{id: id, sub: sub, filter: filter})
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := make([]subscription, 0, len(b.subs))
+		for _, s := range b.subs {
+			if s.id != id {
+				subs = append(subs, s)
+			}
+		}
+		b.subs = subs
+	}
+}
+
+// Publish delivers ev to all matching subscribers in subscription order.
+// Subscribers may subscribe or unsubscribe from within HandleEvent.
+func (b *Bus) Publish(ev Event) {
+	b.mu.Lock()
+	subs := b.subs
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		if s.filter == nil || s.filter(ev) {
+			s.sub.HandleEvent(ev)
+		}
+	}
+}
+
+// Publish sends a typed payload on topic.
+func Publish[T any](b *Bus, topic Topic[T], payload T) {
+	b.Publish(Event{Topic: topic.Name, Payload: payload})
+}
+
+// Subscribe registers fn for payloads published on topic.
+func Subscribe[T any](b *Bus, topic Topic[T], fn func(payload T)) (unsubscribe func()) {
+	return b.Subscribe(SubscriberFunc(func(ev Event) {
+		if payload, ok := ev.Payload.(T); ok {
+			fn(payload)
+		}
+	}), OnTopics(topic.Name))
+}
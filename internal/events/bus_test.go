@@ -0,0 +1,89 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBus_DeliversInSubscriptionOrder(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	bus.Subscribe(SubscriberFunc(func(ev Event) { got = append(got, "first:"+ev.Topic) }), nil)
+	bus.Subscribe(SubscriberFunc(func(ev Event) { got = append(got, "second:"+ev.Topic) }), nil)
+
+	bus.Publish(Event{Topic: "a"})
+	bus.Publish(Event{Topic: "b"})
+
+	want := []string{"first:a", "second:a", "first:b", "second:b"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBus_FilterSelectsTopics(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	bus.Subscribe(SubscriberFunc(func(ev Event) { got = append(got, ev.Topic) }), OnTopics("keep", "also"))
+
+	bus.Publish(Event{Topic: "keep"})
+	bus.Publish(Event{Topic: "drop"})
+	bus.Publish(Event{Topic: "also"})
+
+	if len(got) != 2 || got[0] != "keep" || got[1] != "also" {
+		t.Errorf("got %v, want [keep also]", got)
+	}
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus := NewBus()
+	count := 0
+	unsubscribe := bus.Subscribe(SubscriberFunc(func(ev Event) { count++ }), nil)
+
+	bus.Publish(Event{Topic: "x"})
+	unsubscribe()
+	unsubscribe() // second call is a no-op
+	bus.Publish(Event{Topic: "x"})
+
+	if count != 1 {
+		t.Errorf("expected 1 delivery, got %d", count)
+	}
+}
+
+func TestBus_UnsubscribeDuringDelivery(t *testing.T) {
+	bus := NewBus()
+	var unsubscribe func()
+	count := 0
+	unsubscribe = bus.Subscribe(SubscriberFunc(func(ev Event) {
+		count++
+		unsubscribe()
+	}), nil)
+
+	bus.Publish(Event{Topic: "x"})
+	bus.Publish(Event{Topic: "x"})
+
+	if count != 1 {
+		t.Errorf("expected 1 delivery, got %d", count)
+	}
+}
+
+func TestTypedPublishSubscribe(t *testing.T) {
+	bus := NewBus()
+	var got []RunStart
+	Subscribe(bus, RunStarted, func(p RunStart) { got = append(got, p) })
+
+	Publish(bus, RunStarted, RunStart{RequestID: "r1", Prompt: "hi"})
+	Publish(bus, RunFinished, RunEnd{RequestID: "r1"})
+	// Untyped publish with a mismatched payload is ignored by typed subscribers
+	bus.Publish(Event{Topic: RunStarted.Name, Payload: "not a RunStart"})
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 payload, got %d", len(got))
+	}
+	if got[0].RequestID != "r1" || got[0].Prompt != "hi" {
+		t.Errorf("unexpected payload: %+v", got[0])
+	}
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"github.com/Marcel-Bich/dogma/internal/changeset"
+	"github.com/Marcel-Bich/dogma/internal/claude"
+	"github.com/Marcel-Bich/dogma/internal/git"
+	"github.com/Marcel-Bich/dogma/internal/updater"
+)
+
+// RunStart describes a prompt run that is about to start.
+type RunStart struct {
+	RequestID string `json:"request_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Prompt    string `json:"prompt"`
+}
+
+// RunEnd describes a finished prompt run. Error is empty on success.
+type RunEnd struct {
+	RequestID string               `json:"request_id,omitempty"`
+	SessionID string               `json:"session_id,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Changes   *changeset.Changeset `json:"changes,omitempty"` // Files the run changed, if tracked
+}
+
+// RunError reports a failed prompt run.
+type RunError struct {
+	RequestID string `json:"request_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Message   string `json:"message"`
+}
+
+// UsageReport carries the token usage and cost of a completed run.
+type UsageReport struct {
+	RequestID    string       `json:"request_id,omitempty"`
+	SessionID    string       `json:"session_id,omitempty"`
+	Usage        claude.Usage `json:"usage"`
+	TotalCostUSD float64      `json:"total_cost_usd,omitempty"`
+	DurationMs   float64      `json:"duration_ms,omitempty"`
+	NumTurns     int          `json:"num_turns,omitempty"`
+}
+
+// GitReport carries the working tree changes after a run or a git action.
+// RequestID and SessionID are empty when no run caused the report.
+type GitReport struct {
+	RequestID string      `json:"request_id,omitempty"`
+	SessionID string      `json:"session_id,omitempty"`
+	Changes   git.Changes `json:"changes"`
+}
+
+// Run lifecycle topics.
+var (
+	RunStarted  = Topic[RunStart]{Name: "run:started"}
+	RunFinished = Topic[RunEnd]{Name: "run:finished"}
+)
+
+// Claude stream topics. Names match the events the frontend listens to.
+var (
+	BridgeEvents = Topic[claude.BridgeEvent]{Name: "claude:event"}
+	Todos        = Topic[claude.TodoUpdate]{Name: "claude:todos"}
+	Usage        = Topic[UsageReport]{Name: "claude:usage"}
+	Errors       = Topic[RunError]{Name: "claude:error"}
+)
+
+// Session list topics, published when sessions change outside the app.
+var (
+	SessionsAdded   = Topic[[]claude.SessionInfo]{Name: "sessions:added"}
+	SessionsUpdated = Topic[[]claude.SessionInfo]{Name: "sessions:updated"}
+	SessionsRemoved = Topic[[]string]{Name: "sessions:removed"}
+	SessionDetached = Topic[string]{Name: "session:detached"} // Attached session no longer followed
+)
+
+// Working tree topics.
+var (
+	GitChanges = Topic[GitReport]{Name: "git:changes"}
+)
+
+// Self-update topics.
+var (
+	UpdateAvailable = Topic[*updater.UpdateInfo]{Name: "app:update-available"}
+	UpdateApplied   = Topic[*updater.UpdateInfo]{Name: "app:update-applied"}
+	UpdateFailed    = Topic[string]{Name: "app:update-error"}
+)
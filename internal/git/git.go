@@ -0,0 +1,327 @@
+// Package git reads and changes the state of a git working tree by running
+// the git CLI, so the result matches what the user sees in a terminal.
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// maxDiffSize caps the diff returned by Changes. Larger diffs are cut at
+// a line boundary and marked Truncated.
+const maxDiffSize = 1 << 20
+
+// ErrNotRepository is returned when the directory is not inside a git
+// working tree.
+var ErrNotRepository = errors.New("not a git repository")
+
+// Status describes how a file differs from HEAD.
+type Status string
+
+const (
+	StatusModified  Status = "modified"
+	StatusAdded     Status = "added"
+	StatusDeleted   Status = "deleted"
+	StatusRenamed   Status = "renamed"
+	StatusUntracked Status = "untracked"
+)
+
+// FileChange is a changed file in the working tree. Paths are relative to
+// the repository root and use forward slashes.
+type FileChange struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"` // Source of a rename
+	Status   Status `json:"status"`
+	Staged   bool   `json:"staged"`   // Has changes in the index
+	Unstaged bool   `json:"unstaged"` // Has changes not in the index
+}
+
+// Changes is the state of a working tree compared to HEAD.
+type Changes struct {
+	Root      string       `json:"root"`
+	Branch    string       `json:"branch,omitempty"`
+	Files     []FileChange `json:"files"`
+	Diff      string       `json:"diff"` // Unified diff of all files, untracked ones included
+	Truncated bool         `json:"truncated,omitempty"`
+}
+
+// Repo runs git commands for the working tree containing Dir.
+type Repo struct {
+	Dir     string
+	GitPath string // Path to the git binary (default: "git")
+}
+
+// Open returns a Repo for dir. It does not touch the disk; commands fail
+// with ErrNotRepository if dir is not inside a working tree.
+func Open(dir string) *Repo {
+	return &Repo{Dir: dir, GitPath: "git"}
+}
+
+// Root returns the top-level directory of the working tree.
+func (r *Repo) Root(ctx context.Context) (string, error) {
+	out, err := r.run(ctx, r.Dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		if _, statErr := os.Stat(r.Dir); statErr != nil || strings.Contains(err.Error(), "not a git repository") {
+			return "", ErrNotRepository
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Status lists the changed, added, deleted and untracked files.
+func (r *Repo) Status(ctx context.Context) ([]FileChange, error) {
+	root, err := r.Root(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.status(ctx, root)
+}
+
+// Files lists the files below Dir that are tracked or untracked but not
+// ignored, relative to Dir with forward slashes.
+func (r *Repo) Files(ctx context.Context) ([]string, error) {
+	if _, err := r.Root(ctx); err != nil {
+		return nil, err
+	}
+	out, err := r.run(ctx, r.Dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	files := []string{}
+	for _, path := range strings.Split(string(out), "\x00") {
+		// Unmerged files are listed once per stage
+		if path != "" && !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// Changes returns the changed files and a unified diff against HEAD,
+// covering staged, unstaged and untracked changes.
+func (r *Repo) Changes(ctx context.Context) (Changes, error) {
+	root, err := r.Root(ctx)
+	if err != nil {
+		return Changes{}, err
+	}
+	files, err := r.status(ctx, root)
+	if err != nil {
+		return Changes{}, err
+	}
+	changes := Changes{Root: root, Files: files}
+	if out, err := r.run(ctx, root, "symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		changes.Branch = strings.TrimSpace(string(out))
+	}
+
+	var diff bytes.Buffer
+	if r.hasHead(ctx, root) {
+		out, err := r.run(ctx, root, "diff", "--no-color", "--no-ext-diff", "HEAD")
+		if err != nil {
+			return Changes{}, err
+		}
+		diff.Write(out)
+	} else {
+		// Before the first commit everything tracked is in the index
+		out, err := r.run(ctx, root, "diff", "--no-color", "--no-ext-diff", "--cached")
+		if err != nil {
+			return Changes{}, err
+		}
+		diff.Write(out)
+		if out, err = r.run(ctx, root, "diff", "--no-color", "--no-ext-diff"); err != nil {
+			return Changes{}, err
+		}
+		diff.Write(out)
+	}
+	for _, f := range files {
+		if f.Status != StatusUntracked || diff.Len() > maxDiffSize {
+			continue
+		}
+		out, err := r.untrackedDiff(ctx, root, f.Path)
+		if err != nil {
+			return Changes{}, err
+		}
+		diff.Write(out)
+	}
+
+	changes.Diff = diff.String()
+	if len(changes.Diff) > maxDiffSize {
+		cut := strings.LastIndexByte(changes.Diff[:maxDiffSize], '\n')
+		changes.Diff = changes.Diff[:cut+1]
+		changes.Truncated = true
+	}
+	return changes, nil
+}
+
+// Stage adds the given paths, relative to the repository root, to the
+// index. Deleted files are staged as removals.
+func (r *Repo) Stage(ctx context.Context, paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	root, err := r.Root(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = r.run(ctx, root, append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+// Discard throws away all staged and unstaged changes to path, relative
+// to the repository root. Untracked and newly added files are deleted;
+// a renamed file is moved back.
+func (r *Repo) Discard(ctx context.Context, path string) error {
+	root, err := r.Root(ctx)
+	if err != nil {
+		return err
+	}
+	files, err := r.status(ctx, root)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.Path != path {
+			continue
+		}
+		switch {
+		case f.Status == StatusUntracked:
+			_, err = r.run(ctx, root, "clean", "-f", "--", f.Path)
+		case f.Status == StatusAdded:
+			_, err = r.run(ctx, root, "rm", "-f", "--", f.Path)
+		case f.Status == StatusRenamed:
+			if _, err = r.run(ctx, root, "rm", "-f", "--", f.Path); err == nil {
+				_, err = r.run(ctx, root, "restore", "--source=HEAD", "--staged", "--worktree", "--", f.OrigPath)
+			}
+		default:
+			_, err = r.run(ctx, root, "restore", "--source=HEAD", "--staged", "--worktree", "--", f.Path)
+		}
+		return err
+	}
+	return fmt.Errorf("%s has no changes", path)
+}
+
+// Commit records the staged changes with message and returns the new
+// commit hash.
+func (r *Repo) Commit(ctx context.Context, message string) (string, error) {
+	if strings.TrimSpace(message) == "" {
+		return "", errors.New("commit message is empty")
+	}
+	root, err := r.Root(ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.run(ctx, root, "commit", "-q", "-m", message); err != nil {
+		return "", err
+	}
+	out, err := r.run(ctx, root, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// status parses `git status --porcelain -z`.
+func (r *Repo) status(ctx context.Context, root string) ([]FileChange, error) {
+	out, err := r.run(ctx, root, "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	files := []FileChange{}
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 4 {
+			continue
+		}
+		x, y := entry[0], entry[1]
+		f := FileChange{Path: entry[3:]}
+		switch {
+		case x == '?':
+			f.Status = StatusUntracked
+			f.Unstaged = true
+			files = append(files, f)
+			continue
+		case x == '!':
+			continue
+		case x == 'R' || x == 'C':
+			// The source path follows as its own field
+			if i+1 < len(fields) {
+				i++
+				f.OrigPath = fields[i]
+			}
+			f.Status = StatusRenamed
+			if x == 'C' {
+				f.Status = StatusAdded
+			}
+		case x == 'A':
+			f.Status = StatusAdded
+		case x == 'D' || y == 'D':
+			f.Status = StatusDeleted
+		default:
+			f.Status = StatusModified
+		}
+		f.Staged = x != ' '
+		f.Unstaged = y != ' '
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// untrackedDiff returns the diff that adding path would produce.
+func (r *Repo) untrackedDiff(ctx context.Context, root string, path string) ([]byte, error) {
+	out, err := r.run(ctx, root, "diff", "--no-color", "--no-ext-diff", "--no-index", "--", os.DevNull, path)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// --no-index exits with 1 when the files differ
+		return out, nil
+	}
+	return out, err
+}
+
+// hasHead reports whether the repository has at least one commit.
+func (r *Repo) hasHead(ctx context.Context, root string) bool {
+	_, err := r.run(ctx, root, "rev-parse", "--verify", "-q", "HEAD")
+	return err == nil
+}
+
+// run executes git in dir and returns its stdout. Errors include git's
+// stderr. Optional locks are disabled so a status check never blocks a
+// git command the agent runs at the same time, and messages are kept in
+// English so Root can recognize them whatever the user's locale.
+func (r *Repo) run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	gitPath := r.GitPath
+	if gitPath == "" {
+		gitPath = "git"
+	}
+	cmd := exec.CommandContext(ctx, gitPath, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0", "GIT_TERMINAL_PROMPT=0", "LC_ALL=C")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return stdout.Bytes(), fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return stdout.Bytes(), &commandError{args: args, msg: msg, err: err}
+	}
+	return stdout.Bytes(), nil
+}
+
+// commandError is a failed git command with its stderr message.
+type commandError struct {
+	args []string
+	msg  string
+	err  error
+}
+
+func (e *commandError) Error() string { return fmt.Sprintf("git %s: %s", e.args[0], e.msg) }
+func (e *commandError) Unwrap() error { return e.err }
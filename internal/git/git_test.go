@@ -0,0 +1,219 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates a repository with one commit containing a.txt and
+// b.txt.
+func newTestRepo(t *testing.T) (*Repo, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	dir := t.TempDir()
+	repo := Open(dir)
+	ctx := context.Background()
+	if _, err := repo.run(ctx, dir, "init", "-q", "-b", "main"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, dir, "a.txt", "one\n")
+	writeFile(t, dir, "b.txt", "two\n")
+	if err := repo.Stage(ctx, "a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit(ctx, "Initial"); err != nil {
+		t.Fatal(err)
+	}
+	return repo, dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func statusOf(files []FileChange) map[string]FileChange {
+	m := make(map[string]FileChange)
+	for _, f := range files {
+		m[f.Path] = f
+	}
+	return m
+}
+
+func TestFiles(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	writeFile(t, dir, ".gitignore", "target/\n")
+	writeFile(t, dir, "target/out.bin", "built")
+	writeFile(t, dir, "src/new.go", "package src")
+
+	files, err := repo.Files(ctx)
+	if err != nil {
+		t.Fatalf("Files() error = %v", err)
+	}
+	sort.Strings(files)
+	if got := strings.Join(files, ","); got != ".gitignore,a.txt,b.txt,src/new.go" {
+		t.Errorf("Files() = %s", got)
+	}
+
+	// Paths are relative to Dir, not the repository root
+	files, err = Open(filepath.Join(dir, "src")).Files(ctx)
+	if err != nil || strings.Join(files, ",") != "new.go" {
+		t.Errorf("Files(src) = %v, %v", files, err)
+	}
+
+	if _, err := Open(t.TempDir()).Files(ctx); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("Files(not a repo) error = %v, want ErrNotRepository", err)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	writeFile(t, dir, "a.txt", "one\nmore\n")
+	os.Remove(filepath.Join(dir, "b.txt"))
+	writeFile(t, dir, "sub/new.txt", "fresh\n")
+	writeFile(t, dir, "staged.txt", "staged\n")
+	if err := repo.Stage(ctx, "staged.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Works from a subdirectory too
+	changes, err := Open(filepath.Join(dir, "sub")).Changes(ctx)
+	if err != nil {
+		t.Fatalf("Changes() error = %v", err)
+	}
+	if changes.Branch != "main" || changes.Truncated {
+		t.Errorf("Changes() branch = %q, truncated = %v", changes.Branch, changes.Truncated)
+	}
+	files := statusOf(changes.Files)
+	if len(files) != 4 {
+		t.Fatalf("Changes() files = %+v, want 4", changes.Files)
+	}
+	if f := files["a.txt"]; f.Status != StatusModified || f.Staged || !f.Unstaged {
+		t.Errorf("a.txt = %+v", f)
+	}
+	if f := files["b.txt"]; f.Status != StatusDeleted {
+		t.Errorf("b.txt = %+v", f)
+	}
+	if f := files["sub/new.txt"]; f.Status != StatusUntracked {
+		t.Errorf("sub/new.txt = %+v", f)
+	}
+	if f := files["staged.txt"]; f.Status != StatusAdded || !f.Staged || f.Unstaged {
+		t.Errorf("staged.txt = %+v", f)
+	}
+	for _, want := range []string{"+more", "-two", "+fresh", "+staged", "b/sub/new.txt"} {
+		if !strings.Contains(changes.Diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, changes.Diff)
+		}
+	}
+}
+
+func TestChanges_Clean(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	changes, err := repo.Changes(context.Background())
+	if err != nil || len(changes.Files) != 0 || changes.Diff != "" {
+		t.Errorf("Changes() = %+v, %v; want no changes", changes, err)
+	}
+}
+
+func TestChanges_NotRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CEILING_DIRECTORIES", os.TempDir())
+	// Translated messages must not hide that the directory is no repository
+	t.Setenv("LANG", "de_DE.UTF-8")
+	t.Setenv("LANGUAGE", "de")
+	_, err := Open(t.TempDir()).Changes(context.Background())
+	if !errors.Is(err, ErrNotRepository) {
+		t.Errorf("Changes() error = %v, want ErrNotRepository", err)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	writeFile(t, dir, "a.txt", "changed\n")
+	writeFile(t, dir, "untracked.txt", "x\n")
+	writeFile(t, dir, "added.txt", "y\n")
+	if err := repo.Stage(ctx, "added.txt", "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.run(ctx, dir, "mv", "b.txt", "c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"a.txt", "untracked.txt", "added.txt", "c.txt"} {
+		if err := repo.Discard(ctx, path); err != nil {
+			t.Fatalf("Discard(%s) error = %v", path, err)
+		}
+	}
+	if files, _ := repo.Status(ctx); len(files) != 0 {
+		t.Errorf("Status() after discard = %+v, want clean", files)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "one\n" {
+		t.Errorf("a.txt = %q", data)
+	}
+	if err := repo.Discard(ctx, "a.txt"); err == nil {
+		t.Error("Discard(unchanged) error = nil, want error")
+	}
+}
+
+func TestCommit(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	if _, err := repo.Commit(ctx, "  "); err == nil {
+		t.Error("Commit(empty message) error = nil, want error")
+	}
+	if _, err := repo.Commit(ctx, "Nothing staged"); err == nil {
+		t.Error("Commit(nothing staged) error = nil, want error")
+	}
+
+	writeFile(t, dir, "a.txt", "changed\n")
+	if err := repo.Stage(ctx, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := repo.Commit(ctx, "Change a")
+	if err != nil || len(hash) != 40 {
+		t.Fatalf("Commit() = %q, %v", hash, err)
+	}
+	out, _ := repo.run(ctx, dir, "log", "-1", "--format=%s")
+	if strings.TrimSpace(string(out)) != "Change a" {
+		t.Errorf("last commit = %q", out)
+	}
+}
+
+func TestChanges_Truncated(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	line := strings.Repeat("x", 99) + "\n"
+	writeFile(t, dir, "big.txt", strings.Repeat(line, maxDiffSize/100+10))
+	changes, err := repo.Changes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Truncated || len(changes.Diff) > maxDiffSize || !strings.HasSuffix(changes.Diff, "\n") {
+		t.Errorf("Changes() truncated = %v, diff size %d", changes.Truncated, len(changes.Diff))
+	}
+}
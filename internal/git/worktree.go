@@ -0,0 +1,160 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Worktree is a checkout of the repository.
+type Worktree struct {
+	Path   string `json:"path"`
+	Branch string `json:"branch,omitempty"` // Empty for a detached HEAD
+	Head   string `json:"head,omitempty"`
+	Main   bool   `json:"main"` // The repository's main checkout
+}
+
+// Worktrees lists the checkouts of the repository, the main one first.
+func (r *Repo) Worktrees(ctx context.Context) ([]Worktree, error) {
+	root, err := r.Root(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.run(ctx, root, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, err
+	}
+	var worktrees []Worktree
+	for _, block := range strings.Split(strings.TrimSpace(string(out)), "\n\n") {
+		var wt Worktree
+		for _, line := range strings.Split(block, "\n") {
+			key, value, _ := strings.Cut(line, " ")
+			switch key {
+			case "worktree":
+				wt.Path = filepath.FromSlash(value)
+			case "HEAD":
+				wt.Head = value
+			case "branch":
+				wt.Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		}
+		if wt.Path == "" {
+			continue
+		}
+		wt.Main = len(worktrees) == 0
+		worktrees = append(worktrees, wt)
+	}
+	return worktrees, nil
+}
+
+// AddWorktree checks out a new branch, starting at HEAD, in a new worktree
+// at path.
+func (r *Repo) AddWorktree(ctx context.Context, path string, branch string) error {
+	root, err := r.Root(ctx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create worktree dir: %w", err)
+	}
+	_, err = r.run(ctx, root, "worktree", "add", "-q", "-b", branch, path, "HEAD")
+	return err
+}
+
+// MergeWorktree merges the branch of the worktree at path into the branch
+// checked out in the main worktree, then removes the worktree and its
+// branch. The main worktree must have no uncommitted changes to tracked
+// files. Uncommitted changes in the worktree are committed first with
+// message. A merge that fails, e.g. on a conflict, is aborted and the
+// worktree is kept, its changes committed on its branch.
+func (r *Repo) MergeWorktree(ctx context.Context, path string, message string) error {
+	main, wt, err := r.findWorktree(ctx, path)
+	if err != nil {
+		return err
+	}
+	if wt.Branch == "" {
+		return fmt.Errorf("worktree %s has no branch to merge", wt.Path)
+	}
+	mainFiles, err := r.status(ctx, main.Path)
+	if err != nil {
+		return err
+	}
+	for _, f := range mainFiles {
+		if f.Status != StatusUntracked {
+			return fmt.Errorf("%s has uncommitted changes; commit or stash them before merging", main.Path)
+		}
+	}
+
+	files, err := r.status(ctx, wt.Path)
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		if _, err := r.run(ctx, wt.Path, "add", "-A"); err != nil {
+			return err
+		}
+		if _, err := r.run(ctx, wt.Path, "commit", "-q", "-m", message); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.run(ctx, main.Path, "merge", "--no-edit", wt.Branch); err != nil {
+		// Only a merge that started, e.g. stopped on a conflict, leaves
+		// MERGE_HEAD behind to abort
+		if _, headErr := r.run(ctx, main.Path, "rev-parse", "-q", "--verify", "MERGE_HEAD"); headErr == nil {
+			if _, abortErr := r.run(ctx, main.Path, "merge", "--abort"); abortErr != nil {
+				return errors.Join(err, fmt.Errorf("abort merge: %w", abortErr))
+			}
+		}
+		return err
+	}
+	return r.removeWorktree(ctx, main, wt)
+}
+
+// RemoveWorktree deletes the worktree at path and its branch, discarding
+// any work not merged.
+func (r *Repo) RemoveWorktree(ctx context.Context, path string) error {
+	main, wt, err := r.findWorktree(ctx, path)
+	if err != nil {
+		return err
+	}
+	return r.removeWorktree(ctx, main, wt)
+}
+
+func (r *Repo) removeWorktree(ctx context.Context, main Worktree, wt Worktree) error {
+	if _, err := r.run(ctx, main.Path, "worktree", "remove", "--force", wt.Path); err != nil {
+		return err
+	}
+	if wt.Branch == "" {
+		return nil
+	}
+	_, err := r.run(ctx, main.Path, "branch", "-D", wt.Branch)
+	return err
+}
+
+// findWorktree returns the main worktree and the linked worktree at path.
+func (r *Repo) findWorktree(ctx context.Context, path string) (Worktree, Worktree, error) {
+	worktrees, err := r.Worktrees(ctx)
+	if err != nil {
+		return Worktree{}, Worktree{}, err
+	}
+	want := RealPath(path)
+	for _, wt := range worktrees[1:] {
+		if RealPath(wt.Path) == want {
+			return worktrees[0], wt, nil
+		}
+	}
+	return Worktree{}, Worktree{}, fmt.Errorf("%s is not a linked worktree of %s", path, worktrees[0].Path)
+}
+
+// RealPath resolves symlinks in path, as git does when reporting
+// worktrees, falling back to the cleaned path.
+func RealPath(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return filepath.Clean(path)
+}
@@ -0,0 +1,132 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorktrees(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	wtPath := filepath.Join(t.TempDir(), "wt", "fix")
+	if err := repo.AddWorktree(ctx, wtPath, "dogma/fix"); err != nil {
+		t.Fatalf("AddWorktree() error = %v", err)
+	}
+
+	worktrees, err := Open(wtPath).Worktrees(ctx)
+	if err != nil {
+		t.Fatalf("Worktrees() error = %v", err)
+	}
+	if len(worktrees) != 2 {
+		t.Fatalf("Worktrees() = %+v, want 2", worktrees)
+	}
+	if !worktrees[0].Main || RealPath(worktrees[0].Path) != RealPath(dir) || worktrees[0].Branch != "main" {
+		t.Errorf("main worktree = %+v", worktrees[0])
+	}
+	if worktrees[1].Main || RealPath(worktrees[1].Path) != RealPath(wtPath) || worktrees[1].Branch != "dogma/fix" || worktrees[1].Head == "" {
+		t.Errorf("linked worktree = %+v", worktrees[1])
+	}
+}
+
+func TestMergeWorktree(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	wtPath := filepath.Join(t.TempDir(), "fix")
+	if err := repo.AddWorktree(ctx, wtPath, "dogma/fix"); err != nil {
+		t.Fatal(err)
+	}
+	// Uncommitted work in the worktree is committed before merging
+	writeFile(t, wtPath, "a.txt", "fixed\n")
+	writeFile(t, wtPath, "new.txt", "added\n")
+
+	if err := repo.MergeWorktree(ctx, wtPath, "Fix from session"); err != nil {
+		t.Fatalf("MergeWorktree() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "fixed\n" {
+		t.Errorf("a.txt = %q after merge", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.txt")); err != nil {
+		t.Errorf("new.txt not merged: %v", err)
+	}
+	if _, err := os.Stat(wtPath); !os.IsNotExist(err) {
+		t.Errorf("worktree dir still exists: %v", err)
+	}
+	if worktrees, _ := repo.Worktrees(ctx); len(worktrees) != 1 {
+		t.Errorf("Worktrees() = %+v, want main only", worktrees)
+	}
+	if out, _ := repo.run(ctx, dir, "branch", "--list", "dogma/fix"); strings.TrimSpace(string(out)) != "" {
+		t.Errorf("branch still exists: %q", out)
+	}
+}
+
+func TestMergeWorktree_ConflictKeepsWorktree(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	wtPath := filepath.Join(t.TempDir(), "fix")
+	if err := repo.AddWorktree(ctx, wtPath, "dogma/fix"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, wtPath, "a.txt", "theirs\n")
+	writeFile(t, dir, "a.txt", "ours\n")
+	if err := repo.Stage(ctx, "a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Commit(ctx, "Change on main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.MergeWorktree(ctx, wtPath, "Fix"); err == nil {
+		t.Fatal("MergeWorktree() error = nil, want conflict")
+	}
+	if files, _ := repo.Status(ctx); len(files) != 0 {
+		t.Errorf("main checkout not clean after aborted merge: %+v", files)
+	}
+	if _, err := os.Stat(wtPath); err != nil {
+		t.Errorf("worktree removed despite failed merge: %v", err)
+	}
+}
+
+func TestMergeWorktree_DirtyCheckout(t *testing.T) {
+	repo, dir := newTestRepo(t)
+	ctx := context.Background()
+	wtPath := filepath.Join(t.TempDir(), "fix")
+	if err := repo.AddWorktree(ctx, wtPath, "dogma/fix"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, wtPath, "a.txt", "theirs\n")
+	writeFile(t, dir, "a.txt", "local edit\n")
+
+	if err := repo.MergeWorktree(ctx, wtPath, "Fix"); err == nil || !strings.Contains(err.Error(), "uncommitted changes") {
+		t.Fatalf("MergeWorktree() error = %v, want uncommitted changes", err)
+	}
+	// Neither checkout was touched
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "local edit\n" {
+		t.Errorf("main a.txt = %q", data)
+	}
+	if files, _ := Open(wtPath).Status(ctx); len(files) != 1 {
+		t.Errorf("worktree changes were committed: %+v", files)
+	}
+}
+
+func TestRemoveWorktree(t *testing.T) {
+	repo, _ := newTestRepo(t)
+	ctx := context.Background()
+	wtPath := filepath.Join(t.TempDir(), "fix")
+	if err := repo.AddWorktree(ctx, wtPath, "dogma/fix"); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, wtPath, "a.txt", "throwaway\n")
+
+	if err := repo.RemoveWorktree(ctx, wtPath); err != nil {
+		t.Fatalf("RemoveWorktree() error = %v", err)
+	}
+	if worktrees, _ := repo.Worktrees(ctx); len(worktrees) != 1 {
+		t.Errorf("Worktrees() = %+v, want main only", worktrees)
+	}
+	if err := repo.RemoveWorktree(ctx, wtPath); err == nil {
+		t.Error("RemoveWorktree(removed) error = nil, want error")
+	}
+}
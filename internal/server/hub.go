@@ -0,0 +1,126 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket timing. Clients that do not answer pings within pongWait are
+// dropped.
+const (
+	writeWait  = 10 * time.Second
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	sendBuffer = 256 // Messages queued per client before it counts as stuck
+)
+
+// hub fans frontend events out to the connected WebSockets.
+type hub struct {
+	mu      sync.Mutex
+	clients map[*client]bool
+}
+
+// client is one WebSocket connection. Messages are queued on send and
+// written by the client's own goroutine, so a slow browser never blocks
+// the App.
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	once sync.Once
+}
+
+func newHub() *hub {
+	return &hub{clients: make(map[*client]bool)}
+}
+
+// serve registers conn and runs its read and write loops until it closes.
+func (h *hub) serve(conn *websocket.Conn) {
+	c := &client{conn: conn, send: make(chan []byte, sendBuffer)}
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+
+	go c.writeLoop()
+	c.readLoop()
+	h.remove(c)
+}
+
+// broadcast queues payload for every client. Clients whose queue is full
+// are disconnected instead of delaying the others.
+func (h *hub) broadcast(payload []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		select {
+		case c.send <- payload:
+		default:
+			delete(h.clients, c)
+			c.close()
+		}
+	}
+}
+
+func (h *hub) remove(c *client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	h.mu.Unlock()
+	c.close()
+}
+
+// closeAll disconnects every client.
+func (h *hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		delete(h.clients, c)
+		c.close()
+	}
+}
+
+// close stops the write loop, which then closes the connection.
+func (c *client) close() {
+	c.once.Do(func() { close(c.send) })
+}
+
+// readLoop discards incoming messages; the stream is one-way. It returns
+// when the connection fails or the browser closes it.
+func (c *client) readLoop() {
+	c.conn.SetReadLimit(512)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *client) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+	for {
+		select {
+		case payload, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Package server serves dogma over HTTP for use from a browser, e.g. on a
+// dev box reached through an SSH tunnel. It offers the desktop bindings as
+// a JSON API and streams the frontend events over a WebSocket, with the
+// same names and payloads the desktop frontend receives.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/claude"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultAddr is the listen address used when none is given. It only
+// accepts connections from the same machine.
+const DefaultAddr = "127.0.0.1:7777"
+
+// maxRequestSize bounds JSON request bodies.
+const maxRequestSize = 1 << 20
+
+// App is the part of the desktop bindings served over HTTP.
+type App interface {
+	SendPromptWithSessionAndRequestId(prompt string, sessionID string, requestID string)
+	CancelPrompt()
+	ListSessions() ([]claude.SessionInfo, error)
+	LoadSession(sessionID string) (claude.Transcript, error)
+}
+
+// PromptRequest is the body of POST /api/prompt. An empty SessionID
+// starts a new session.
+type PromptRequest struct {
+	Prompt    string `json:"prompt"`
+	SessionID string `json:"session_id,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Message is a frontend event sent over the WebSocket.
+type Message struct {
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// Server serves an App. API requests must carry the token as a bearer
+// token. Browsers cannot set headers on a WebSocket, so GET /api/events
+// alone also accepts it as the token query parameter. The page itself is
+// public and only reads the token from its own URL.
+type Server struct {
+	app    App
+	token  string
+	assets fs.FS
+	hub    *hub
+
+	upgrader websocket.Upgrader
+}
+
+// New creates a Server for app. assets holds the frontend build served at
+// "/"; it may be nil to serve the API only.
+func New(app App, token string, assets fs.FS) *Server {
+	return &Server{app: app, token: token, assets: assets, hub: newHub()}
+}
+
+// NewToken returns a random token for New.
+func NewToken() (string, error) {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Emit sends a frontend event to every connected WebSocket. It has the
+// signature of the desktop event emitter, so the App can publish through
+// it unchanged.
+func (s *Server) Emit(eventName string, data ...interface{}) {
+	msg := Message{Event: eventName}
+	if len(data) > 0 {
+		msg.Data = data[0]
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.hub.broadcast(payload)
+}
+
+// Handler returns the HTTP handler of the server.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/prompt", s.handlePrompt)
+	mux.HandleFunc("POST /api/cancel", s.handleCancel)
+	mux.HandleFunc("GET /api/sessions", s.handleListSessions)
+	mux.HandleFunc("GET /api/sessions/{id}", s.handleLoadSession)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	if s.assets != nil {
+		// The page itself is public so the browser can load it; everything
+		// it does goes through the API.
+		return s.withAuth(mux, http.FileServerFS(s.assets))
+	}
+	return s.withAuth(mux, http.NotFoundHandler())
+}
+
+// ListenAndServe serves on addr until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is done, then closes the WebSockets and
+// shuts down.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	s.hub.closeAll()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// IsLoopback reports whether addr only listens on the local machine.
+func IsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// withAuth routes /api/ requests to api after checking the token and
+// everything else to static.
+func (s *Server) withAuth(api http.Handler, static http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			static.ServeHTTP(w, r)
+			return
+		}
+		if !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		api.ServeHTTP(w, r)
+	})
+}
+
+// authorized reports whether r carries the server's token. Tokens in URLs
+// end up in proxy logs and browser history, so the query parameter is only
+// accepted for the WebSocket.
+func (s *Server) authorized(r *http.Request) bool {
+	var token string
+	if r.Method == http.MethodGet && r.URL.Path == "/api/events" {
+		token = r.URL.Query().Get("token")
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	return s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	var req PromptRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("prompt is empty"))
+		return
+	}
+	// Like the binding, this returns at once; the run is reported through
+	// the event stream.
+	s.app.SendPromptWithSessionAndRequestId(req.Prompt, req.SessionID, req.RequestID)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	s.app.CancelPrompt()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := s.app.ListSessions()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+func (s *Server) handleLoadSession(w http.ResponseWriter, r *http.Request) {
+	tr, err := s.app.LoadSession(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tr)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied
+		return
+	}
+	s.hub.serve(conn)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Marcel-Bich/dogma/internal/claude"
+	"github.com/gorilla/websocket"
+)
+
+const testToken = "secret"
+
+type fakeApp struct {
+	mu        sync.Mutex
+	prompts   []PromptRequest
+	cancelled bool
+	loadErr   error
+}
+
+func (f *fakeApp) SendPromptWithSessionAndRequestId(prompt string, sessionID string, requestID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prompts = append(f.prompts, PromptRequest{Prompt: prompt, SessionID: sessionID, RequestID: requestID})
+}
+
+func (f *fakeApp) CancelPrompt() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancelled = true
+}
+
+func (f *fakeApp) ListSessions() ([]claude.SessionInfo, error) {
+	return []claude.SessionInfo{{ID: "s1", Summary: "First"}}, nil
+}
+
+func (f *fakeApp) LoadSession(sessionID string) (claude.Transcript, error) {
+	if f.loadErr != nil {
+		return claude.Transcript{}, f.loadErr
+	}
+	return claude.Transcript{SessionID: sessionID, Events: []claude.BridgeEvent{{Type: "user", Text: "Hi"}}}, nil
+}
+
+func newTestServer(t *testing.T, app App) (*Server, *httptest.Server) {
+	t.Helper()
+	assets := fstest.MapFS{"index.html": &fstest.MapFile{Data: []byte("<html>dogma</html>")}}
+	s := New(app, testToken, assets)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return s, ts
+}
+
+func do(t *testing.T, method, url, token, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAPI(t *testing.T) {
+	app := &fakeApp{}
+	_, ts := newTestServer(t, app)
+
+	resp := do(t, "POST", ts.URL+"/api/prompt", testToken, `{"prompt":"Hello","session_id":"s1","request_id":"r1"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("POST /api/prompt status = %d", resp.StatusCode)
+	}
+	if len(app.prompts) != 1 || app.prompts[0] != (PromptRequest{Prompt: "Hello", SessionID: "s1", RequestID: "r1"}) {
+		t.Errorf("prompts = %+v", app.prompts)
+	}
+
+	if resp := do(t, "POST", ts.URL+"/api/cancel", testToken, ""); resp.StatusCode != http.StatusNoContent || !app.cancelled {
+		t.Errorf("POST /api/cancel status = %d, cancelled = %v", resp.StatusCode, app.cancelled)
+	}
+
+	resp = do(t, "GET", ts.URL+"/api/sessions", testToken, "")
+	var sessions []claude.SessionInfo
+	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil || len(sessions) != 1 || sessions[0].ID != "s1" {
+		t.Errorf("GET /api/sessions = %+v, %v", sessions, err)
+	}
+
+	resp = do(t, "GET", ts.URL+"/api/sessions/s1", testToken, "")
+	var tr claude.Transcript
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil || tr.SessionID != "s1" || len(tr.Events) != 1 {
+		t.Errorf("GET /api/sessions/s1 = %+v, %v", tr, err)
+	}
+}
+
+func TestAPI_Errors(t *testing.T) {
+	app := &fakeApp{loadErr: errors.New("session not found")}
+	_, ts := newTestServer(t, app)
+
+	for _, token := range []string{"", "wrong"} {
+		if resp := do(t, "GET", ts.URL+"/api/sessions", token, ""); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, resp.StatusCode)
+		}
+	}
+	// Only the WebSocket takes the token as a query parameter
+	if resp := do(t, "GET", ts.URL+"/api/sessions?token="+testToken, "", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("query token on GET /api/sessions: status = %d, want 401", resp.StatusCode)
+	}
+	if resp := do(t, "POST", ts.URL+"/api/prompt?token="+testToken, "", `{"prompt":"hi"}`); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("query token on POST /api/prompt: status = %d, want 401", resp.StatusCode)
+	}
+
+	if resp := do(t, "POST", ts.URL+"/api/prompt", testToken, `{"prompt":"  "}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("empty prompt: status = %d, want 400", resp.StatusCode)
+	}
+	if resp := do(t, "POST", ts.URL+"/api/prompt", testToken, `not json`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid body: status = %d, want 400", resp.StatusCode)
+	}
+	if len(app.prompts) != 0 {
+		t.Errorf("prompts sent for invalid or unauthorized requests: %+v", app.prompts)
+	}
+
+	resp := do(t, "GET", ts.URL+"/api/sessions/missing", testToken, "")
+	var body map[string]string
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusInternalServerError || body["error"] != "session not found" {
+		t.Errorf("load error: status = %d, body = %v", resp.StatusCode, body)
+	}
+}
+
+func TestStaticAssets(t *testing.T) {
+	_, ts := newTestServer(t, &fakeApp{})
+	resp := do(t, "GET", ts.URL+"/", "", "")
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(data), "dogma") {
+		t.Errorf("GET / = %d %q", resp.StatusCode, data)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s, ts := newTestServer(t, &fakeApp{})
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/events"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Dial without token: err = %v, want 401", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?token="+testToken, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	// Wait until the hub knows the connection
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.hub.mu.Lock()
+		n := len(s.hub.clients)
+		s.hub.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client not registered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	s.Emit("claude:event", claude.BridgeEvent{Type: "assistant", Text: "Hi", SessionID: "s1"})
+	s.Emit("claude:done")
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var first struct {
+		Event string             `json:"event"`
+		Data  claude.BridgeEvent `json:"data"`
+	}
+	if err := conn.ReadJSON(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Event != "claude:event" || first.Data.Text != "Hi" || first.Data.SessionID != "s1" {
+		t.Errorf("first message = %+v", first)
+	}
+	var second Message
+	if err := conn.ReadJSON(&second); err != nil {
+		t.Fatal(err)
+	}
+	if second.Event != "claude:done" || second.Data != nil {
+		t.Errorf("second message = %+v", second)
+	}
+}
+
+func TestServe_StopsOnCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- New(&fakeApp{}, testToken, nil).Serve(ctx, ln) }()
+
+	resp := do(t, "GET", "http://"+ln.Addr().String()+"/api/sessions", testToken, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not stop")
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		DefaultAddr:      true,
+		"localhost:80":   true,
+		"[::1]:7777":     true,
+		"0.0.0.0:7777":   false,
+		":7777":          false,
+		"10.0.0.5:7777":  false,
+		"not an address": false,
+	} {
+		if got := IsLoopback(addr); got != want {
+			t.Errorf("IsLoopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
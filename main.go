@@ -2,6 +2,10 @@ package main
 
 import (
 	"embed"
+	"flag"
+	"os"
+
+	"github.com/Marcel-Bich/dogma/internal/server"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -12,9 +16,21 @@ import (
 var assets embed.FS
 
 func main() {
+	serve := flag.Bool("serve", false, "run headless and serve the UI over HTTP instead of opening a window")
+	listen := flag.String("listen", server.DefaultAddr, "address to serve on with -serve")
+	flag.Parse()
+
 	// Create an instance of the app structure
 	app := NewApp()
 
+	if *serve {
+		if err := runHeadless(app, *listen); err != nil {
+			println("Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Create application with options
 	err := wails.Run(&options.App{
 		Title:  "dogma",